@@ -6,14 +6,15 @@ import (
 	"database/sql"
 	_ "eggServer/docs"
 	"eggServer/internal/config"
+	"eggServer/internal/contextx"
 	cfg "eggServer/internal/gamedata"
+	"eggServer/internal/gamedatax"
 	"eggServer/internal/handler"
 	"eggServer/internal/logic"
 	"eggServer/internal/middleware"
 	"eggServer/internal/models"
 	"eggServer/pkg/redisbackend"
 	"eggServer/pkg/utils"
-	"encoding/json"
 	"fmt"
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
@@ -59,6 +60,9 @@ import (
 // Usage: go build -ldflags "-X main.VERSION=x.x.x"
 var VERSION = "1.0.0"
 
+// 配置表目录
+const gameDataDir = "./data/static/"
+
 var (
 	l            *logrus.Entry
 	ginLambda    *ginadapter.GinLambdaV2
@@ -84,12 +88,22 @@ func main() {
 
 	config.MustLoad(fmt.Sprintf("./data/conf/game%s.toml", version))
 
+	// 没有机器人token无法校验initData，所有登录都会失败
+	if config.C.Telegram.BotToken == "" {
+		log.Fatalln("Telegram.BotToken is empty, set it in the config file or CONFIG_TELEGRAM_BOTTOKEN")
+	}
+
 	config.PrintWithJSON()
 	initLogger()
 	initGameData()
 	initRedisBackend()
 	initGorm()
 	initLogic()
+	initBattlePush()
+	initBattleScheduler()
+	initTournamentScheduler()
+	initPaymentWatcher()
+	initOrderSweeper()
 	initGin()
 }
 
@@ -131,21 +145,9 @@ func initLogger() {
 	})
 }
 
-func loader(file string) ([]map[string]interface{}, error) {
-	if bytes, err := os.ReadFile("./data/static/" + file + ".json"); err != nil {
-		return nil, err
-	} else {
-		jsonData := make([]map[string]interface{}, 0)
-		if err = json.Unmarshal(bytes, &jsonData); err != nil {
-			return nil, err
-		}
-		return jsonData, nil
-	}
-}
-
 func initGameData() {
 	log.Println("initGameData")
-	if tb, err := cfg.NewTables(loader); err != nil {
+	if tb, err := gamedatax.Load(gameDataDir); err != nil {
 		log.Fatalln(err.Error())
 	} else {
 		tables = tb
@@ -242,6 +244,58 @@ func closeDB() {
 func initLogic() {
 	log.Println("initLogic")
 	logic.Init(tables)
+	logic.GameDataLogic.Init(gameDataDir)
+
+	// 监听其他实例重新加载配置表
+	ctx := contextx.NewLogger(context.Background(), l)
+	go logic.GameDataLogic.Run(ctx, redisBackend)
+}
+
+func initBattlePush() {
+	log.Println("initBattlePush")
+
+	ctx := contextx.NewLogger(context.Background(), l)
+	go logic.BattlePushLogic.Run(ctx, redisBackend)
+}
+
+func initBattleScheduler() {
+	if !config.C.BattleScheduler.Enable {
+		return
+	}
+	log.Println("initBattleScheduler")
+
+	ctx := contextx.NewLogger(context.Background(), l)
+	go logic.BattleLogic.RunScheduler(ctx, gormDB, redisBackend, config.C.BattleScheduler.Interval*time.Second)
+}
+
+func initTournamentScheduler() {
+	if !config.C.Tournament.SchedulerEnable {
+		return
+	}
+	log.Println("initTournamentScheduler")
+
+	ctx := contextx.NewLogger(context.Background(), l)
+	go logic.TournamentLogic.RunScheduler(ctx, gormDB, redisBackend, config.C.Tournament.SchedulerInterval*time.Second)
+}
+
+func initPaymentWatcher() {
+	if !config.C.PaymentWatcher.Enable {
+		return
+	}
+	log.Println("initPaymentWatcher")
+
+	ctx := contextx.NewLogger(context.Background(), l)
+	go logic.PaymentLogic.RunWatcher(ctx, gormDB, redisBackend, config.C.PaymentWatcher.Interval*time.Second)
+}
+
+func initOrderSweeper() {
+	if !config.C.Order.SweeperEnable {
+		return
+	}
+	log.Println("initOrderSweeper")
+
+	ctx := contextx.NewLogger(context.Background(), l)
+	go logic.OrderLogic.RunSweeper(ctx, gormDB, redisBackend, config.C.Order.SweeperInterval*time.Second)
 }
 
 func initGin() {
@@ -306,6 +360,7 @@ func register(app *gin.Engine) error {
 	app.Use(middleware.DB(gormDB))
 	app.Use(middleware.RB(redisBackend))
 	app.Use(middleware.Trace())
+	app.Use(middleware.GameData())
 
 	// Swagger
 	if config.C.Swagger {
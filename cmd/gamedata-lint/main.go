@@ -0,0 +1,31 @@
+package main
+
+import (
+	"eggServer/internal/gamedatax"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// 校验配置表，有不符合规则的配置时返回非0
+// Usage: go run ./cmd/gamedata-lint -dir ./data/static
+func main() {
+	dir := flag.String("dir", "./data/static", "配置表目录")
+	flag.Parse()
+
+	if _, err := gamedatax.Load(*dir); err != nil {
+		var e *gamedatax.ValidateError
+		if errors.As(err, &e) {
+			for _, v := range e.Violations {
+				fmt.Println(v.String())
+			}
+			fmt.Printf("%d violations\n", len(e.Violations))
+		} else {
+			fmt.Println(err.Error())
+		}
+		os.Exit(1)
+	}
+
+	fmt.Println("ok")
+}
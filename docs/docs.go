@@ -250,32 +250,14 @@ const docTemplate = `{
         "schema.LoginReq": {
             "type": "object",
             "required": [
-                "userUid"
+                "initData"
             ],
             "properties": {
-                "firstName": {
-                    "type": "string"
-                },
-                "languageCode": {
-                    "type": "string"
-                },
-                "lastName": {
+                "initData": {
                     "type": "string"
                 },
                 "os": {
                     "type": "string"
-                },
-                "photoUrl": {
-                    "type": "string"
-                },
-                "startParam": {
-                    "type": "string"
-                },
-                "userUid": {
-                    "type": "string"
-                },
-                "username": {
-                    "type": "string"
                 }
             }
         }
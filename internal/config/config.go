@@ -57,25 +57,37 @@ func PrintWithJSON() {
 }
 
 type Config struct {
-	RunMode       string
-	PrintConfig   bool
-	Swagger       bool
-	GM            bool
-	DataKey       byte
-	WalletAddress string
-	JWTAuth       JWTAuth
-	RateLimiter   RateLimiter
-	CORS          CORS
-	Gorm          Gorm
-	MySQL         MySQL
-	RedisBackend  RedisBackend
-	Log           Log
+	RunMode         string
+	PrintConfig     bool
+	Swagger         bool
+	GM              bool
+	DataKey         byte
+	WalletAddress   string
+	Telegram        Telegram
+	JWTAuth         JWTAuth
+	RateLimiter     RateLimiter
+	Idempotency     Idempotency
+	BattleScheduler BattleScheduler
+	Tournament      Tournament
+	PaymentWatcher  PaymentWatcher
+	Order           Order
+	PriceOracle     PriceOracle
+	CORS            CORS
+	Gorm            Gorm
+	MySQL           MySQL
+	RedisBackend    RedisBackend
+	Log             Log
 }
 
 func (c *Config) IsDebugMode() bool {
 	return c.RunMode == "debug"
 }
 
+type Telegram struct {
+	BotToken    string
+	AuthExpired time.Duration
+}
+
 type JWTAuth struct {
 	Key        string
 	Expired    time.Duration
@@ -87,6 +99,44 @@ type RateLimiter struct {
 	Count  int
 }
 
+type Idempotency struct {
+	Enable  bool
+	Expired time.Duration
+}
+
+type BattleScheduler struct {
+	Enable   bool
+	Interval time.Duration
+}
+
+type Tournament struct {
+	SchedulerEnable   bool
+	SchedulerInterval time.Duration
+}
+
+type PaymentWatcher struct {
+	Enable   bool
+	Interval time.Duration
+}
+
+type Order struct {
+	PayTimeout      time.Duration
+	SweeperEnable   bool
+	SweeperInterval time.Duration
+}
+
+type PriceOracle struct {
+	Providers        []string
+	MinSources       int
+	Timeout          time.Duration
+	MaxStale         time.Duration
+	CacheExpired     time.Duration
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+	Override         map[string]float64
+	Static           map[string]float64
+}
+
 type CORS struct {
 	Enable           bool
 	AllowOrigins     []string
@@ -36,6 +36,23 @@ const (
     AlreadyJoinOtherBattle = 27                  // 已报名其他战场
     BattleRegistrationFull = 28                  // 报名人数已满
     RegisteredUsers = 29                         // 最近一小时注册的用户数量: {1}
+    InitDataInvalid = 30                         // 登录数据校验失败
+    InitDataExpired = 31                         // 登录数据已过期，请重新打开
+    BattleNotFinished = 32                       // 战斗还未结束
+    OrderDelivered = 33                          // 订单已发货
+    OrderExpired = 34                            // 订单已过期
+    OrderUnderpaid = 35                          // 支付金额不足
+    OrderStatusInvalid = 36                      // 订单状态错误
+    PetMaxLevel = 37                             // 宠物已达到当前阶段的最高等级
+    PetCannotEvolve = 38                         // 宠物不能进化
+    InviteCodeInvalid = 39                       // 邀请码无效
+    NotBattleCreator = 40                        // 不是房间的创建者
+    TournamentNotOpen = 41                       // 不在锦标赛报名时间
+    TournamentAlreadyRegistered = 42             // 已经报名锦标赛
+    TournamentNoPrize = 43                       // 没有可以领取的锦标赛奖励
+    NotInMatchQueue = 44                         // 不在匹配队列中
+    NoBattleToResume = 45                        // 没有可以恢复的战斗
+    IdempotencyKeyReused = 46                    // 幂等键已经用于其他请求
     BattleVictory = 1000                         // 你在刚刚的{1}取得胜利获得奖励{2} <img src='ui://item/gofen'/>
     BattleFailure = 1001                         // 你在刚刚的{1}遗憾落败
     ShopRefresh = 1002                           // 是否花费{1} <img src='ui://item/zs02'/>刷新？
@@ -8,3 +8,41 @@ const (
 	TON        = 1       // TON
 	SignInCard = 2001008 // 签到卡
 )
+
+// 道具和宠物变化的来源
+const (
+	SourceShopBuy          = 1  // 商店购买
+	SourceTaskReward       = 2  // 任务奖励
+	SourceBattleSettlement = 3  // 战斗结算
+	SourceGM               = 4  // GM指令
+	SourceSignIn           = 5  // 签到
+	SourceEggOpen          = 6  // 开蛋
+	SourceOrderDelivery    = 7  // 订单发货
+	SourceBattleJoin       = 8  // 报名战斗
+	SourceBattleLeave      = 9  // 离开战斗
+	SourceInvite           = 10 // 邀请奖励
+	SourcePassPort         = 11 // 通行证奖励
+	SourceItemUse          = 12 // 使用道具
+	SourceCreateRole       = 13 // 创建角色
+	SourceAutoAddVit       = 14 // 体力恢复
+	SourceClickScreen      = 15 // 点击屏幕
+	SourceShopRefresh      = 16 // 刷新商店
+	SourceEggFusion        = 17 // 合成蛋
+	SourcePetFeed          = 18 // 喂养宠物
+	SourcePetEvolve        = 19 // 宠物进化
+	SourceTournamentEntry  = 20 // 锦标赛报名
+	SourceTournamentRefund = 21 // 锦标赛取消退还
+	SourceTournamentPrize  = 22 // 锦标赛奖励
+)
+
+// 订单状态
+const (
+	OrderStatusPending   byte = 0 // 待支付
+	OrderStatusDelivered byte = 1 // 已发货
+	OrderStatusPaid      byte = 2 // 已支付，待发货
+	OrderStatusExpired   byte = 3 // 已过期
+	OrderStatusCancelled byte = 4 // 已取消
+	OrderStatusRefunded  byte = 5 // 已退款
+	OrderStatusUnderpaid byte = 6 // 少付，未发货，需要退款
+	OrderStatusOverpaid  byte = 7 // 多付，已发货，需要退还多付的部分
+)
@@ -0,0 +1,45 @@
+
+//------------------------------------------------------------------------------
+// <auto-generated>
+//     This code was generated by a tool.
+//     Changes to this file may cause incorrect behavior and will be lost if
+//     the code is regenerated.
+// </auto-generated>
+//------------------------------------------------------------------------------
+
+package cfg;
+
+
+type EggFusionTb struct {
+    _dataMap map[int32]*IEggFusion
+    _dataList []*IEggFusion
+}
+
+func NewEggFusionTb(_buf []map[string]interface{}) (*EggFusionTb, error) {
+    _dataList := make([]*IEggFusion, 0, len(_buf))
+    dataMap := make(map[int32]*IEggFusion)
+
+    for _, _ele_ := range _buf {
+        if _v, err2 := NewIEggFusion(_ele_); err2 != nil {
+            return nil, err2
+        } else {
+            _dataList = append(_dataList, _v)
+            dataMap[_v.Id] = _v
+        }
+    }
+    return &EggFusionTb{_dataList:_dataList, _dataMap:dataMap}, nil
+}
+
+func (table *EggFusionTb) GetDataMap() map[int32]*IEggFusion {
+    return table._dataMap
+}
+
+func (table *EggFusionTb) GetDataList() []*IEggFusion {
+    return table._dataList
+}
+
+func (table *EggFusionTb) Get(key int32) *IEggFusion {
+    return table._dataMap[key]
+}
+
+
@@ -0,0 +1,45 @@
+
+//------------------------------------------------------------------------------
+// <auto-generated>
+//     This code was generated by a tool.
+//     Changes to this file may cause incorrect behavior and will be lost if
+//     the code is regenerated.
+// </auto-generated>
+//------------------------------------------------------------------------------
+
+package cfg;
+
+
+type EggPityTb struct {
+    _dataMap map[int32]*IEggPity
+    _dataList []*IEggPity
+}
+
+func NewEggPityTb(_buf []map[string]interface{}) (*EggPityTb, error) {
+    _dataList := make([]*IEggPity, 0, len(_buf))
+    dataMap := make(map[int32]*IEggPity)
+
+    for _, _ele_ := range _buf {
+        if _v, err2 := NewIEggPity(_ele_); err2 != nil {
+            return nil, err2
+        } else {
+            _dataList = append(_dataList, _v)
+            dataMap[_v.Id] = _v
+        }
+    }
+    return &EggPityTb{_dataList:_dataList, _dataMap:dataMap}, nil
+}
+
+func (table *EggPityTb) GetDataMap() map[int32]*IEggPity {
+    return table._dataMap
+}
+
+func (table *EggPityTb) GetDataList() []*IEggPity {
+    return table._dataList
+}
+
+func (table *EggPityTb) Get(key int32) *IEggPity {
+    return table._dataMap[key]
+}
+
+
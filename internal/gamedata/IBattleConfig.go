@@ -30,6 +30,7 @@ type IBattleConfig struct {
     CanExit int32
     IsOpen int32
     IsGuide int32
+    RobotStrategyWeight []int32
 }
 
 const TypeId_IBattleConfig = 1955824899
@@ -96,6 +97,20 @@ func NewIBattleConfig(_buf map[string]interface{}) (_v *IBattleConfig, err error
     { var _ok_ bool; var _tempNum_ float64; if _tempNum_, _ok_ = _buf["canExit"].(float64); !_ok_ { err = errors.New("canExit error"); return }; _v.CanExit = int32(_tempNum_) }
     { var _ok_ bool; var _tempNum_ float64; if _tempNum_, _ok_ = _buf["isOpen"].(float64); !_ok_ { err = errors.New("isOpen error"); return }; _v.IsOpen = int32(_tempNum_) }
     { var _ok_ bool; var _tempNum_ float64; if _tempNum_, _ok_ = _buf["isGuide"].(float64); !_ok_ { err = errors.New("isGuide error"); return }; _v.IsGuide = int32(_tempNum_) }
+ {
+                    var _arr_ []interface{}
+                    var _ok_ bool
+                    if _arr_, _ok_ = _buf["robotStrategyWeight"].([]interface{}); !_ok_ { err = errors.New("robotStrategyWeight error"); return }
+    
+                    _v.RobotStrategyWeight = make([]int32, 0, len(_arr_))
+                    
+                    for _, _e_ := range _arr_ {
+                        var _list_v_ int32
+                        { var _ok_ bool; var _x_ float64; if _x_, _ok_ = _e_.(float64); !_ok_ { err = errors.New("_list_v_ error"); return }; _list_v_ = int32(_x_) }
+                        _v.RobotStrategyWeight = append(_v.RobotStrategyWeight, _list_v_)
+                    }
+                }
+
     return
 }
 
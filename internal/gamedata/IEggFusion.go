@@ -0,0 +1,78 @@
+
+//------------------------------------------------------------------------------
+// <auto-generated>
+//     This code was generated by a tool.
+//     Changes to this file may cause incorrect behavior and will be lost if
+//     the code is regenerated.
+// </auto-generated>
+//------------------------------------------------------------------------------
+
+package cfg;
+
+import "errors"
+
+type IEggFusion struct {
+    Id int32
+    EggScore []float64
+    QualityWeight []int32
+    CatalystId int32
+    CatalystNum int32
+    CatalystWeight []int32
+}
+
+const TypeId_IEggFusion = 834136608
+
+func (*IEggFusion) GetTypeId() int32 {
+    return 834136608
+}
+
+func NewIEggFusion(_buf map[string]interface{}) (_v *IEggFusion, err error) {
+    _v = &IEggFusion{}
+    { var _ok_ bool; var _tempNum_ float64; if _tempNum_, _ok_ = _buf["id"].(float64); !_ok_ { err = errors.New("id error"); return }; _v.Id = int32(_tempNum_) }
+     {
+                    var _arr_ []interface{}
+                    var _ok_ bool
+                    if _arr_, _ok_ = _buf["eggScore"].([]interface{}); !_ok_ { err = errors.New("eggScore error"); return }
+    
+                    _v.EggScore = make([]float64, 0, len(_arr_))
+                    
+                    for _, _e_ := range _arr_ {
+                        var _list_v_ float64
+                        { var _ok_ bool; var _x_ float64; if _x_, _ok_ = _e_.(float64); !_ok_ { err = errors.New("_list_v_ error"); return }; _list_v_ = float64(_x_) }
+                        _v.EggScore = append(_v.EggScore, _list_v_)
+                    }
+                }
+
+     {
+                    var _arr_ []interface{}
+                    var _ok_ bool
+                    if _arr_, _ok_ = _buf["qualityWeight"].([]interface{}); !_ok_ { err = errors.New("qualityWeight error"); return }
+    
+                    _v.QualityWeight = make([]int32, 0, len(_arr_))
+                    
+                    for _, _e_ := range _arr_ {
+                        var _list_v_ int32
+                        { var _ok_ bool; var _x_ float64; if _x_, _ok_ = _e_.(float64); !_ok_ { err = errors.New("_list_v_ error"); return }; _list_v_ = int32(_x_) }
+                        _v.QualityWeight = append(_v.QualityWeight, _list_v_)
+                    }
+                }
+
+    { var _ok_ bool; var _tempNum_ float64; if _tempNum_, _ok_ = _buf["catalystId"].(float64); !_ok_ { err = errors.New("catalystId error"); return }; _v.CatalystId = int32(_tempNum_) }
+    { var _ok_ bool; var _tempNum_ float64; if _tempNum_, _ok_ = _buf["catalystNum"].(float64); !_ok_ { err = errors.New("catalystNum error"); return }; _v.CatalystNum = int32(_tempNum_) }
+     {
+                    var _arr_ []interface{}
+                    var _ok_ bool
+                    if _arr_, _ok_ = _buf["catalystWeight"].([]interface{}); !_ok_ { err = errors.New("catalystWeight error"); return }
+    
+                    _v.CatalystWeight = make([]int32, 0, len(_arr_))
+                    
+                    for _, _e_ := range _arr_ {
+                        var _list_v_ int32
+                        { var _ok_ bool; var _x_ float64; if _x_, _ok_ = _e_.(float64); !_ok_ { err = errors.New("_list_v_ error"); return }; _list_v_ = int32(_x_) }
+                        _v.CatalystWeight = append(_v.CatalystWeight, _list_v_)
+                    }
+                }
+
+    return
+}
+
@@ -0,0 +1,37 @@
+
+//------------------------------------------------------------------------------
+// <auto-generated>
+//     This code was generated by a tool.
+//     Changes to this file may cause incorrect behavior and will be lost if
+//     the code is regenerated.
+// </auto-generated>
+//------------------------------------------------------------------------------
+
+package cfg;
+
+import "errors"
+
+type IEggPity struct {
+    Id int32
+    Quality int32
+    SoftPity int32
+    SoftRate int32
+    HardPity int32
+}
+
+const TypeId_IEggPity = -1764206758
+
+func (*IEggPity) GetTypeId() int32 {
+    return -1764206758
+}
+
+func NewIEggPity(_buf map[string]interface{}) (_v *IEggPity, err error) {
+    _v = &IEggPity{}
+    { var _ok_ bool; var _tempNum_ float64; if _tempNum_, _ok_ = _buf["id"].(float64); !_ok_ { err = errors.New("id error"); return }; _v.Id = int32(_tempNum_) }
+    { var _ok_ bool; var _tempNum_ float64; if _tempNum_, _ok_ = _buf["quality"].(float64); !_ok_ { err = errors.New("quality error"); return }; _v.Quality = int32(_tempNum_) }
+    { var _ok_ bool; var _tempNum_ float64; if _tempNum_, _ok_ = _buf["softPity"].(float64); !_ok_ { err = errors.New("softPity error"); return }; _v.SoftPity = int32(_tempNum_) }
+    { var _ok_ bool; var _tempNum_ float64; if _tempNum_, _ok_ = _buf["softRate"].(float64); !_ok_ { err = errors.New("softRate error"); return }; _v.SoftRate = int32(_tempNum_) }
+    { var _ok_ bool; var _tempNum_ float64; if _tempNum_, _ok_ = _buf["hardPity"].(float64); !_ok_ { err = errors.New("hardPity error"); return }; _v.HardPity = int32(_tempNum_) }
+    return
+}
+
@@ -20,6 +20,8 @@ type IPet struct {
     Desc string
     Head string
     Body string
+    Ability int32
+    AbilityValue int32
 }
 
 const TypeId_IPet = 2254870
@@ -37,6 +39,8 @@ func NewIPet(_buf map[string]interface{}) (_v *IPet, err error) {
     { var _ok_ bool; if _v.Desc, _ok_ = _buf["desc"].(string); !_ok_ { err = errors.New("desc error"); return } }
     { var _ok_ bool; if _v.Head, _ok_ = _buf["head"].(string); !_ok_ { err = errors.New("head error"); return } }
     { var _ok_ bool; if _v.Body, _ok_ = _buf["body"].(string); !_ok_ { err = errors.New("body error"); return } }
+    { var _ok_ bool; var _tempNum_ float64; if _tempNum_, _ok_ = _buf["ability"].(float64); !_ok_ { err = errors.New("ability error"); return }; _v.Ability = int32(_tempNum_) }
+    { var _ok_ bool; var _tempNum_ float64; if _tempNum_, _ok_ = _buf["abilityValue"].(float64); !_ok_ { err = errors.New("abilityValue error"); return }; _v.AbilityValue = int32(_tempNum_) }
     return
 }
 
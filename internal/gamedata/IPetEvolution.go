@@ -0,0 +1,47 @@
+
+//------------------------------------------------------------------------------
+// <auto-generated>
+//     This code was generated by a tool.
+//     Changes to this file may cause incorrect behavior and will be lost if
+//     the code is regenerated.
+// </auto-generated>
+//------------------------------------------------------------------------------
+
+package cfg;
+
+import "errors"
+
+type IPetEvolution struct {
+    Id int32
+    PetId int32
+    Quality int32
+    Stage int32
+    MaxLevel int32
+    LevelExp int32
+    FeedExp int32
+    RoundExp int32
+    CostId int32
+    CostNum int32
+}
+
+const TypeId_IPetEvolution = 1634013189
+
+func (*IPetEvolution) GetTypeId() int32 {
+    return 1634013189
+}
+
+func NewIPetEvolution(_buf map[string]interface{}) (_v *IPetEvolution, err error) {
+    _v = &IPetEvolution{}
+    { var _ok_ bool; var _tempNum_ float64; if _tempNum_, _ok_ = _buf["id"].(float64); !_ok_ { err = errors.New("id error"); return }; _v.Id = int32(_tempNum_) }
+    { var _ok_ bool; var _tempNum_ float64; if _tempNum_, _ok_ = _buf["petId"].(float64); !_ok_ { err = errors.New("petId error"); return }; _v.PetId = int32(_tempNum_) }
+    { var _ok_ bool; var _tempNum_ float64; if _tempNum_, _ok_ = _buf["quality"].(float64); !_ok_ { err = errors.New("quality error"); return }; _v.Quality = int32(_tempNum_) }
+    { var _ok_ bool; var _tempNum_ float64; if _tempNum_, _ok_ = _buf["stage"].(float64); !_ok_ { err = errors.New("stage error"); return }; _v.Stage = int32(_tempNum_) }
+    { var _ok_ bool; var _tempNum_ float64; if _tempNum_, _ok_ = _buf["maxLevel"].(float64); !_ok_ { err = errors.New("maxLevel error"); return }; _v.MaxLevel = int32(_tempNum_) }
+    { var _ok_ bool; var _tempNum_ float64; if _tempNum_, _ok_ = _buf["levelExp"].(float64); !_ok_ { err = errors.New("levelExp error"); return }; _v.LevelExp = int32(_tempNum_) }
+    { var _ok_ bool; var _tempNum_ float64; if _tempNum_, _ok_ = _buf["feedExp"].(float64); !_ok_ { err = errors.New("feedExp error"); return }; _v.FeedExp = int32(_tempNum_) }
+    { var _ok_ bool; var _tempNum_ float64; if _tempNum_, _ok_ = _buf["roundExp"].(float64); !_ok_ { err = errors.New("roundExp error"); return }; _v.RoundExp = int32(_tempNum_) }
+    { var _ok_ bool; var _tempNum_ float64; if _tempNum_, _ok_ = _buf["costId"].(float64); !_ok_ { err = errors.New("costId error"); return }; _v.CostId = int32(_tempNum_) }
+    { var _ok_ bool; var _tempNum_ float64; if _tempNum_, _ok_ = _buf["costNum"].(float64); !_ok_ { err = errors.New("costNum error"); return }; _v.CostNum = int32(_tempNum_) }
+    return
+}
+
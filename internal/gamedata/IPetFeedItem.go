@@ -0,0 +1,31 @@
+
+//------------------------------------------------------------------------------
+// <auto-generated>
+//     This code was generated by a tool.
+//     Changes to this file may cause incorrect behavior and will be lost if
+//     the code is regenerated.
+// </auto-generated>
+//------------------------------------------------------------------------------
+
+package cfg;
+
+import "errors"
+
+type IPetFeedItem struct {
+    Id int32
+    Exp int32
+}
+
+const TypeId_IPetFeedItem = 1098984455
+
+func (*IPetFeedItem) GetTypeId() int32 {
+    return 1098984455
+}
+
+func NewIPetFeedItem(_buf map[string]interface{}) (_v *IPetFeedItem, err error) {
+    _v = &IPetFeedItem{}
+    { var _ok_ bool; var _tempNum_ float64; if _tempNum_, _ok_ = _buf["id"].(float64); !_ok_ { err = errors.New("id error"); return }; _v.Id = int32(_tempNum_) }
+    { var _ok_ bool; var _tempNum_ float64; if _tempNum_, _ok_ = _buf["exp"].(float64); !_ok_ { err = errors.New("exp error"); return }; _v.Exp = int32(_tempNum_) }
+    return
+}
+
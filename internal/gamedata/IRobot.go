@@ -0,0 +1,46 @@
+
+//------------------------------------------------------------------------------
+// <auto-generated>
+//     This code was generated by a tool.
+//     Changes to this file may cause incorrect behavior and will be lost if
+//     the code is regenerated.
+// </auto-generated>
+//------------------------------------------------------------------------------
+
+package cfg;
+
+import "errors"
+
+type IRobot struct {
+    Id int32
+    Name string
+    Pets []int32
+}
+
+const TypeId_IRobot = -2125906015
+
+func (*IRobot) GetTypeId() int32 {
+    return -2125906015
+}
+
+func NewIRobot(_buf map[string]interface{}) (_v *IRobot, err error) {
+    _v = &IRobot{}
+    { var _ok_ bool; var _tempNum_ float64; if _tempNum_, _ok_ = _buf["id"].(float64); !_ok_ { err = errors.New("id error"); return }; _v.Id = int32(_tempNum_) }
+    { var _ok_ bool; if _v.Name, _ok_ = _buf["name"].(string); !_ok_ { err = errors.New("name error"); return } }
+     {
+                    var _arr_ []interface{}
+                    var _ok_ bool
+                    if _arr_, _ok_ = _buf["pets"].([]interface{}); !_ok_ { err = errors.New("pets error"); return }
+    
+                    _v.Pets = make([]int32, 0, len(_arr_))
+                    
+                    for _, _e_ := range _arr_ {
+                        var _list_v_ int32
+                        { var _ok_ bool; var _x_ float64; if _x_, _ok_ = _e_.(float64); !_ok_ { err = errors.New("_list_v_ error"); return }; _list_v_ = int32(_x_) }
+                        _v.Pets = append(_v.Pets, _list_v_)
+                    }
+                }
+
+    return
+}
+
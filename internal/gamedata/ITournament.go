@@ -0,0 +1,58 @@
+
+//------------------------------------------------------------------------------
+// <auto-generated>
+//     This code was generated by a tool.
+//     Changes to this file may cause incorrect behavior and will be lost if
+//     the code is regenerated.
+// </auto-generated>
+//------------------------------------------------------------------------------
+
+package cfg;
+
+import "errors"
+
+type ITournament struct {
+    Id int32
+    Name string
+    BattleId int32
+    FirstStart int32
+    Interval int32
+    RegisterTime int32
+    MinPlayer int32
+    MaxPlayer int32
+    Need []*GlobalItemData
+}
+
+const TypeId_ITournament = -1330958574
+
+func (*ITournament) GetTypeId() int32 {
+    return -1330958574
+}
+
+func NewITournament(_buf map[string]interface{}) (_v *ITournament, err error) {
+    _v = &ITournament{}
+    { var _ok_ bool; var _tempNum_ float64; if _tempNum_, _ok_ = _buf["id"].(float64); !_ok_ { err = errors.New("id error"); return }; _v.Id = int32(_tempNum_) }
+    { var _ok_ bool; if _v.Name, _ok_ = _buf["name"].(string); !_ok_ { err = errors.New("name error"); return } }
+    { var _ok_ bool; var _tempNum_ float64; if _tempNum_, _ok_ = _buf["battleId"].(float64); !_ok_ { err = errors.New("battleId error"); return }; _v.BattleId = int32(_tempNum_) }
+    { var _ok_ bool; var _tempNum_ float64; if _tempNum_, _ok_ = _buf["firstStart"].(float64); !_ok_ { err = errors.New("firstStart error"); return }; _v.FirstStart = int32(_tempNum_) }
+    { var _ok_ bool; var _tempNum_ float64; if _tempNum_, _ok_ = _buf["interval"].(float64); !_ok_ { err = errors.New("interval error"); return }; _v.Interval = int32(_tempNum_) }
+    { var _ok_ bool; var _tempNum_ float64; if _tempNum_, _ok_ = _buf["registerTime"].(float64); !_ok_ { err = errors.New("registerTime error"); return }; _v.RegisterTime = int32(_tempNum_) }
+    { var _ok_ bool; var _tempNum_ float64; if _tempNum_, _ok_ = _buf["minPlayer"].(float64); !_ok_ { err = errors.New("minPlayer error"); return }; _v.MinPlayer = int32(_tempNum_) }
+    { var _ok_ bool; var _tempNum_ float64; if _tempNum_, _ok_ = _buf["maxPlayer"].(float64); !_ok_ { err = errors.New("maxPlayer error"); return }; _v.MaxPlayer = int32(_tempNum_) }
+     {
+                    var _arr_ []interface{}
+                    var _ok_ bool
+                    if _arr_, _ok_ = _buf["need"].([]interface{}); !_ok_ { err = errors.New("need error"); return }
+    
+                    _v.Need = make([]*GlobalItemData, 0, len(_arr_))
+                    
+                    for _, _e_ := range _arr_ {
+                        var _list_v_ *GlobalItemData
+                        { var _ok_ bool; var _x_ map[string]interface{}; if _x_, _ok_ = _e_.(map[string]interface{}); !_ok_ { err = errors.New("_list_v_ error"); return }; if _list_v_, err = NewGlobalItemData(_x_); err != nil { return } }
+                        _v.Need = append(_v.Need, _list_v_)
+                    }
+                }
+
+    return
+}
+
@@ -0,0 +1,50 @@
+
+//------------------------------------------------------------------------------
+// <auto-generated>
+//     This code was generated by a tool.
+//     Changes to this file may cause incorrect behavior and will be lost if
+//     the code is regenerated.
+// </auto-generated>
+//------------------------------------------------------------------------------
+
+package cfg;
+
+import "errors"
+
+type ITournamentPrize struct {
+    Id int32
+    TournamentId int32
+    RankMin int32
+    RankMax int32
+    Reward []*GlobalItemData
+}
+
+const TypeId_ITournamentPrize = 1498296352
+
+func (*ITournamentPrize) GetTypeId() int32 {
+    return 1498296352
+}
+
+func NewITournamentPrize(_buf map[string]interface{}) (_v *ITournamentPrize, err error) {
+    _v = &ITournamentPrize{}
+    { var _ok_ bool; var _tempNum_ float64; if _tempNum_, _ok_ = _buf["id"].(float64); !_ok_ { err = errors.New("id error"); return }; _v.Id = int32(_tempNum_) }
+    { var _ok_ bool; var _tempNum_ float64; if _tempNum_, _ok_ = _buf["tournamentId"].(float64); !_ok_ { err = errors.New("tournamentId error"); return }; _v.TournamentId = int32(_tempNum_) }
+    { var _ok_ bool; var _tempNum_ float64; if _tempNum_, _ok_ = _buf["rankMin"].(float64); !_ok_ { err = errors.New("rankMin error"); return }; _v.RankMin = int32(_tempNum_) }
+    { var _ok_ bool; var _tempNum_ float64; if _tempNum_, _ok_ = _buf["rankMax"].(float64); !_ok_ { err = errors.New("rankMax error"); return }; _v.RankMax = int32(_tempNum_) }
+     {
+                    var _arr_ []interface{}
+                    var _ok_ bool
+                    if _arr_, _ok_ = _buf["reward"].([]interface{}); !_ok_ { err = errors.New("reward error"); return }
+    
+                    _v.Reward = make([]*GlobalItemData, 0, len(_arr_))
+                    
+                    for _, _e_ := range _arr_ {
+                        var _list_v_ *GlobalItemData
+                        { var _ok_ bool; var _x_ map[string]interface{}; if _x_, _ok_ = _e_.(map[string]interface{}); !_ok_ { err = errors.New("_list_v_ error"); return }; if _list_v_, err = NewGlobalItemData(_x_); err != nil { return } }
+                        _v.Reward = append(_v.Reward, _list_v_)
+                    }
+                }
+
+    return
+}
+
@@ -0,0 +1,29 @@
+//------------------------------------------------------------------------------
+// <auto-generated>
+//     This code was generated by a tool.
+//     Changes to this file may cause incorrect behavior and will be lost if
+//     the code is regenerated.
+// </auto-generated>
+//------------------------------------------------------------------------------
+
+package cfg;
+
+const (
+    /**
+     * 无
+     */
+    PetAbilityType_None = 0;
+    /**
+     * 被杀死时存活一次
+     */
+    PetAbilityType_Survive = 1;
+    /**
+     * 奖金加成，万分比
+     */
+    PetAbilityType_Bonus = 2;
+    /**
+     * 下注前看到一个安全的格子
+     */
+    PetAbilityType_Scout = 3;
+)
+
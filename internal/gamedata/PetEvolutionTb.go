@@ -0,0 +1,45 @@
+
+//------------------------------------------------------------------------------
+// <auto-generated>
+//     This code was generated by a tool.
+//     Changes to this file may cause incorrect behavior and will be lost if
+//     the code is regenerated.
+// </auto-generated>
+//------------------------------------------------------------------------------
+
+package cfg;
+
+
+type PetEvolutionTb struct {
+    _dataMap map[int32]*IPetEvolution
+    _dataList []*IPetEvolution
+}
+
+func NewPetEvolutionTb(_buf []map[string]interface{}) (*PetEvolutionTb, error) {
+    _dataList := make([]*IPetEvolution, 0, len(_buf))
+    dataMap := make(map[int32]*IPetEvolution)
+
+    for _, _ele_ := range _buf {
+        if _v, err2 := NewIPetEvolution(_ele_); err2 != nil {
+            return nil, err2
+        } else {
+            _dataList = append(_dataList, _v)
+            dataMap[_v.Id] = _v
+        }
+    }
+    return &PetEvolutionTb{_dataList:_dataList, _dataMap:dataMap}, nil
+}
+
+func (table *PetEvolutionTb) GetDataMap() map[int32]*IPetEvolution {
+    return table._dataMap
+}
+
+func (table *PetEvolutionTb) GetDataList() []*IPetEvolution {
+    return table._dataList
+}
+
+func (table *PetEvolutionTb) Get(key int32) *IPetEvolution {
+    return table._dataMap[key]
+}
+
+
@@ -0,0 +1,45 @@
+
+//------------------------------------------------------------------------------
+// <auto-generated>
+//     This code was generated by a tool.
+//     Changes to this file may cause incorrect behavior and will be lost if
+//     the code is regenerated.
+// </auto-generated>
+//------------------------------------------------------------------------------
+
+package cfg;
+
+
+type PetFeedItemTb struct {
+    _dataMap map[int32]*IPetFeedItem
+    _dataList []*IPetFeedItem
+}
+
+func NewPetFeedItemTb(_buf []map[string]interface{}) (*PetFeedItemTb, error) {
+    _dataList := make([]*IPetFeedItem, 0, len(_buf))
+    dataMap := make(map[int32]*IPetFeedItem)
+
+    for _, _ele_ := range _buf {
+        if _v, err2 := NewIPetFeedItem(_ele_); err2 != nil {
+            return nil, err2
+        } else {
+            _dataList = append(_dataList, _v)
+            dataMap[_v.Id] = _v
+        }
+    }
+    return &PetFeedItemTb{_dataList:_dataList, _dataMap:dataMap}, nil
+}
+
+func (table *PetFeedItemTb) GetDataMap() map[int32]*IPetFeedItem {
+    return table._dataMap
+}
+
+func (table *PetFeedItemTb) GetDataList() []*IPetFeedItem {
+    return table._dataList
+}
+
+func (table *PetFeedItemTb) Get(key int32) *IPetFeedItem {
+    return table._dataMap[key]
+}
+
+
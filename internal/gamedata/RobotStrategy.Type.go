@@ -0,0 +1,33 @@
+//------------------------------------------------------------------------------
+// <auto-generated>
+//     This code was generated by a tool.
+//     Changes to this file may cause incorrect behavior and will be lost if
+//     the code is regenerated.
+// </auto-generated>
+//------------------------------------------------------------------------------
+
+package cfg;
+
+const (
+    /**
+     * 随机下注
+     */
+    RobotStrategyType_Random = 0;
+    /**
+     * 跟随人多的格子
+     */
+    RobotStrategyType_Herd = 1;
+    /**
+     * 避开人多的格子
+     */
+    RobotStrategyType_Avoid = 2;
+    /**
+     * 回合快结束时下注
+     */
+    RobotStrategyType_Late = 3;
+    /**
+     * 中途可能退出战斗
+     */
+    RobotStrategyType_Quit = 4;
+)
+
@@ -0,0 +1,45 @@
+
+//------------------------------------------------------------------------------
+// <auto-generated>
+//     This code was generated by a tool.
+//     Changes to this file may cause incorrect behavior and will be lost if
+//     the code is regenerated.
+// </auto-generated>
+//------------------------------------------------------------------------------
+
+package cfg;
+
+
+type RobotTb struct {
+    _dataMap map[int32]*IRobot
+    _dataList []*IRobot
+}
+
+func NewRobotTb(_buf []map[string]interface{}) (*RobotTb, error) {
+    _dataList := make([]*IRobot, 0, len(_buf))
+    dataMap := make(map[int32]*IRobot)
+
+    for _, _ele_ := range _buf {
+        if _v, err2 := NewIRobot(_ele_); err2 != nil {
+            return nil, err2
+        } else {
+            _dataList = append(_dataList, _v)
+            dataMap[_v.Id] = _v
+        }
+    }
+    return &RobotTb{_dataList:_dataList, _dataMap:dataMap}, nil
+}
+
+func (table *RobotTb) GetDataMap() map[int32]*IRobot {
+    return table._dataMap
+}
+
+func (table *RobotTb) GetDataList() []*IRobot {
+    return table._dataList
+}
+
+func (table *RobotTb) Get(key int32) *IRobot {
+    return table._dataMap[key]
+}
+
+
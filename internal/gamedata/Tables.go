@@ -40,6 +40,13 @@ type Tables struct {
     ReSignTb *ReSignTb
     PassPortIssueTb *PassPortIssueTb
     PassPortRewardTb *PassPortRewardTb
+    EggPityTb *EggPityTb
+    EggFusionTb *EggFusionTb
+    PetEvolutionTb *PetEvolutionTb
+    PetFeedItemTb *PetFeedItemTb
+    TournamentTb *TournamentTb
+    TournamentPrizeTb *TournamentPrizeTb
+    RobotTb *RobotTb
 }
 
 func NewTables(loader JsonLoader) (*Tables, error) {
@@ -215,6 +222,48 @@ func NewTables(loader JsonLoader) (*Tables, error) {
     if tables.PassPortRewardTb, err = NewPassPortRewardTb(buf) ; err != nil {
         return nil, err
     }
+    if buf, err = loader("EggPityTb") ; err != nil {
+        return nil, err
+    }
+    if tables.EggPityTb, err = NewEggPityTb(buf) ; err != nil {
+        return nil, err
+    }
+    if buf, err = loader("EggFusionTb") ; err != nil {
+        return nil, err
+    }
+    if tables.EggFusionTb, err = NewEggFusionTb(buf) ; err != nil {
+        return nil, err
+    }
+    if buf, err = loader("PetEvolutionTb") ; err != nil {
+        return nil, err
+    }
+    if tables.PetEvolutionTb, err = NewPetEvolutionTb(buf) ; err != nil {
+        return nil, err
+    }
+    if buf, err = loader("PetFeedItemTb") ; err != nil {
+        return nil, err
+    }
+    if tables.PetFeedItemTb, err = NewPetFeedItemTb(buf) ; err != nil {
+        return nil, err
+    }
+    if buf, err = loader("TournamentTb") ; err != nil {
+        return nil, err
+    }
+    if tables.TournamentTb, err = NewTournamentTb(buf) ; err != nil {
+        return nil, err
+    }
+    if buf, err = loader("TournamentPrizeTb") ; err != nil {
+        return nil, err
+    }
+    if tables.TournamentPrizeTb, err = NewTournamentPrizeTb(buf) ; err != nil {
+        return nil, err
+    }
+    if buf, err = loader("RobotTb") ; err != nil {
+        return nil, err
+    }
+    if tables.RobotTb, err = NewRobotTb(buf) ; err != nil {
+        return nil, err
+    }
     return tables, nil
 }
 
@@ -0,0 +1,45 @@
+
+//------------------------------------------------------------------------------
+// <auto-generated>
+//     This code was generated by a tool.
+//     Changes to this file may cause incorrect behavior and will be lost if
+//     the code is regenerated.
+// </auto-generated>
+//------------------------------------------------------------------------------
+
+package cfg;
+
+
+type TournamentPrizeTb struct {
+    _dataMap map[int32]*ITournamentPrize
+    _dataList []*ITournamentPrize
+}
+
+func NewTournamentPrizeTb(_buf []map[string]interface{}) (*TournamentPrizeTb, error) {
+    _dataList := make([]*ITournamentPrize, 0, len(_buf))
+    dataMap := make(map[int32]*ITournamentPrize)
+
+    for _, _ele_ := range _buf {
+        if _v, err2 := NewITournamentPrize(_ele_); err2 != nil {
+            return nil, err2
+        } else {
+            _dataList = append(_dataList, _v)
+            dataMap[_v.Id] = _v
+        }
+    }
+    return &TournamentPrizeTb{_dataList:_dataList, _dataMap:dataMap}, nil
+}
+
+func (table *TournamentPrizeTb) GetDataMap() map[int32]*ITournamentPrize {
+    return table._dataMap
+}
+
+func (table *TournamentPrizeTb) GetDataList() []*ITournamentPrize {
+    return table._dataList
+}
+
+func (table *TournamentPrizeTb) Get(key int32) *ITournamentPrize {
+    return table._dataMap[key]
+}
+
+
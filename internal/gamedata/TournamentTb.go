@@ -0,0 +1,45 @@
+
+//------------------------------------------------------------------------------
+// <auto-generated>
+//     This code was generated by a tool.
+//     Changes to this file may cause incorrect behavior and will be lost if
+//     the code is regenerated.
+// </auto-generated>
+//------------------------------------------------------------------------------
+
+package cfg;
+
+
+type TournamentTb struct {
+    _dataMap map[int32]*ITournament
+    _dataList []*ITournament
+}
+
+func NewTournamentTb(_buf []map[string]interface{}) (*TournamentTb, error) {
+    _dataList := make([]*ITournament, 0, len(_buf))
+    dataMap := make(map[int32]*ITournament)
+
+    for _, _ele_ := range _buf {
+        if _v, err2 := NewITournament(_ele_); err2 != nil {
+            return nil, err2
+        } else {
+            _dataList = append(_dataList, _v)
+            dataMap[_v.Id] = _v
+        }
+    }
+    return &TournamentTb{_dataList:_dataList, _dataMap:dataMap}, nil
+}
+
+func (table *TournamentTb) GetDataMap() map[int32]*ITournament {
+    return table._dataMap
+}
+
+func (table *TournamentTb) GetDataList() []*ITournament {
+    return table._dataList
+}
+
+func (table *TournamentTb) Get(key int32) *ITournament {
+    return table._dataMap[key]
+}
+
+
@@ -0,0 +1,32 @@
+package gamedatax
+
+import (
+	cfg "eggServer/internal/gamedata"
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// Load 读取目录下的所有配置表并校验
+func Load(dir string) (*cfg.Tables, error) {
+	tables, err := cfg.NewTables(func(file string) ([]map[string]interface{}, error) {
+		bytes, err := os.ReadFile(filepath.Join(dir, file+".json"))
+		if err != nil {
+			return nil, err
+		}
+
+		jsonData := make([]map[string]interface{}, 0)
+		if err := json.Unmarshal(bytes, &jsonData); err != nil {
+			return nil, err
+		}
+		return jsonData, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := Validate(tables); err != nil {
+		return nil, err
+	}
+	return tables, nil
+}
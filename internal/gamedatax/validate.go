@@ -0,0 +1,596 @@
+package gamedatax
+
+import (
+	cfg "eggServer/internal/gamedata"
+	"fmt"
+	"strings"
+)
+
+// Violation 不符合规则的配置
+type Violation struct {
+	Table string
+	Id    int32
+	Field string
+	Msg   string
+}
+
+func (v *Violation) String() string {
+	return fmt.Sprintf("%s[%d].%s: %s", v.Table, v.Id, v.Field, v.Msg)
+}
+
+// ValidateError 配置表校验失败，包含所有不符合规则的配置
+type ValidateError struct {
+	Violations []*Violation
+}
+
+func (e *ValidateError) Error() string {
+	lines := make([]string, 0, len(e.Violations)+1)
+	lines = append(lines, fmt.Sprintf("gamedata: %d violations", len(e.Violations)))
+	for _, v := range e.Violations {
+		lines = append(lines, v.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+type report struct {
+	tables     *cfg.Tables
+	violations []*Violation
+}
+
+func (r *report) add(table string, id int32, field string, format string, args ...interface{}) {
+	r.violations = append(r.violations, &Violation{Table: table, Id: id, Field: field, Msg: fmt.Sprintf(format, args...)})
+}
+
+// 校验规则
+var rules = []func(r *report){
+	checkGlobal,
+	checkItemRefs,
+	checkEgg,
+	checkEggOpen,
+	checkEggPity,
+	checkEggFusion,
+	checkPet,
+	checkBattle,
+	checkRobot,
+	checkTournament,
+	checkTask,
+	checkDailyShop,
+	checkGuide,
+	checkPassPort,
+}
+
+// Check 运行所有规则，返回所有不符合规则的配置
+func Check(tables *cfg.Tables) []*Violation {
+	r := &report{tables: tables}
+	for _, rule := range rules {
+		rule(r)
+	}
+	return r.violations
+}
+
+// Validate 校验配置表的内容
+func Validate(tables *cfg.Tables) error {
+	if violations := Check(tables); len(violations) > 0 {
+		return &ValidateError{Violations: violations}
+	}
+	return nil
+}
+
+// 全局配置直接按下标读取
+func checkGlobal(r *report) {
+	list := r.tables.GlobalTb.GetDataList()
+	if len(list) == 0 {
+		r.add("GlobalTb", 0, "", "table is empty")
+		return
+	}
+
+	global := list[0]
+	r.checkRange("GlobalTb", 0, "VitMinMax", global.VitMinMax)
+	r.checkRange("GlobalTb", 0, "GoldMinmax", global.GoldMinmax)
+}
+
+// 最小值和最大值
+func (r *report) checkRange(table string, id int32, field string, v []int32) {
+	if len(v) != 2 {
+		r.add(table, id, field, "want 2 values, got %d", len(v))
+		return
+	}
+	if v[0] > v[1] {
+		r.add(table, id, field, "min %d > max %d", v[0], v[1])
+	}
+}
+
+// 奖励和消耗引用的道具或宠物必须存在，optional表示可以为空（全部为0）
+func (r *report) checkItem(table string, id int32, field string, item *cfg.GlobalItemData, optional bool) {
+	if item == nil || (item.Type == 0 && item.Id == 0 && item.Num == 0) {
+		if !optional {
+			r.add(table, id, field, "is empty")
+		}
+		return
+	}
+
+	if item.Num <= 0 {
+		r.add(table, id, field, "num %d <= 0", item.Num)
+	}
+	r.checkArticle(table, id, field, item.Type, item.Id)
+}
+
+// 按奖励类型检查道具或宠物是否存在
+func (r *report) checkArticle(table string, id int32, field string, rewardType int32, articleId int32) {
+	switch rewardType {
+	case cfg.RewardType_Item:
+		if r.tables.ItemTb.Get(articleId) == nil {
+			r.add(table, id, field, "item %d not found in ItemTb", articleId)
+		}
+	case cfg.RewardType_Pet:
+		if r.tables.PetTb.Get(articleId) == nil {
+			r.add(table, id, field, "pet %d not found in PetTb", articleId)
+		}
+	default:
+		r.add(table, id, field, "unknown reward type %d", rewardType)
+	}
+}
+
+func checkItemRefs(r *report) {
+	for _, v := range r.tables.GlobalTb.GetDataList() {
+		r.checkItem("GlobalTb", 0, "ShareEggReward", v.ShareEggReward, false)
+		r.checkItem("GlobalTb", 0, "InvitedReward", v.InvitedReward, false)
+		r.checkItem("GlobalTb", 0, "SharePetReward", v.SharePetReward, false)
+		for i, item := range v.ShopRefresh {
+			r.checkItem("GlobalTb", 0, fmt.Sprintf("ShopRefresh[%d]", i), item, true)
+		}
+	}
+
+	for _, v := range r.tables.DailyShopTb.GetDataList() {
+		r.checkItem("DailyShopTb", v.Id, "Item", v.Item, false)
+		r.checkItem("DailyShopTb", v.Id, "Price", v.Price, true)
+	}
+	for _, v := range r.tables.OrdinaryShopTb.GetDataList() {
+		r.checkItem("OrdinaryShopTb", v.Id, "Item", v.Item, false)
+		r.checkItem("OrdinaryShopTb", v.Id, "Price", v.Price, true)
+	}
+	for _, v := range r.tables.DiamondShopTb.GetDataList() {
+		r.checkItem("DiamondShopTb", v.Id, "Item", v.Item, false)
+		r.checkItem("DiamondShopTb", v.Id, "Price", v.Price, true)
+	}
+	for _, v := range r.tables.SpecialShopTb.GetDataList() {
+		r.checkItem("SpecialShopTb", v.Id, "Item", v.Item, false)
+		r.checkItem("SpecialShopTb", v.Id, "Price", v.Price, true)
+	}
+
+	for _, v := range r.tables.SignTb.GetDataList() {
+		r.checkItem("SignTb", v.Id, "Reward", v.Reward, false)
+	}
+	for _, v := range r.tables.ReSignTb.GetDataList() {
+		r.checkItem("ReSignTb", v.Id, "Cost", v.Cost, false)
+	}
+	for _, v := range r.tables.TaskTb.GetDataList() {
+		r.checkItem("TaskTb", v.TaskId, "Reward", v.Reward, true)
+	}
+	for _, v := range r.tables.PassPortRewardTb.GetDataList() {
+		r.checkItem("PassPortRewardTb", v.Id, "OrdinaryReward", v.OrdinaryReward, true)
+		r.checkItem("PassPortRewardTb", v.Id, "DeluxeReward", v.DeluxeReward, true)
+	}
+	for _, v := range r.tables.BattleConfigTb.GetDataList() {
+		// 报名消耗的数量可以为0，表示免费
+		for i, item := range v.Need {
+			field := fmt.Sprintf("Need[%d]", i)
+			if item.Num < 0 {
+				r.add("BattleConfigTb", v.Id, field, "num %d < 0", item.Num)
+			}
+			r.checkArticle("BattleConfigTb", v.Id, field, item.Type, item.Id)
+		}
+		for i, item := range v.Reward {
+			r.checkItem("BattleConfigTb", v.Id, fmt.Sprintf("Reward[%d]", i), item, false)
+		}
+	}
+}
+
+// 蛋的部件按品质随机，不随机的品质不配置，配置的品质都要有对应的部件
+func checkEgg(r *report) {
+	qualities := make(map[int32]map[int32]bool)
+	for _, v := range r.tables.EggTb.GetDataList() {
+		if v.PartType < cfg.EggPartType_Part1 || v.PartType > cfg.EggPartType_Part3 {
+			r.add("EggTb", v.Id, "PartType", "unknown part type %d", v.PartType)
+			continue
+		}
+		if qualities[v.PartType] == nil {
+			qualities[v.PartType] = make(map[int32]bool)
+		}
+		qualities[v.PartType][v.Quality] = true
+	}
+
+	checkPart := func(table string, partType int32, id int32, quality int32, weight int32, total *int32) {
+		if weight <= 0 {
+			r.add(table, id, "Weight", "weight %d <= 0", weight)
+		}
+		if !qualities[partType][quality] {
+			r.add(table, id, "Quality", "no part of quality %d in EggTb", quality)
+		}
+		*total += weight
+	}
+
+	var total1, total2, total3 int32
+	for _, v := range r.tables.EggPart1Tb.GetDataList() {
+		checkPart("EggPart1Tb", cfg.EggPartType_Part1, v.Id, v.Quality, v.Weight, &total1)
+	}
+	for _, v := range r.tables.EggPart2Tb.GetDataList() {
+		checkPart("EggPart2Tb", cfg.EggPartType_Part2, v.Id, v.Quality, v.Weight, &total2)
+	}
+	for _, v := range r.tables.EggPart3Tb.GetDataList() {
+		checkPart("EggPart3Tb", cfg.EggPartType_Part3, v.Id, v.Quality, v.Weight, &total3)
+	}
+
+	if total1 <= 0 {
+		r.add("EggPart1Tb", 0, "Weight", "total weight %d <= 0", total1)
+	}
+	if total2 <= 0 {
+		r.add("EggPart2Tb", 0, "Weight", "total weight %d <= 0", total2)
+	}
+	if total3 <= 0 {
+		r.add("EggPart3Tb", 0, "Weight", "total weight %d <= 0", total3)
+	}
+}
+
+// 开蛋的分组和奖励
+func checkEggOpen(r *report) {
+	groupWeight := make(map[int32]int32)
+	for _, v := range r.tables.EggOpenWeightTb.GetDataList() {
+		if v.Weight <= 0 {
+			r.add("EggOpenWeightTb", v.Id, "Weight", "weight %d <= 0", v.Weight)
+		}
+		if v.ArticleNum <= 0 {
+			r.add("EggOpenWeightTb", v.Id, "ArticleNum", "num %d <= 0", v.ArticleNum)
+		}
+		// 类型为0表示没有奖励
+		if v.ItemType != 0 {
+			r.checkArticle("EggOpenWeightTb", v.Id, "ArticleId", v.ItemType, v.ArticleId)
+		} else if v.ArticleId != 0 {
+			r.add("EggOpenWeightTb", v.Id, "ArticleId", "article %d with empty reward type", v.ArticleId)
+		}
+		groupWeight[v.GroupId] += v.Weight
+	}
+
+	for _, v := range r.tables.EggScoreTb.GetDataList() {
+		// 只有一个值的是按下标开蛋使用的，不参与按分数匹配
+		if len(v.EggScore) != 1 && len(v.EggScore) != 2 {
+			r.add("EggScoreTb", v.Id, "EggScore", "want 1 or 2 values, got %d", len(v.EggScore))
+		} else if len(v.EggScore) == 2 && v.EggScore[0] >= v.EggScore[1] {
+			r.add("EggScoreTb", v.Id, "EggScore", "min %v >= max %v", v.EggScore[0], v.EggScore[1])
+		}
+
+		if len(v.OpenGroupId) != len(v.GroupPb) {
+			r.add("EggScoreTb", v.Id, "GroupPb", "%d weights for %d groups", len(v.GroupPb), len(v.OpenGroupId))
+		}
+
+		var total int32
+		for i, groupId := range v.OpenGroupId {
+			if groupWeight[groupId] <= 0 {
+				r.add("EggScoreTb", v.Id, "OpenGroupId", "group %d not found in EggOpenWeightTb", groupId)
+			}
+			if i < len(v.GroupPb) {
+				if v.GroupPb[i] <= 0 {
+					r.add("EggScoreTb", v.Id, "GroupPb", "weight %d <= 0", v.GroupPb[i])
+				}
+				total += v.GroupPb[i]
+			}
+		}
+		if total <= 0 {
+			r.add("EggScoreTb", v.Id, "GroupPb", "total weight %d <= 0", total)
+		}
+	}
+}
+
+// 开蛋保底，每个品质只能有一条，软保底之后每次增加的概率为万分比
+func checkEggPity(r *report) {
+	qualities := make(map[int32]bool)
+	for _, v := range r.tables.EggPityTb.GetDataList() {
+		if r.tables.QualityTb.Get(v.Quality) == nil {
+			r.add("EggPityTb", v.Id, "Quality", "quality %d not found in QualityTb", v.Quality)
+		}
+		if qualities[v.Quality] {
+			r.add("EggPityTb", v.Id, "Quality", "duplicate quality %d", v.Quality)
+		}
+		qualities[v.Quality] = true
+
+		if v.SoftPity < 0 {
+			r.add("EggPityTb", v.Id, "SoftPity", "count %d < 0", v.SoftPity)
+		}
+		if v.SoftRate < 0 || v.SoftRate > 10000 {
+			r.add("EggPityTb", v.Id, "SoftRate", "rate %d out of [0, 10000]", v.SoftRate)
+		}
+		if v.HardPity <= v.SoftPity {
+			r.add("EggPityTb", v.Id, "HardPity", "count %d <= softPity %d", v.HardPity, v.SoftPity)
+		}
+	}
+}
+
+// 合成蛋按输入蛋的总评分选择配置，品质权重的下标为品质，每个部件都要有可以随机的品质
+func checkEggFusion(r *report) {
+	qualities := make(map[int32]map[int32]bool)
+	for _, v := range r.tables.EggTb.GetDataList() {
+		if qualities[v.PartType] == nil {
+			qualities[v.PartType] = make(map[int32]bool)
+		}
+		qualities[v.PartType][v.Quality] = true
+	}
+
+	checkWeight := func(id int32, field string, weights []int32) {
+		for _, partType := range []int32{cfg.EggPartType_Part1, cfg.EggPartType_Part2, cfg.EggPartType_Part3} {
+			var total int32
+			for quality, w := range weights {
+				if w < 0 {
+					r.add("EggFusionTb", id, field, "weight %d < 0", w)
+				}
+				if w > 0 && qualities[partType][int32(quality)] {
+					total += w
+				}
+			}
+			if total <= 0 {
+				r.add("EggFusionTb", id, field, "no quality of part %d can be rolled", partType)
+			}
+		}
+	}
+
+	for _, v := range r.tables.EggFusionTb.GetDataList() {
+		if len(v.EggScore) != 2 {
+			r.add("EggFusionTb", v.Id, "EggScore", "want 2 values, got %d", len(v.EggScore))
+		} else if v.EggScore[0] >= v.EggScore[1] {
+			r.add("EggFusionTb", v.Id, "EggScore", "min %v >= max %v", v.EggScore[0], v.EggScore[1])
+		}
+
+		checkWeight(v.Id, "QualityWeight", v.QualityWeight)
+
+		// 催化剂为0表示不能使用
+		if v.CatalystId != 0 {
+			r.checkArticle("EggFusionTb", v.Id, "CatalystId", cfg.RewardType_Item, v.CatalystId)
+			if v.CatalystNum <= 0 {
+				r.add("EggFusionTb", v.Id, "CatalystNum", "num %d <= 0", v.CatalystNum)
+			}
+			checkWeight(v.Id, "CatalystWeight", v.CatalystWeight)
+		}
+	}
+}
+
+// 宠物进化配置按宠物或品质查找，阶段从0开始连续，每只宠物都要有阶段0，宠物能力的类型和数值
+func checkPet(r *report) {
+	petStages := make(map[int32]map[int32]bool)
+	qualityStages := make(map[int32]map[int32]bool)
+	for _, v := range r.tables.PetEvolutionTb.GetDataList() {
+		stages := qualityStages
+		key := v.Quality
+		if v.PetId != 0 {
+			if r.tables.PetTb.Get(v.PetId) == nil {
+				r.add("PetEvolutionTb", v.Id, "PetId", "pet %d not found in PetTb", v.PetId)
+			}
+			stages = petStages
+			key = v.PetId
+		} else if r.tables.QualityTb.Get(v.Quality) == nil {
+			r.add("PetEvolutionTb", v.Id, "Quality", "quality %d not found in QualityTb", v.Quality)
+		}
+		if stages[key] == nil {
+			stages[key] = make(map[int32]bool)
+		}
+		if stages[key][v.Stage] {
+			r.add("PetEvolutionTb", v.Id, "Stage", "duplicate stage %d", v.Stage)
+		}
+		stages[key][v.Stage] = true
+
+		if v.MaxLevel <= 0 {
+			r.add("PetEvolutionTb", v.Id, "MaxLevel", "level %d <= 0", v.MaxLevel)
+		}
+		if v.LevelExp <= 0 {
+			r.add("PetEvolutionTb", v.Id, "LevelExp", "exp %d <= 0", v.LevelExp)
+		}
+		if v.FeedExp < 0 {
+			r.add("PetEvolutionTb", v.Id, "FeedExp", "exp %d < 0", v.FeedExp)
+		}
+		if v.RoundExp < 0 {
+			r.add("PetEvolutionTb", v.Id, "RoundExp", "exp %d < 0", v.RoundExp)
+		}
+		if v.CostId != 0 {
+			r.checkArticle("PetEvolutionTb", v.Id, "CostId", cfg.RewardType_Item, v.CostId)
+			if v.CostNum <= 0 {
+				r.add("PetEvolutionTb", v.Id, "CostNum", "num %d <= 0", v.CostNum)
+			}
+		}
+	}
+
+	for _, v := range r.tables.PetEvolutionTb.GetDataList() {
+		stages := qualityStages[v.Quality]
+		if v.PetId != 0 {
+			stages = petStages[v.PetId]
+		}
+		if v.Stage < 0 || (v.Stage > 0 && !stages[v.Stage-1]) {
+			r.add("PetEvolutionTb", v.Id, "Stage", "stage %d is not contiguous", v.Stage)
+		}
+	}
+
+	for _, v := range r.tables.PetTb.GetDataList() {
+		if !petStages[v.Id][0] && !qualityStages[v.Quality][0] {
+			r.add("PetTb", v.Id, "Quality", "no stage 0 in PetEvolutionTb")
+		}
+
+		switch v.Ability {
+		case cfg.PetAbilityType_None, cfg.PetAbilityType_Survive, cfg.PetAbilityType_Scout:
+		case cfg.PetAbilityType_Bonus:
+			if v.AbilityValue <= 0 {
+				r.add("PetTb", v.Id, "AbilityValue", "bonus rate %d <= 0", v.AbilityValue)
+			}
+		default:
+			r.add("PetTb", v.Id, "Ability", "unknown ability %d", v.Ability)
+		}
+	}
+
+	for _, v := range r.tables.PetFeedItemTb.GetDataList() {
+		r.checkArticle("PetFeedItemTb", v.Id, "Id", cfg.RewardType_Item, v.Id)
+		if v.Exp <= 0 {
+			r.add("PetFeedItemTb", v.Id, "Exp", "exp %d <= 0", v.Exp)
+		}
+	}
+}
+
+func checkBattle(r *report) {
+	for _, v := range r.tables.BattleConfigTb.GetDataList() {
+		if v.TotalRound <= 0 {
+			r.add("BattleConfigTb", v.Id, "TotalRound", "round %d <= 0", v.TotalRound)
+		}
+		if len(v.RoundTimes) != int(v.TotalRound) {
+			r.add("BattleConfigTb", v.Id, "RoundTimes", "want %d values, got %d", v.TotalRound, len(v.RoundTimes))
+		}
+		for i, t := range v.RoundTimes {
+			if t <= 0 {
+				r.add("BattleConfigTb", v.Id, fmt.Sprintf("RoundTimes[%d]", i), "time %d <= 0", t)
+			}
+		}
+		if v.PlayerNum <= 0 {
+			r.add("BattleConfigTb", v.Id, "PlayerNum", "num %d <= 0", v.PlayerNum)
+		}
+		if v.GridNum <= 0 {
+			r.add("BattleConfigTb", v.Id, "GridNum", "num %d <= 0", v.GridNum)
+		}
+		if v.MatchTime < 0 {
+			r.add("BattleConfigTb", v.Id, "MatchTime", "time %d < 0", v.MatchTime)
+		}
+		if v.RoundInterval < 0 {
+			r.add("BattleConfigTb", v.Id, "RoundInterval", "time %d < 0", v.RoundInterval)
+		}
+		if len(v.RobotStrategyWeight) > cfg.RobotStrategyType_Quit+1 {
+			r.add("BattleConfigTb", v.Id, "RobotStrategyWeight", "want at most %d values, got %d", cfg.RobotStrategyType_Quit+1, len(v.RobotStrategyWeight))
+		}
+		for i, w := range v.RobotStrategyWeight {
+			if w < 0 {
+				r.add("BattleConfigTb", v.Id, fmt.Sprintf("RobotStrategyWeight[%d]", i), "weight %d < 0", w)
+			}
+		}
+	}
+}
+
+// 机器人的roleId使用配置id，需要在机器人的范围内，并且足够补满任何房间
+func checkRobot(r *report) {
+	for _, v := range r.tables.RobotTb.GetDataList() {
+		if v.Id <= 0 || v.Id > 10000 {
+			r.add("RobotTb", v.Id, "Id", "id %d out of [1, 10000]", v.Id)
+		}
+		if v.Name == "" {
+			r.add("RobotTb", v.Id, "Name", "name is empty")
+		}
+		for i, petId := range v.Pets {
+			if r.tables.PetTb.Get(petId) == nil {
+				r.add("RobotTb", v.Id, fmt.Sprintf("Pets[%d]", i), "pet %d not found in PetTb", petId)
+			}
+		}
+	}
+
+	robotNum := len(r.tables.RobotTb.GetDataList())
+	for _, v := range r.tables.BattleConfigTb.GetDataList() {
+		if int(v.PlayerNum)-1 > robotNum {
+			r.add("BattleConfigTb", v.Id, "PlayerNum", "num %d needs %d robots, RobotTb has %d", v.PlayerNum, v.PlayerNum-1, robotNum)
+		}
+	}
+}
+
+// 锦标赛的房间使用战斗配置，名次奖励的区间不能重叠
+func checkTournament(r *report) {
+	for _, v := range r.tables.TournamentTb.GetDataList() {
+		if r.tables.BattleConfigTb.Get(v.BattleId) == nil {
+			r.add("TournamentTb", v.Id, "BattleId", "battle %d not found in BattleConfigTb", v.BattleId)
+		}
+		if v.FirstStart <= 0 {
+			r.add("TournamentTb", v.Id, "FirstStart", "time %d <= 0", v.FirstStart)
+		}
+		if v.Interval < 0 {
+			r.add("TournamentTb", v.Id, "Interval", "time %d < 0", v.Interval)
+		}
+		if v.RegisterTime <= 0 || (v.Interval > 0 && v.RegisterTime > v.Interval) {
+			r.add("TournamentTb", v.Id, "RegisterTime", "time %d out of (0, interval %d]", v.RegisterTime, v.Interval)
+		}
+		if v.MinPlayer < 2 {
+			r.add("TournamentTb", v.Id, "MinPlayer", "num %d < 2", v.MinPlayer)
+		}
+		if v.MaxPlayer < v.MinPlayer {
+			r.add("TournamentTb", v.Id, "MaxPlayer", "num %d < minPlayer %d", v.MaxPlayer, v.MinPlayer)
+		}
+		for i, item := range v.Need {
+			r.checkItem("TournamentTb", v.Id, fmt.Sprintf("Need[%d]", i), item, false)
+		}
+	}
+
+	ranks := make(map[int32][][2]int32)
+	for _, v := range r.tables.TournamentPrizeTb.GetDataList() {
+		if r.tables.TournamentTb.Get(v.TournamentId) == nil {
+			r.add("TournamentPrizeTb", v.Id, "TournamentId", "tournament %d not found in TournamentTb", v.TournamentId)
+		}
+		if v.RankMin <= 0 || v.RankMin > v.RankMax {
+			r.add("TournamentPrizeTb", v.Id, "RankMin", "rank [%d, %d] is invalid", v.RankMin, v.RankMax)
+		}
+		for _, rank := range ranks[v.TournamentId] {
+			if v.RankMin <= rank[1] && v.RankMax >= rank[0] {
+				r.add("TournamentPrizeTb", v.Id, "RankMin", "rank [%d, %d] overlaps [%d, %d]", v.RankMin, v.RankMax, rank[0], rank[1])
+			}
+		}
+		ranks[v.TournamentId] = append(ranks[v.TournamentId], [2]int32{v.RankMin, v.RankMax})
+		for i, item := range v.Reward {
+			r.checkItem("TournamentPrizeTb", v.Id, fmt.Sprintf("Reward[%d]", i), item, false)
+		}
+	}
+}
+
+func checkTask(r *report) {
+	for _, v := range r.tables.TaskTb.GetDataList() {
+		if r.tables.TaskTypeTb.Get(v.TaskSubId) == nil {
+			r.add("TaskTb", v.TaskId, "TaskSubId", "task type %d not found in TaskTypeTb", v.TaskSubId)
+		}
+		if v.Need <= 0 {
+			r.add("TaskTb", v.TaskId, "Need", "need %d <= 0", v.Need)
+		}
+	}
+}
+
+// 每日商店按分组随机
+func checkDailyShop(r *report) {
+	groupWeight := make(map[int32]int32)
+	for _, v := range r.tables.DailyShopTb.GetDataList() {
+		if v.Weight <= 0 {
+			r.add("DailyShopTb", v.Id, "Weight", "weight %d <= 0", v.Weight)
+		}
+		for i, discount := range v.Discount {
+			if discount <= 0 || discount > 100 {
+				r.add("DailyShopTb", v.Id, fmt.Sprintf("Discount[%d]", i), "discount %d not in (0, 100]", discount)
+			}
+		}
+		groupWeight[v.GroupId] += v.Weight
+	}
+
+	for groupId, total := range groupWeight {
+		if total <= 0 {
+			r.add("DailyShopTb", groupId, "GroupId", "total weight of group %d <= 0", total)
+		}
+	}
+}
+
+func checkGuide(r *report) {
+	for _, v := range r.tables.GuideTb.GetDataList() {
+		for _, id := range v.GuideList {
+			if r.tables.GuideListTb.Get(id) == nil {
+				r.add("GuideTb", v.Id, "GuideList", "guide %d not found in GuideListTb", id)
+			}
+		}
+	}
+}
+
+func checkPassPort(r *report) {
+	issues := make(map[int32]bool)
+	for _, v := range r.tables.PassPortIssueTb.GetDataList() {
+		if v.StartTime > v.EndTime {
+			r.add("PassPortIssueTb", v.Id, "EndTime", "end time %d < start time %d", v.EndTime, v.StartTime)
+		}
+		issues[v.Issue] = true
+	}
+
+	for _, v := range r.tables.PassPortRewardTb.GetDataList() {
+		if !issues[v.Issue] {
+			r.add("PassPortRewardTb", v.Id, "Issue", "issue %d not found in PassPortIssueTb", v.Issue)
+		}
+	}
+}
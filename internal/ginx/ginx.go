@@ -1,15 +1,13 @@
 package ginx
 
 import (
-	"bytes"
-	"compress/zlib"
 	"eggServer/internal/config"
 	"eggServer/internal/constant"
 	"eggServer/pkg/errors"
+	"encoding/json"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
-	"github.com/shamaton/msgpack/v2"
 	"net/http"
 	"reflect"
 	"strconv"
@@ -28,6 +26,13 @@ type ResponseData struct {
 	Data interface{} `json:"data,omitempty" msgpack:"data"` // 数据内容
 }
 
+// ResponseBody 编码后的响应
+type ResponseBody struct {
+	Status      int    `json:"status"`      // http状态码
+	ContentType string `json:"contentType"` // 内容类型
+	Data        []byte `json:"data"`        // 编码后的数据
+}
+
 // ResponseFail 返回失败的结构体
 type ResponseFail struct {
 	Code int    `json:"code" msgpack:"code"` // 状态码
@@ -78,46 +83,47 @@ func ResJSON(c *gin.Context, httpCode int, resp interface{}) {
 	switch contentType {
 	case "application/json":
 		if config.C.IsDebugMode() {
-			c.JSON(httpCode, resp)
+			b, err := json.Marshal(resp)
+			if err != nil {
+				fmt.Println("Error marshaling data:", err)
+				return
+			}
+
+			setResBody(c, resp, &ResponseBody{Status: httpCode, ContentType: "application/json; charset=utf-8", Data: b})
+			c.Data(httpCode, "application/json; charset=utf-8", b)
 			c.Abort()
 		}
 	case "application/x-1I9EK5kMNs":
-		data, err := msgpack.Marshal(resp)
+		b, err := EncodeMsgPack(resp)
 		if err != nil {
-			fmt.Println("Error marshaling data:", err)
+			fmt.Println("Error encoding data:", err)
 			return
 		}
 
-		// 创建一个缓冲区来保存压缩后的数据
-		var compressedData bytes.Buffer
-
-		// 创建一个新的 zlib 写入器
-		w := zlib.NewWriter(&compressedData)
-
-		// 写入数据进行压缩
-		if _, err := w.Write(data); err != nil {
-			fmt.Println("Error writing compressed data:", err)
-			return
-		}
-
-		// 关闭写入器以确保所有数据都被写入
-		if err := w.Close(); err != nil {
-			fmt.Println("Error closing zlib writer:", err)
-			return
-		}
-
-		// 加密数据
-		b := compressedData.Bytes()
-		for i, v := range b {
-			b[i] = v ^ config.C.DataKey
-		}
-
 		// 发送压缩后的数据
+		setResBody(c, resp, &ResponseBody{Status: httpCode, ContentType: "application/x-1I9EK5kMNs", Data: b})
 		c.Data(httpCode, "application/x-1I9EK5kMNs", b)
 		c.Abort()
 	}
 }
 
+// 保存成功的响应，失败的请求不会改变数据，不需要保存
+func setResBody(c *gin.Context, resp interface{}, body *ResponseBody) {
+	if r, ok := resp.(ResponseData); ok && r.Code == constant.OK {
+		c.Set(ResBodyKey, body)
+	}
+}
+
+// GetResBody 从上下文中获取编码后的成功响应
+func GetResBody(c *gin.Context) *ResponseBody {
+	if v, ok := c.Get(ResBodyKey); ok {
+		if b, ok := v.(*ResponseBody); ok {
+			return b
+		}
+	}
+	return nil
+}
+
 // ResError 返回错误响应
 // 根据错误类型生成对应的错误响应
 func ResError(c *gin.Context, status int, err error) {
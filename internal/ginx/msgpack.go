@@ -27,6 +27,37 @@ func (msgpackBinding) BindBody(body []byte, obj any) error {
 	return decodeMsgPack(bytes.NewReader(body), obj)
 }
 
+// EncodeMsgPack 将数据序列化为 msgpack 格式，使用 zlib 压缩后再加密
+func EncodeMsgPack(v any) ([]byte, error) {
+	data, err := msgpack.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling data: %w", err)
+	}
+
+	// 创建一个缓冲区来保存压缩后的数据
+	var compressedData bytes.Buffer
+
+	// 创建一个新的 zlib 写入器
+	w := zlib.NewWriter(&compressedData)
+
+	// 写入数据进行压缩
+	if _, err := w.Write(data); err != nil {
+		return nil, fmt.Errorf("error writing compressed data: %w", err)
+	}
+
+	// 关闭写入器以确保所有数据都被写入
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("error closing zlib writer: %w", err)
+	}
+
+	// 加密数据
+	b := compressedData.Bytes()
+	for i, v := range b {
+		b[i] = v ^ config.C.DataKey
+	}
+	return b, nil
+}
+
 func decodeMsgPack(r io.Reader, obj any) error {
 	// 读取原始数据流
 	data, err := io.ReadAll(r)
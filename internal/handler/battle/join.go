@@ -0,0 +1,31 @@
+package battle
+
+import (
+	"eggServer/internal/constant"
+	"eggServer/internal/contextx"
+	"eggServer/internal/ginx"
+	"eggServer/internal/logic"
+	"eggServer/internal/schema"
+	"eggServer/pkg/errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+func Join(c *gin.Context) {
+	ctx := c.Request.Context()
+	db := contextx.FromGormDB(ctx)
+	roleId := contextx.FromRoleID(ctx)
+	req := new(schema.BattleJoinReq)
+	if err := ginx.ParseJSON(c, req); err != nil {
+		ginx.ResError(c, http.StatusOK, errors.NewResponseError(constant.ParametersInvalid, err))
+		return
+	}
+
+	resp, err := logic.BattleLogic.JoinByCode(ctx, db, roleId, req)
+	if err != nil {
+		ginx.ResError(c, http.StatusOK, err)
+		return
+	}
+
+	ginx.ResData(c, constant.OK, resp)
+}
@@ -0,0 +1,28 @@
+package battle
+
+import (
+	"eggServer/internal/constant"
+	"eggServer/internal/ginx"
+	"eggServer/internal/logic"
+	"eggServer/internal/schema"
+	"eggServer/pkg/errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+func Lobby(c *gin.Context) {
+	ctx := c.Request.Context()
+	req := new(schema.BattleLobbyReq)
+	if err := ginx.ParseJSON(c, req); err != nil {
+		ginx.ResError(c, http.StatusOK, errors.NewResponseError(constant.ParametersInvalid, err))
+		return
+	}
+
+	resp, err := logic.BattleLogic.Lobby(ctx, req.BattleId)
+	if err != nil {
+		ginx.ResError(c, http.StatusOK, err)
+		return
+	}
+
+	ginx.ResData(c, constant.OK, resp)
+}
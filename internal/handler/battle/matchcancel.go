@@ -0,0 +1,29 @@
+package battle
+
+import (
+	"eggServer/internal/constant"
+	"eggServer/internal/contextx"
+	"eggServer/internal/ginx"
+	"eggServer/internal/logic"
+	"eggServer/internal/schema"
+	"eggServer/pkg/errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+func MatchCancel(c *gin.Context) {
+	ctx := c.Request.Context()
+	roleId := contextx.FromRoleID(ctx)
+	req := new(schema.BattleMatchCancelReq)
+	if err := ginx.ParseJSON(c, req); err != nil {
+		ginx.ResError(c, http.StatusOK, errors.NewResponseError(constant.ParametersInvalid, err))
+		return
+	}
+
+	if err := logic.BattleLogic.CancelMatch(ctx, roleId, req.BattleId); err != nil {
+		ginx.ResError(c, http.StatusOK, err)
+		return
+	}
+
+	ginx.ResOk(c)
+}
@@ -0,0 +1,24 @@
+package battle
+
+import (
+	"eggServer/internal/constant"
+	"eggServer/internal/contextx"
+	"eggServer/internal/ginx"
+	"eggServer/internal/logic"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+func Stats(c *gin.Context) {
+	ctx := c.Request.Context()
+	roleId := contextx.FromRoleID(ctx)
+	db := contextx.FromGormDB(ctx)
+
+	resp, err := logic.BattleLogic.Stats(ctx, db, roleId)
+	if err != nil {
+		ginx.ResError(c, http.StatusOK, err)
+		return
+	}
+
+	ginx.ResData(c, constant.OK, resp)
+}
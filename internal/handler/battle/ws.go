@@ -0,0 +1,148 @@
+package battle
+
+import (
+	"context"
+	"eggServer/internal/config"
+	"eggServer/internal/constant"
+	"eggServer/internal/contextx"
+	"eggServer/internal/ginx"
+	"eggServer/internal/logic"
+	"eggServer/internal/schema"
+	"eggServer/pkg/errors"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	wsWriteWait  = 10 * time.Second    // 写超时
+	wsPongWait   = 60 * time.Second    // 心跳超时
+	wsPingPeriod = wsPongWait * 9 / 10 // 心跳间隔
+)
+
+var upgrader = websocket.Upgrader{
+	CheckOrigin: checkOrigin,
+}
+
+// 只允许跨域配置中的域名连接，没有Origin的非浏览器客户端直接允许
+func checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	for _, v := range config.C.CORS.AllowOrigins {
+		if v == "*" || strings.EqualFold(v, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// WS 战斗实时推送，推送数据和http响应使用相同的编码
+func WS(c *gin.Context) {
+	ctx := c.Request.Context()
+	logger := contextx.FromLogger(ctx)
+	roleId := contextx.FromRoleID(ctx)
+	req := new(schema.BattleWSReq)
+	if err := ginx.ParseQuery(c, req); err != nil || req.DeskId == "" {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	// 观战可以订阅任何公开房间，私人房间需要邀请码，否则只能订阅自己参加的房间
+	var state interface{}
+	var err error
+	if req.Spectate == 1 {
+		state, err = logic.BattlePushLogic.BuildSpectate(ctx, req.DeskId, req.InviteCode)
+	} else {
+		state, err = logic.BattlePushLogic.BuildConnect(ctx, roleId, req.DeskId)
+	}
+	if err != nil {
+		c.AbortWithStatus(http.StatusForbidden)
+		return
+	}
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		logger.Errorf("battle.WS error:%s", err.Error())
+		return
+	}
+	defer conn.Close()
+
+	sub := logic.BattlePushLogic.Subscribe(roleId, req.DeskId)
+	defer logic.BattlePushLogic.Unsubscribe(sub)
+
+	// 只读取心跳和关闭消息
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = conn.SetReadDeadline(time.Now().Add(wsPongWait))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+		})
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	// 连接后先推送当前状态
+	if err := writeMessage(conn, ginx.ResponseData{Code: constant.OK, Data: state}); err != nil {
+		return
+	}
+	if req.Spectate != 1 {
+		if err := push(ctx, conn, roleId, req, logic.BattleEventRoundResult); err != nil {
+			return
+		}
+	}
+
+	ticker := time.NewTicker(wsPingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case event := <-sub.C:
+			if err := push(ctx, conn, roleId, req, event); err != nil {
+				return
+			}
+		case <-ticker.C:
+			_ = conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// 推送事件，观战的连接每次都推送完整的观战状态
+func push(ctx context.Context, conn *websocket.Conn, roleId uint64, req *schema.BattleWSReq, event byte) error {
+	var resp *schema.BattlePushResp
+	var err error
+	if req.Spectate == 1 {
+		resp, err = logic.BattlePushLogic.BuildSpectate(ctx, req.DeskId, req.InviteCode)
+	} else {
+		resp, err = logic.BattlePushLogic.Build(ctx, roleId, req.DeskId, event)
+	}
+	if err != nil {
+		var e *errors.ResponseError
+		if errors.As(err, &e) {
+			return writeMessage(conn, ginx.ResponseFail{Code: e.Code, Msg: e.Error()})
+		}
+		return writeMessage(conn, ginx.ResponseFail{Code: constant.UnknownError, Msg: err.Error()})
+	}
+	return writeMessage(conn, ginx.ResponseData{Code: constant.OK, Data: resp})
+}
+
+func writeMessage(conn *websocket.Conn, resp interface{}) error {
+	b, err := ginx.EncodeMsgPack(resp)
+	if err != nil {
+		return err
+	}
+	_ = conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+	return conn.WriteMessage(websocket.BinaryMessage, b)
+}
@@ -21,7 +21,7 @@ func Use(c *gin.Context) {
 		return
 	}
 
-	resp, err := logic.ItemLogic.UseItem(ctx, db, roleId, req.ItemID, req.Param, true)
+	resp, err := logic.ItemLogic.UseItem(ctx, db, roleId, req.ItemID, req.Param, true, constant.SourceItemUse)
 	if err != nil {
 		ginx.ResError(c, http.StatusOK, err)
 		return
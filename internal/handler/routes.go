@@ -7,12 +7,16 @@ import (
 	"eggServer/internal/handler/guide"
 	"eggServer/internal/handler/item"
 	"eggServer/internal/handler/leaderboard"
+	"eggServer/internal/handler/ledger"
+	"eggServer/internal/handler/order"
 	"eggServer/internal/handler/passportreward"
+	"eggServer/internal/handler/pet"
 	"eggServer/internal/handler/platform"
 	"eggServer/internal/handler/shop"
 	"eggServer/internal/handler/sign"
 	"eggServer/internal/handler/task"
 	"eggServer/internal/handler/ton"
+	"eggServer/internal/handler/tournament"
 	"eggServer/internal/handler/user"
 	"eggServer/internal/middleware"
 	"github.com/gin-gonic/gin"
@@ -41,6 +45,9 @@ func RegisterHandlers(app *gin.Engine, client redis.UniversalClient, l *logrus.E
 	v1.Use(middleware.Auth())
 	v1.Use(middleware.RateLimiter(client))
 
+	// 会改变数据的接口，客户端重试时不重复扣费或者发奖
+	idempotency := middleware.Idempotency()
+
 	if config.C.GM {
 		v1.POST("/gm", user.GM)
 	}
@@ -52,37 +59,61 @@ func RegisterHandlers(app *gin.Engine, client redis.UniversalClient, l *logrus.E
 
 	v1.POST("/autolayegg", egg.AutoLayEgg)
 	v1.POST("/clickscreen", egg.ClickScreen)
-	v1.POST("/eggopen", egg.Open)
+	v1.POST("/eggopen", idempotency, egg.Open)
+	v1.POST("/eggopenbatch", idempotency, egg.OpenBatch)
+	v1.POST("/eggfusion", idempotency, egg.Fusion)
+
+	v1.POST("/petfeed", idempotency, pet.Feed)
+	v1.POST("/petevolve", idempotency, pet.Evolve)
 
-	v1.POST("/taskrewards", task.Rewards)
+	v1.POST("/taskrewards", idempotency, task.Rewards)
 	v1.POST("/taskdata", task.Data)
 	v1.POST("/taskgoto", task.Goto)
 	v1.POST("/tasktonaccount", task.TonAccount)
 
 	v1.POST("/shopdata", shop.Data)
-	v1.POST("/shoprefresh", shop.Refresh)
-	v1.POST("/shopbuy", shop.Buy)
-	v1.POST("/shopshare", shop.Share)
-	v1.POST("/createorder", shop.CreateOrder)
+	v1.POST("/shoprefresh", idempotency, shop.Refresh)
+	v1.POST("/shopbuy", idempotency, shop.Buy)
+	v1.POST("/shopshare", idempotency, shop.Share)
+	v1.POST("/createorder", idempotency, shop.CreateOrder)
 	v1.POST("/delivery", shop.Delivery)
+	v1.POST("/orderlist", order.List)
+	v1.POST("/ordercancel", idempotency, order.Cancel)
 
-	v1.POST("/battlematch", battle.Match)
+	v1.POST("/battlematch", idempotency, battle.Match)
+	v1.POST("/battlematchcancel", battle.MatchCancel)
+	v1.POST("/battlecreate", idempotency, battle.Create)
+	v1.POST("/battlejoin", idempotency, battle.Join)
+	v1.POST("/battlestart", battle.Start)
 	v1.POST("/battlematchstate", battle.MatchState)
-	v1.POST("/battleleave", battle.Leave)
+	v1.POST("/battleleave", idempotency, battle.Leave)
 	v1.POST("/battlebet", battle.Bet)
 	v1.POST("/battleroundresult", battle.RoundResult)
-	v1.POST("/battlesettlement", battle.Settlement)
+	v1.POST("/battlesettlement", idempotency, battle.Settlement)
 	v1.POST("/battleexit", battle.Exit)
 	v1.POST("/battlesyncscore", battle.SyncScore)
+	v1.POST("/battleverify", battle.Verify)
+	v1.POST("/battleresume", battle.Resume)
+	v1.POST("/battlehistory", battle.History)
+	v1.POST("/battlestats", battle.Stats)
+	v1.POST("/battlereplay", battle.Replay)
+	v1.POST("/battlespectate", battle.Spectate)
+	v1.POST("/battlelobby", battle.Lobby)
+	v1.GET("/battlews", battle.WS)
+
+	v1.POST("/tournamentregister", idempotency, tournament.Register)
+	v1.POST("/tournamentbracket", tournament.Bracket)
+	v1.POST("/tournamentclaim", idempotency, tournament.Claim)
 
-	v1.POST("/itemuse", item.Use)
+	v1.POST("/itemuse", idempotency, item.Use)
 	v1.POST("/leaderboarddata", leaderboard.Data)
+	v1.POST("/ledgerdata", ledger.Data)
 
-	v1.POST("/guidestep", guide.Step)
+	v1.POST("/guidestep", idempotency, guide.Step)
 
 	v1.POST("/signdata", sign.Data)
-	v1.POST("/signin", sign.In)
+	v1.POST("/signin", idempotency, sign.In)
 
 	v1.POST("/passportrewarddata", passportreward.Data)
-	v1.POST("/passportrewardget", passportreward.Get)
+	v1.POST("/passportrewardget", idempotency, passportreward.Get)
 }
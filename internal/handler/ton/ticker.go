@@ -19,7 +19,7 @@ func Ticker(c *gin.Context) {
 		return
 	}
 
-	last, err := logic.TonapiLogic.Ticker(ctx, req.InstId)
+	last, err := logic.PriceLogic.Price(ctx, req.InstId)
 	if err != nil {
 		ginx.ResError(c, http.StatusOK, err)
 		return
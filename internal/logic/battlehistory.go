@@ -0,0 +1,117 @@
+package logic
+
+import (
+	"context"
+	"eggServer/internal/constant"
+	"eggServer/internal/models"
+	"eggServer/internal/schema"
+	"eggServer/pkg/errors"
+	"eggServer/pkg/utils"
+	"gorm.io/gorm"
+	"math"
+	"sort"
+)
+
+// 战斗记录每页最多条数
+const battleHistoryMaxPageSize = 50
+
+// History 分页获取参战记录，按时间倒序
+func (s *battleLogic) History(ctx context.Context, db *gorm.DB, roleId uint64, req *schema.BattleHistoryReq) (*schema.BattleHistoryResp, error) {
+	pageNum, pageSize := req.Page, req.Limit
+	if pageNum <= 0 {
+		pageNum = 1
+	}
+	if pageSize <= 0 {
+		pageSize = 10
+	}
+	if pageSize > battleHistoryMaxPageSize {
+		pageSize = battleHistoryMaxPageSize
+	}
+
+	records, total, err := models.BattleRecordRepo.FindPageByRoleId(ctx, db, roleId, pageNum, pageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := new(schema.BattleHistoryResp)
+	resp.Total = total
+	resp.List = make([]*schema.BattleRecordData, 0, len(records))
+	for _, record := range records {
+		data := new(schema.BattleRecordData)
+		utils.Copy(data, record)
+		resp.List = append(resp.List, data)
+	}
+	return resp, nil
+}
+
+// Stats 战斗统计
+func (s *battleLogic) Stats(ctx context.Context, db *gorm.DB, roleId uint64) (*schema.BattleStatsResp, error) {
+	stats, err := models.BattleRecordRepo.StatsByRoleId(ctx, db, roleId)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := new(schema.BattleStatsResp)
+	resp.BattleCount = stats.BattleCount
+	resp.WinCount = stats.WinCount
+	resp.TotalBonus = stats.TotalBonus
+	if stats.BattleCount > 0 {
+		resp.WinRate = int32(stats.WinCount * 10000 / stats.BattleCount)
+		resp.AvgRounds = math.Round(float64(stats.TotalRounds)/float64(stats.BattleCount)*100) / 100
+	}
+	return resp, nil
+}
+
+// Replay 已结束战斗的回放，只有参战的玩家可以查看
+func (s *battleLogic) Replay(ctx context.Context, db *gorm.DB, roleId uint64, deskId string) (*schema.BattleReplayResp, error) {
+	battleResult, err := models.BattleResultRepo.FindOneByDeskId(ctx, db, deskId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.NewResponseError(constant.BattleNotFinished, nil)
+		}
+		return nil, err
+	}
+
+	if _, ok := battleResult.PlayerData[roleId]; !ok {
+		return nil, errors.NewResponseError(constant.BattleYouNotInRound, nil)
+	}
+
+	battleData := new(models.BattleData)
+	utils.Copy(battleData, battleResult)
+
+	resp := new(schema.BattleReplayResp)
+	resp.DeskId = battleResult.DeskId
+	resp.BattleId = battleResult.BattleId
+	resp.CreatedAt = battleResult.CreateAt
+	resp.StartAt = battleResult.StartAt
+	resp.ServerSeed = battleResult.ServerSeed
+	resp.SeedHash = battleResult.SeedHash
+	resp.Result = battleResult.Result
+	resp.TournamentId = battleResult.TournamentId
+	resp.RoundBonus = make([]int32, 0, len(battleResult.Result))
+	for i := range battleResult.Result {
+		resp.RoundBonus = append(resp.RoundBonus, battleResult.Bonus[i+1])
+	}
+
+	resp.Players = make([]*schema.BattleReplayPlayer, 0, len(battleResult.PlayerData))
+	for id, playerData := range battleResult.PlayerData {
+		player := &schema.BattleReplayPlayer{
+			RoleId:   id,
+			Name:     playerData.Name,
+			PetId:    playerData.PetId,
+			Ability:  playerData.Ability,
+			Bet:      playerData.Bet,
+			SafeGrid: playerData.SafeGrid,
+			Survived: playerData.Survived,
+			Rounds:   s.survivedRounds(battleData, playerData),
+			Win:      playerData.Win,
+			Bonus:    playerData.Bonus,
+			IsRobot:  playerData.IsRobot,
+		}
+		resp.Players = append(resp.Players, player)
+	}
+	sort.Slice(resp.Players, func(i, j int) bool {
+		return resp.Players[i].RoleId < resp.Players[j].RoleId
+	})
+	return resp, nil
+}
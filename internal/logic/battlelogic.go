@@ -10,17 +10,15 @@ import (
 	"eggServer/pkg/errors"
 	"eggServer/pkg/redisbackend"
 	"eggServer/pkg/utils"
-	Xorshift "eggServer/pkg/utils/xorshift"
+	"eggServer/pkg/utils/fair"
+	"eggServer/pkg/utils/weighted"
 	"encoding/json"
 	"fmt"
 	"github.com/patrickmn/go-cache"
 	"github.com/redis/go-redis/v9"
-	"github.com/spf13/cast"
 	"golang.org/x/sync/singleflight"
 	"gorm.io/gorm"
 	"math"
-	"math/rand"
-	"strings"
 	"time"
 )
 
@@ -28,139 +26,146 @@ var (
 	BattleDeskIdKey             = "battle:deskId:%d"
 	BattleDataKey               = "battle:data:%s"
 	BattleMatchKey              = "battle:match:%d"
+	BattleQueueKey              = "battle:queue:%d"
+	BattleQueueInfoKey          = "battle:queueInfo:%d"
+	BattleQueueRoleKey          = "battle:queueRole:%d"
 	BattleRegistrationKey       = "battleRegistration:%s"
 	BattleScoreKey              = "battleScore:%s"
+	BattleScheduleKey           = "battle:schedule"
 	BattleNeedPetNum      int32 = 1
 )
 
 var BattleLogic = new(battleLogic)
 
 type battleLogic struct {
-	tables   *cfg.Tables
-	g        singleflight.Group
-	cache    *cache.Cache
-	xorshift *Xorshift.Xorshift
+	tables           *cfg.Tables
+	g                singleflight.Group
+	cache            *cache.Cache
+	tournamentBattle map[int32]bool // 锦标赛使用的战斗，不能直接匹配
+
+	source               weighted.Source
+	robotStrategySampler map[int32]*weighted.Sampler[int32] // 战斗 -> 机器人策略
 }
 
 func (s *battleLogic) Init(tables *cfg.Tables) {
 	s.tables = tables
+	s.tournamentBattle = make(map[int32]bool)
+	for _, v := range tables.TournamentTb.GetDataList() {
+		s.tournamentBattle[v.BattleId] = true
+	}
 
-	// 使用当前时间的纳秒部分作为种子
-	seed := uint32(time.Now().UnixNano())
-	s.xorshift = Xorshift.NewXorshift(seed)
-
-	// 内存缓存
-	s.cache = cache.New(time.Second, time.Minute)
-}
+	if s.source == nil {
+		s.source = weighted.Default
+	}
 
-// 检查无效的战斗数据
-func (s *battleLogic) checkInvalidBattleData(ctx context.Context, rb *redisbackend.RedisBackend, battleData *models.BattleData, battleId int32) (bool, error) {
-	logger := contextx.FromLogger(ctx)
-	battleConfig := s.tables.BattleConfigTb.Get(battleId)
-	if battleData != nil && time.Now().Unix() >= battleData.CreateAt+int64(battleConfig.MatchTime) {
-		if battleData.State == 0 || battleData.State == 2 {
-			if err := rb.Client().Del(ctx, fmt.Sprintf(BattleDataKey, battleData.DeskId)).Err(); err != nil {
-				logger.Errorf("BattleLogic.checkInvalidBattleData error:%s", err.Error())
-				return true, err
+	// 权重为0的策略不参与随机
+	s.robotStrategySampler = make(map[int32]*weighted.Sampler[int32])
+	for _, v := range tables.BattleConfigTb.GetDataList() {
+		strategies := make([]int32, 0)
+		weights := make([]int32, 0)
+		for strategy, weight := range v.RobotStrategyWeight {
+			if weight > 0 {
+				strategies = append(strategies, int32(strategy))
+				weights = append(weights, weight)
 			}
-			return true, nil
+		}
+		if len(strategies) == 0 {
+			continue
+		}
+		if sampler, err := weighted.New(strategies, weights); err == nil {
+			s.robotStrategySampler[v.Id] = sampler
 		}
 	}
-	return false, nil
+
+	// 内存缓存
+	s.cache = cache.New(time.Second, time.Minute)
 }
 
-// Match 匹配
+// Match 匹配，新手引导直接创建房间，其他战斗加入匹配队列，由调度器分配房间
+// 分配房间前重复调用返回排队状态，分配后返回房间的匹配状态
 func (s *battleLogic) Match(ctx context.Context, db *gorm.DB, roleId uint64, battleId int32, petId int32) (*schema.BattleMatchResp, error) {
 	logger := contextx.FromLogger(ctx)
 	rb := contextx.FromRB(ctx)
 	battleConfig := s.tables.BattleConfigTb.Get(battleId)
-	if battleConfig == nil {
+	if battleConfig == nil || s.tournamentBattle[battleId] || s.tables.PetTb.Get(petId) == nil {
 		return nil, errors.NewResponseError(constant.ParametersInvalid, nil)
 	}
 
-	// 分布式锁
-	m := rb.NewMutex(fmt.Sprintf(BattleMatchKey, battleId))
-	if err := m.Lock(ctx); err != nil {
+	if battleConfig.IsGuide == 1 {
+		return s.matchGuide(ctx, db, rb, roleId, battleId, petId)
+	}
+
+	role, err := models.RoleRepo.Get(ctx, db, roleId)
+	if err != nil {
 		logger.Errorf("BattleLogic.Match error:%s", err.Error())
-		return nil, errors.NewResponseError(constant.ServerBusy, err)
+		return nil, errors.NewResponseError(constant.DatabaseError, err)
 	}
 
-	defer func() {
-		if _, err := m.Unlock(context.Background()); err != nil {
-			logger.WithError(err).Error("error on mutex unlock")
+	// 已经分配了房间
+	if role.LastDeskId != "" {
+		battleData, err := s.getBattleData(ctx, rb, role.LastDeskId)
+		if err == nil && battleData.BattleId == battleId && utils.InArray(battleData.Players, roleId) {
+			resp := new(schema.BattleMatchResp)
+			resp.MatchState = s.getBattleMatchStateResp(roleId, battleData)
+			return resp, nil
 		}
-	}()
-
-	key := fmt.Sprintf(BattleDeskIdKey, battleId)
+		return nil, errors.NewResponseError(constant.AlreadyJoinOtherBattle, nil)
+	}
 
-	// 获取最后的房间号
-	ret, err := rb.Client().Get(ctx, key).Result()
-	if err != nil && !errors.Is(err, redis.Nil) {
+	ret, err := s.checkQualified(ctx, db, roleId, battleConfig.Need)
+	if err != nil {
 		return nil, err
 	}
 
-	if ret == "" {
-		// 设置初始房间号为1
-		ret, err = rb.Client().Set(ctx, key, 1, redis.KeepTTL).Result()
-		if err != nil {
-			return nil, err
-		}
+	// 不符合报名资格
+	if !ret {
+		return nil, errors.NewResponseError(constant.NotQualified, nil)
 	}
 
-	// 最后的房间号
-	deskId := fmt.Sprintf("%d-%s", battleId, ret)
-	battleData, err := s.getBattleData(ctx, rb, deskId)
-	if err != nil && !errors.Is(err, redis.Nil) {
-		return nil, err
+	// 宠物在分配房间时才消耗，这里只检查数量
+	pet, err := models.PetRepo.Get(ctx, db, roleId, petId)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.NewResponseError(constant.DatabaseError, err)
+	}
+	if pet.PetNum < BattleNeedPetNum {
+		return nil, errors.NewResponseError(constant.MinionsNotEnough, nil)
 	}
 
-	isInvalid, err := s.checkInvalidBattleData(ctx, rb, battleData, battleId)
+	resp := new(schema.BattleMatchResp)
+	resp.MatchState, err = s.enqueue(ctx, rb, roleId, battleId, petId, role.BattleCount)
 	if err != nil {
+		logger.Errorf("BattleLogic.Match error:%s", err.Error())
 		return nil, err
 	}
+	return resp, nil
+}
 
-	if battleConfig.IsGuide == 1 {
-		guide, err := models.GuideRepo.Get(ctx, db, roleId)
-		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, err
-		}
-		// 已经进行新手战斗引导了
-		if utils.InArray(guide.Step, 306) {
-			return nil, errors.NewResponseError(constant.ParametersInvalid, nil)
-		}
-		// 记录新手引导
-		if err := GuideLogic.Step(ctx, db, roleId, 306); err != nil {
-			return nil, err
-		}
+// 新手战斗引导，每次创建新房间
+func (s *battleLogic) matchGuide(ctx context.Context, db *gorm.DB, rb *redisbackend.RedisBackend, roleId uint64, battleId int32, petId int32) (*schema.BattleMatchResp, error) {
+	logger := contextx.FromLogger(ctx)
+	guide, err := models.GuideRepo.Get(ctx, db, roleId)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
 	}
-
-	// 战斗引导或战斗房间无效或战斗还没开始并且人数已经满了或战斗已经开始了
-	if battleConfig.IsGuide == 1 || battleData == nil || isInvalid || (!s.isBattleStart(battleData) && len(battleData.Players) == int(battleConfig.PlayerNum)) || s.isBattleStart(battleData) {
-		// 房间号自加
-		ret, err := rb.Client().Incr(ctx, key).Result()
-		if err != nil {
-			return nil, err
-		}
-		deskId = fmt.Sprintf("%d-%d", battleId, ret)
-		// 创建新房间
-		battleData, err = s.createBattleData(ctx, rb, battleId, deskId)
-		if err != nil {
-			logger.Errorf("BattleLogic.Match error:%s", err.Error())
-			return nil, err
-		}
+	// 已经进行新手战斗引导了
+	if utils.InArray(guide.Step, 306) {
+		return nil, errors.NewResponseError(constant.ParametersInvalid, nil)
+	}
+	// 记录新手引导
+	if err := GuideLogic.Step(ctx, db, roleId, 306); err != nil {
+		return nil, err
 	}
 
-	return s.join(ctx, db, rb, roleId, battleData, petId)
-}
+	ret, err := rb.Client().Incr(ctx, fmt.Sprintf(BattleDeskIdKey, battleId)).Result()
+	if err != nil {
+		return nil, errors.NewResponseError(constant.RDBError, err)
+	}
+	deskId := fmt.Sprintf("%d-%d", battleId, ret)
 
-// MatchState 匹配状态
-func (s *battleLogic) MatchState(ctx context.Context, roleId uint64, deskId string) (*schema.BattleMatchStateResp, error) {
-	logger := contextx.FromLogger(ctx)
-	rb := contextx.FromRB(ctx)
-	// 分布式锁
+	// 分布式锁，房间设置完成前调度器不能处理
 	m := rb.NewMutex(deskId)
 	if err := m.Lock(ctx); err != nil {
-		logger.Errorf("BattleLogic.MatchState error:%s", err.Error())
+		logger.Errorf("BattleLogic.matchGuide error:%s", err.Error())
 		return nil, errors.NewResponseError(constant.ServerBusy, err)
 	}
 
@@ -170,6 +175,19 @@ func (s *battleLogic) MatchState(ctx context.Context, roleId uint64, deskId stri
 		}
 	}()
 
+	battleData, err := s.createBattleData(ctx, rb, battleId, deskId)
+	if err != nil {
+		logger.Errorf("BattleLogic.matchGuide error:%s", err.Error())
+		return nil, err
+	}
+	return s.join(ctx, db, rb, roleId, battleData, petId)
+}
+
+// MatchState 匹配状态
+func (s *battleLogic) MatchState(ctx context.Context, roleId uint64, deskId string) (*schema.BattleMatchStateResp, error) {
+	logger := contextx.FromLogger(ctx)
+	rb := contextx.FromRB(ctx)
+
 	battleData, err := s.getBattleData(ctx, rb, deskId)
 	// 检查错误
 	if err := s.checkError(battleData, roleId, err); err != nil {
@@ -177,11 +195,6 @@ func (s *battleLogic) MatchState(ctx context.Context, roleId uint64, deskId stri
 		return nil, err
 	}
 
-	// 机器人逻辑
-	if err := s.robotJoin(ctx, rb, battleData, true); err != nil {
-		return nil, err
-	}
-
 	resp := s.getBattleMatchStateResp(roleId, battleData)
 	return resp, nil
 }
@@ -204,6 +217,17 @@ func (s *battleLogic) getBattleMatchStateResp(roleId uint64, battleData *models.
 
 	resp.PlayerNum = len(battleData.Players)
 	resp.DeskId = battleData.DeskId
+	resp.SeedHash = battleData.SeedHash
+	resp.MaxPlayerNum = s.getPlayerNum(battleData)
+	resp.Creator = battleData.Creator
+	resp.InviteCode = battleData.InviteCode
+
+	// 房间中的玩家，机器人和真实玩家一样显示
+	resp.Players = make([]*schema.BattlePlayer, 0, len(battleData.Players))
+	for _, id := range battleData.Players {
+		data := s.getPlayerData(battleData, id)
+		resp.Players = append(resp.Players, &schema.BattlePlayer{RoleId: id, Name: data.Name, PetId: data.PetId})
+	}
 	return resp
 }
 
@@ -215,27 +239,20 @@ func (s *battleLogic) robotJoin(ctx context.Context, rb *redisbackend.RedisBacke
 	}
 
 	battleConfig := s.tables.BattleConfigTb.Get(battleData.BattleId)
-	if battleConfig.AddRobot == 0 {
+	if !s.isAddRobot(battleData) {
 		return nil
 	}
 
-	if len(battleData.Players) < int(battleConfig.PlayerNum) && len(battleData.PlayerData) < int(battleConfig.PlayerNum) {
+	maxPlayerNum := s.getPlayerNum(battleData)
+	if len(battleData.Players) < maxPlayerNum && len(battleData.PlayerData) < maxPlayerNum {
 		t := time.Now().Unix() - battleData.CreateAt
-		p := int(math.Ceil((float64(t) / float64(battleConfig.MatchTime)) * float64(battleConfig.PlayerNum)))
+		p := int(math.Ceil((float64(t) / float64(battleConfig.MatchTime)) * float64(maxPlayerNum)))
 		playerNum := len(battleData.Players)
-		if p > int(battleConfig.PlayerNum) {
-			p = int(battleConfig.PlayerNum)
+		if p > maxPlayerNum {
+			p = maxPlayerNum
 		}
 		// 人数不足自动补机器人
-		if p > playerNum {
-			n := p - playerNum
-			petConfigList := s.tables.PetTb.GetDataList()
-			for i := 0; i < n; i++ {
-				petConfig, _ := utils.RandomElement(petConfigList)
-				battleData.RobotRoleId = battleData.RobotRoleId + 1
-				s.dealJoin(true, battleData.RobotRoleId, battleData, petConfig.Id)
-			}
-
+		if p > playerNum && s.addRobots(battleData, p-playerNum) > 0 {
 			if isSaveBattleData {
 				if err := s.saveBattleData(ctx, rb, battleData); err != nil {
 					logger.Errorf("BattleLogic.robotJoin error:%s", err.Error())
@@ -247,37 +264,46 @@ func (s *battleLogic) robotJoin(ctx context.Context, rb *redisbackend.RedisBacke
 	return nil
 }
 
-// 是否是机器人
-func (s *battleLogic) isRobot(roleId uint64) bool {
-	return roleId <= 10000
+// 房间的人数上限，私人房间由创建者设置
+func (s *battleLogic) getPlayerNum(battleData *models.BattleData) int {
+	if battleData.PlayerNum > 0 {
+		return battleData.PlayerNum
+	}
+	return int(s.tables.BattleConfigTb.Get(battleData.BattleId).PlayerNum)
 }
 
-// 是否所有都是机器人
-func (s *battleLogic) isAllRobot(players []uint64) bool {
-	for _, roleId := range players {
-		if !s.isRobot(roleId) {
-			return false
-		}
+// 是否用机器人补满房间，私人房间由创建者选择
+func (s *battleLogic) isAddRobot(battleData *models.BattleData) bool {
+	if battleData.TournamentId > 0 {
+		return false
 	}
-	return true
+	if s.isPrivate(battleData) {
+		return battleData.AddRobot == 1
+	}
+	return s.tables.BattleConfigTb.Get(battleData.BattleId).AddRobot == 1
+}
+
+// 是否是机器人，以加入房间时的标记为准
+func (s *battleLogic) isRobot(battleData *models.BattleData, roleId uint64) bool {
+	playerData, ok := battleData.PlayerData[roleId]
+	return ok && playerData.IsRobot == 1
 }
 
-// 机器人人数
-func (s *battleLogic) getRobotLeftNum(players []uint64) int {
-	num := 0
-	for _, roleId := range players {
-		if s.isRobot(roleId) {
-			num++
+// 是否所有都是机器人
+func (s *battleLogic) isAllRobot(battleData *models.BattleData) bool {
+	for _, roleId := range battleData.Players {
+		if !s.isRobot(battleData, roleId) {
+			return false
 		}
 	}
-	return num
+	return true
 }
 
 // 机器人下注人数
 func (s *battleLogic) getRobotBetNum(battleData *models.BattleData, round int) int {
 	i := 0
 	for _, roleId := range battleData.Players {
-		if s.isRobot(roleId) {
+		if s.isRobot(battleData, roleId) {
 			playerData := s.getPlayerData(battleData, roleId)
 			if len(playerData.Bet) >= round {
 				i++
@@ -287,47 +313,58 @@ func (s *battleLogic) getRobotBetNum(battleData *models.BattleData, round int) i
 	return i
 }
 
-// 机器人下注
+// 机器人下注，每个机器人按自己的策略决定下注的时间和格子
 func (s *battleLogic) robotBet(ctx context.Context, rb *redisbackend.RedisBackend, battleData *models.BattleData, isSaveBattleData bool, round int) error {
 	logger := contextx.FromLogger(ctx)
 	if battleData == nil {
 		return nil
 	}
 	battleConfig := s.tables.BattleConfigTb.Get(battleData.BattleId)
-	if battleConfig.AddRobot == 1 {
-		roundTime := battleConfig.RoundTimes[round-1]
-		robotNum := s.getRobotLeftNum(battleData.Players)
-		t := int64(roundTime) - (s.getRoundStartTime(battleData, round) - time.Now().Unix())
-		n := int(math.Ceil(float64(t) / float64(roundTime) * float64(robotNum)))
-		robotBetNum := s.getRobotBetNum(battleData, round)
-
-		if n > robotNum {
-			n = robotNum
-		}
-
-		if n > robotBetNum {
-			gridList := s.getGridList(battleData)
-			i := 0
-			for _, roleId := range battleData.Players {
-				if s.isRobot(roleId) {
-					i++
-					if i <= n {
-						playerData := s.getPlayerData(battleData, roleId)
-						if len(playerData.Bet) < round {
-							// 随机格子
-							grid := gridList[s.xorshift.Intn(len(gridList))]
-							s.bet(roleId, grid, round, battleData)
-						}
-					}
-				}
-			}
+	if !s.isAddRobot(battleData) {
+		return nil
+	}
 
-			if isSaveBattleData {
-				if err := s.saveBattleData(ctx, rb, battleData); err != nil {
-					logger.Errorf("BattleLogic.robotBet error:%s", err.Error())
-					return err
-				}
-			}
+	// 本回合下注时间的进度，万分比
+	roundTime := int64(battleConfig.RoundTimes[round-1])
+	t := roundTime - (s.getRoundStartTime(battleData, round) - time.Now().Unix())
+	progress := t * 10000 / roundTime
+
+	gridList := s.getGridList(battleData)
+	_, petList, _ := s.getGridOccupancy(battleData, round)
+	petNum := make(map[int32]int)
+	for grid, list := range petList {
+		petNum[grid] = len(list)
+	}
+
+	changed := false
+	for _, roleId := range utils.DeepCopyArray(battleData.Players) {
+		if !s.isRobot(battleData, roleId) {
+			continue
+		}
+		playerData := s.getPlayerData(battleData, roleId)
+		strategy := s.getRobotStrategy(playerData.Strategy)
+		if len(playerData.Bet) >= round || progress < strategy.betPoint(battleData.ServerSeed, round, roleId) {
+			continue
+		}
+
+		// 还有真实玩家时才退出，和玩家退出的流程相同
+		if battleConfig.CanExit == 1 && !s.isAllRobot(battleData) && !s.isRoundStart(battleData, round) && strategy.exit(battleData.ServerSeed, round, roleId) {
+			s.dealExit(battleData, roleId, round)
+			changed = true
+			continue
+		}
+
+		grid := strategy.pickGrid(battleData.ServerSeed, round, roleId, gridList, petNum)
+		s.bet(roleId, grid, round, battleData)
+		// 后下注的机器人可以看到前面的下注
+		petNum[grid]++
+		changed = true
+	}
+
+	if changed && isSaveBattleData {
+		if err := s.saveBattleData(ctx, rb, battleData); err != nil {
+			logger.Errorf("BattleLogic.robotBet error:%s", err.Error())
+			return err
 		}
 	}
 	return nil
@@ -389,7 +426,7 @@ func (s *battleLogic) join(ctx context.Context, db *gorm.DB, rb *redisbackend.Re
 
 	if x, found := s.cache.Get(fmt.Sprintf(BattleRegistrationKey, battleData.DeskId)); found {
 		// 报名人数已满
-		if int(battleConfig.PlayerNum) <= x.(int) {
+		if s.getPlayerNum(battleData) <= x.(int) {
 			return nil, errors.NewResponseError(constant.BattleRegistrationFull, nil)
 		}
 	}
@@ -400,74 +437,133 @@ func (s *battleLogic) join(ctx context.Context, db *gorm.DB, rb *redisbackend.Re
 	}
 
 	// 报名人数已满
-	if int(battleConfig.PlayerNum) <= len(battleData.Players) {
+	if s.getPlayerNum(battleData) <= len(battleData.Players) {
 		// 内存缓存
 		s.cache.Set(fmt.Sprintf(BattleRegistrationKey, battleData.DeskId), len(battleData.Players), time.Second)
 		return nil, errors.NewResponseError(constant.BattleRegistrationFull, nil)
 	}
 
-	ret, err := s.checkQualified(ctx, db, roleId, battleConfig.Need)
-	if err != nil {
-		return nil, err
-	}
-
-	// 不符合报名资格
-	if !ret {
-		return nil, errors.NewResponseError(constant.NotQualified, nil)
-	}
-
-	role, err := models.RoleRepo.Get(ctx, db, roleId)
-	if err != nil {
-		logger.Errorf("BattleLogic.join error:%s", err.Error())
-		return nil, errors.NewResponseError(constant.DatabaseError, err)
-	}
-
-	// 已报名其他战场
-	if role.LastDeskId != "" && role.LastDeskId != battleData.DeskId {
-		return nil, errors.NewResponseError(constant.AlreadyJoinOtherBattle, nil)
-	}
-
 	resp := new(schema.BattleMatchResp)
 
 	if !utils.InArray(battleData.Players, roleId) {
-		err = db.Transaction(func(tx *gorm.DB) error {
-			if err := models.RoleRepo.Updates(ctx, db, roleId, map[string]interface{}{"lastDeskId": battleData.DeskId, "battleCount": role.BattleCount + 1}); err != nil {
-				return err
-			}
-
-			reward, err := UtilsLogic.AddItem(ctx, db, roleId, petId, -BattleNeedPetNum, cfg.RewardType_Pet)
-			if err != nil {
-				return err
-			}
-			resp.Reward = reward
-			return nil
-		})
-
+		var err error
+		resp.Reward, err = s.takeSeat(ctx, db, roleId, battleData, petId)
 		if err != nil {
 			logger.Errorf("BattleLogic.join error:%s", err.Error())
 			return nil, err
 		}
 
-		s.dealJoin(false, roleId, battleData, petId)
 		if err := s.saveBattleData(ctx, rb, battleData); err != nil {
 			logger.Errorf("BattleLogic.join error:%s", err.Error())
 			return nil, err
 		}
+		BattlePushLogic.Publish(ctx, rb, battleData.DeskId, BattleEventMatchState)
 	}
 
 	resp.MatchState = s.getBattleMatchStateResp(roleId, battleData)
 	return resp, nil
 }
 
+// 占用房间的位置，记录所在房间并消耗宠物，战斗数据由调用方保存
+// 在事务中锁定角色后检查是否已经加入其他房间和报名资格，宠物数量不足时消耗失败
+func (s *battleLogic) takeSeat(ctx context.Context, db *gorm.DB, roleId uint64, battleData *models.BattleData, petId int32) (*schema.RewardData, error) {
+	battleConfig := s.tables.BattleConfigTb.Get(battleData.BattleId)
+	if battleConfig == nil {
+		return nil, errors.NewResponseError(constant.ParametersInvalid, nil)
+	}
+
+	var pets []*models.PetInstance
+	var reward *schema.RewardData
+	var name string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		role, err := models.RoleRepo.GetForUpdate(ctx, tx, roleId)
+		if err != nil {
+			return err
+		}
+
+		// 已报名其他战场
+		if role.LastDeskId != "" && role.LastDeskId != battleData.DeskId {
+			return errors.NewResponseError(constant.AlreadyJoinOtherBattle, nil)
+		}
+
+		ret, err := s.checkQualified(ctx, tx, roleId, battleConfig.Need)
+		if err != nil {
+			return err
+		}
+
+		// 不符合报名资格
+		if !ret {
+			return errors.NewResponseError(constant.NotQualified, nil)
+		}
+
+		if err := models.RoleRepo.Updates(ctx, tx, roleId, map[string]interface{}{"lastDeskId": battleData.DeskId, "battleCount": role.BattleCount + 1}); err != nil {
+			return err
+		}
+
+		// 消耗等级最低的宠物
+		list, r, err := PetLogic.ConsumePets(ctx, tx, roleId, petId, BattleNeedPetNum, constant.SourceBattleJoin)
+		if err != nil {
+			return err
+		}
+		pets = list
+		reward = r
+
+		name, err = s.getRoleName(ctx, tx, role)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.dealJoin(false, roleId, name, battleData, petId)
+	// 记录参战宠物的等级，离开房间时按原样返还
+	if len(pets) > 0 {
+		playerData := s.getPlayerData(battleData, roleId)
+		playerData.PetInstanceId = pets[0].ID
+		playerData.PetLevel = pets[0].Level
+		playerData.PetExp = pets[0].Exp
+		playerData.PetStage = pets[0].Stage
+	}
+	return reward, nil
+}
+
+// 加入时消耗的宠物，返还时保留原来的id、等级和经验
+func (s *battleLogic) getConsumedPet(playerData *models.BattlePlayerData) *models.PetInstance {
+	pet := &models.PetInstance{ID: playerData.PetInstanceId, Level: playerData.PetLevel, Exp: playerData.PetExp, Stage: playerData.PetStage}
+	if pet.Level == 0 {
+		pet.Level = 1
+	}
+	return pet
+}
+
+// 玩家显示的名字
+func (s *battleLogic) getRoleName(ctx context.Context, db *gorm.DB, role *models.Role) (string, error) {
+	user, err := models.UserRepo.FindOneByUserId(ctx, db, role.UserID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", err
+	}
+	return user.FirstName, nil
+}
+
 // 处理加入战斗
-func (s *battleLogic) dealJoin(isRobot bool, roleId uint64, battleData *models.BattleData, petId int32) {
+func (s *battleLogic) dealJoin(isRobot bool, roleId uint64, name string, battleData *models.BattleData, petId int32) {
 	// 保存参战的宠物
 	playerData := s.getPlayerData(battleData, roleId)
 	playerData.PetId = petId
+	playerData.Name = name
+
+	// 记录宠物的能力，配置修改不影响已经开始的战斗
+	if petConfig := s.tables.PetTb.Get(petId); petConfig != nil {
+		playerData.Ability = petConfig.Ability
+		playerData.AbilityVal = petConfig.AbilityValue
+	}
 
 	// 加入房间时间
 	playerData.JoinAt = 0
-	if !isRobot {
+	playerData.IsRobot = 0
+	if isRobot {
+		playerData.IsRobot = 1
+	} else {
 		playerData.JoinAt = time.Now().Unix()
 	}
 
@@ -510,17 +606,16 @@ func (s *battleLogic) Leave(ctx context.Context, db *gorm.DB, roleId uint64, des
 	if ret {
 		playerData := s.getPlayerData(battleData, roleId)
 		if playerData.PetId > 0 {
-			role, err := models.RoleRepo.Get(ctx, db, roleId)
-			if err != nil {
-				logger.Errorf("BattleLogic.Leave error:%s", err.Error())
-				return nil, errors.NewResponseError(constant.DatabaseError, err)
-			}
-
 			err = db.Transaction(func(tx *gorm.DB) error {
-				if err := models.RoleRepo.Updates(ctx, db, roleId, map[string]interface{}{"lastDeskId": "", "battleCount": role.BattleCount - 1}); err != nil {
+				role, err := models.RoleRepo.GetForUpdate(ctx, tx, roleId)
+				if err != nil {
 					return err
 				}
-				reward, err := UtilsLogic.AddItem(ctx, db, roleId, playerData.PetId, BattleNeedPetNum, cfg.RewardType_Pet)
+				if err := models.RoleRepo.Updates(ctx, tx, roleId, map[string]interface{}{"lastDeskId": "", "battleCount": role.BattleCount - 1}); err != nil {
+					return err
+				}
+				pet := s.getConsumedPet(playerData)
+				reward, err := PetLogic.RestorePets(ctx, tx, roleId, playerData.PetId, BattleNeedPetNum, pet, constant.SourceBattleLeave)
 				if err != nil {
 					return err
 				}
@@ -542,7 +637,7 @@ func (s *battleLogic) Leave(ctx context.Context, db *gorm.DB, roleId uint64, des
 		}
 
 		// 都是机器人
-		if s.isAllRobot(battleData.Players) {
+		if s.isAllRobot(battleData) {
 			battleData.State = 0
 		}
 
@@ -553,6 +648,7 @@ func (s *battleLogic) Leave(ctx context.Context, db *gorm.DB, roleId uint64, des
 			logger.Errorf("BattleLogic.Leave error:%s", err.Error())
 			return nil, err
 		}
+		BattlePushLogic.Publish(ctx, rb, deskId, BattleEventMatchState)
 	}
 
 	return resp, nil
@@ -597,32 +693,15 @@ func (s *battleLogic) Exit(ctx context.Context, roleId uint64, deskId string) (*
 	}
 
 	if utils.InArray(battleData.Players, roleId) {
-		playerData := s.getPlayerData(battleData, roleId)
 		round := s.getRound(battleData)
 		if !s.isRoundStart(battleData, round) { // 回合还没开始
-			// 可以结算
-			playerData.Settlement = 1
-
-			battleData.Players, _ = utils.RemoveElement(battleData.Players, roleId)
-
-			if !utils.InArray(battleData.ExitPlayer, roleId) {
-				battleData.ExitPlayer = append(battleData.ExitPlayer, roleId)
-			}
-
-			if len(battleData.Players) == 0 || s.isAllRobot(battleData.Players) {
-				if round == 1 {
-					// 第一回合还没开始就退出
-					battleData.SettlementRound = -1
-				} else {
-					// 上一回合结算
-					battleData.SettlementRound = round - 1
-				}
-			}
+			s.dealExit(battleData, roleId, round)
 
 			if err := s.saveBattleData(ctx, rb, battleData); err != nil {
 				logger.Errorf("BattleLogic.Exit error:%s", err.Error())
 				return resp, err
 			}
+			BattlePushLogic.Publish(ctx, rb, deskId, BattleEventRoundResult)
 
 			// 立即退出
 			resp.State = 0
@@ -632,6 +711,29 @@ func (s *battleLogic) Exit(ctx context.Context, roleId uint64, deskId string) (*
 	return resp, nil
 }
 
+// 处理退出战斗，带走已经获得的奖金
+func (s *battleLogic) dealExit(battleData *models.BattleData, roleId uint64, round int) {
+	// 可以结算
+	playerData := s.getPlayerData(battleData, roleId)
+	playerData.Settlement = 1
+
+	battleData.Players, _ = utils.RemoveElement(battleData.Players, roleId)
+
+	if !utils.InArray(battleData.ExitPlayer, roleId) {
+		battleData.ExitPlayer = append(battleData.ExitPlayer, roleId)
+	}
+
+	if len(battleData.Players) == 0 || s.isAllRobot(battleData) {
+		if round == 1 {
+			// 第一回合还没开始就退出
+			battleData.SettlementRound = -1
+		} else {
+			// 上一回合结算
+			battleData.SettlementRound = round - 1
+		}
+	}
+}
+
 // Bet 下注
 func (s *battleLogic) Bet(ctx context.Context, roleId uint64, req *schema.BattleBetReq) (*schema.BattleSyncScoreResp, error) {
 	logger := contextx.FromLogger(ctx)
@@ -667,9 +769,6 @@ func (s *battleLogic) Bet(ctx context.Context, roleId uint64, req *schema.Battle
 		return nil, errors.NewResponseError(constant.ParametersInvalid, nil)
 	}
 
-	// 获取玩家战斗数据
-	playerData := s.getPlayerData(battleData, roleId)
-
 	if utils.InArray(battleData.Result, req.Grid) || req.Grid < 1 {
 		return nil, errors.NewResponseError(constant.ParametersInvalid, nil)
 	}
@@ -677,17 +776,13 @@ func (s *battleLogic) Bet(ctx context.Context, roleId uint64, req *schema.Battle
 	// 保存本局本回合下注数据
 	s.bet(roleId, req.Grid, round, battleData)
 
-	// 机器人下注
-	if err := s.robotBet(ctx, rb, battleData, false, round); err != nil {
-		return nil, err
-	}
-
 	if err := s.saveBattleData(ctx, rb, battleData); err != nil {
 		logger.Errorf("BattleLogic.Bet error:%s", err.Error())
 		return nil, errors.NewResponseError(constant.RDBError, nil)
 	}
+	BattlePushLogic.Publish(ctx, rb, req.DeskId, BattleEventSyncScore)
 
-	resp := s.getBattleSyncScoreResp(battleData, playerData, round)
+	resp := s.getBattleSyncScoreResp(battleData, roleId, round)
 	return resp, nil
 }
 
@@ -729,6 +824,14 @@ func (s *battleLogic) createBattleData(ctx context.Context, rb *redisbackend.Red
 	battleData.PlayerData = make(map[uint64]*models.BattlePlayerData)
 	battleData.Bonus = make(map[int]int32)
 
+	// 服务器种子，开局时只公开哈希，结算后公开种子
+	seed, err := fair.NewSeed()
+	if err != nil {
+		return nil, errors.NewResponseError(constant.UnknownError, err)
+	}
+	battleData.ServerSeed = seed
+	battleData.SeedHash = fair.Hash(seed)
+
 	jsonData, err := json.Marshal(battleData)
 	if err != nil {
 		return nil, errors.NewResponseError(constant.JsonMarshalError, err)
@@ -738,6 +841,11 @@ func (s *battleLogic) createBattleData(ctx context.Context, rb *redisbackend.Red
 		return nil, errors.NewResponseError(constant.RDBError, err)
 	}
 
+	// 交给调度器推进房间状态
+	if err := rb.Client().ZAdd(ctx, BattleScheduleKey, redis.Z{Score: float64(battleData.CreateAt), Member: deskId}).Err(); err != nil {
+		return nil, errors.NewResponseError(constant.RDBError, err)
+	}
+
 	return battleData, nil
 }
 
@@ -817,27 +925,14 @@ func (s *battleLogic) getBaseBonus(battleData *models.BattleData) int32 {
 }
 
 func (s *battleLogic) BattleSyncScore(ctx context.Context, roleId uint64, deskId string) (*schema.BattleSyncScoreResp, error) {
-	logger := contextx.FromLogger(ctx)
 	rb := contextx.FromRB(ctx)
-	// 分布式锁
-	m := rb.NewMutex(deskId)
-	if err := m.Lock(ctx); err != nil {
-		logger.Errorf("BattleLogic.BattleSyncScore error:%s", err.Error())
-		return nil, errors.NewResponseError(constant.ServerBusy, err)
-	}
-
-	defer func() {
-		if _, err := m.Unlock(context.Background()); err != nil {
-			logger.WithError(err).Error("error on mutex unlock")
-		}
-	}()
 
 	battleData, err := s.getBattleData(ctx, rb, deskId)
 	// 检查错误
 	if err != nil {
 		if errors.Is(err, redis.Nil) {
 			// 房间已经解散
-			return nil, nil
+			return nil, errors.NewResponseError(constant.BattleAlreadyDismiss, nil)
 		}
 		return nil, err
 	}
@@ -848,28 +943,22 @@ func (s *battleLogic) BattleSyncScore(ctx context.Context, roleId uint64, deskId
 		return nil, errors.NewResponseError(constant.ParametersInvalid, nil)
 	}
 
-	// 机器人下注
-	if err := s.robotBet(ctx, rb, battleData, true, round); err != nil {
-		return nil, err
-	}
+	return s.getBattleSyncScoreResp(battleData, roleId, round), nil
+}
 
+func (s *battleLogic) getBattleSyncScoreResp(battleData *models.BattleData, roleId uint64, round int) *schema.BattleSyncScoreResp {
 	playerData := s.getPlayerData(battleData, roleId)
-	return s.getBattleSyncScoreResp(battleData, playerData, round), err
-}
 
-func (s *battleLogic) getBattleSyncScoreResp(battleData *models.BattleData, playerData *models.BattlePlayerData, round int) *schema.BattleSyncScoreResp {
 	resp := new(schema.BattleSyncScoreResp)
-	resp.ScoreList = make(map[int32]int32)
-	resp.PetList = make(map[int32][]int32)
 	resp.Grid = -1
 
 	// 统计分数
-	for _, playerData := range battleData.PlayerData {
-		if len(playerData.Bet) >= round {
-			grid := playerData.Bet[round-1]
-			resp.PetList[grid] = append(resp.PetList[grid], playerData.PetId)
-			resp.ScoreList[grid] = resp.ScoreList[grid] + battleData.Bonus[round-1]
-		}
+	resp.ScoreList, resp.PetList, resp.PetAbility = s.getGridOccupancy(battleData, round)
+
+	// 下注前看到一个安全的格子
+	if playerData.Ability == cfg.PetAbilityType_Scout && utils.InArray(battleData.Players, roleId) && len(battleData.Result) < round {
+		battleConfig := s.tables.BattleConfigTb.Get(battleData.BattleId)
+		resp.SafeGrid = s.getSafeGrid(battleConfig, battleData.ServerSeed, s.getGridList(battleData), round, roleId, battleData.PlayerData)
 	}
 
 	// 排除自己的宠物
@@ -955,20 +1044,7 @@ func (s *battleLogic) isBattleEnd(battleData *models.BattleData, round int) bool
 
 // GetRoundResult 获取回合结果
 func (s *battleLogic) GetRoundResult(ctx context.Context, roleId uint64, deskId string) (*schema.BattleRoundResultResp, error) {
-	logger := contextx.FromLogger(ctx)
 	rb := contextx.FromRB(ctx)
-	// 分布式锁
-	m := rb.NewMutex(deskId)
-	if err := m.Lock(ctx); err != nil {
-		logger.Errorf("BattleLogic.GetRoundResult error:%s", err.Error())
-		return nil, errors.NewResponseError(constant.ServerBusy, err)
-	}
-
-	defer func() {
-		if _, err := m.Unlock(context.Background()); err != nil {
-			logger.WithError(err).Error("error on mutex unlock")
-		}
-	}()
 
 	resp := new(schema.BattleRoundResultResp)
 	battleData, err := s.getBattleData(ctx, rb, deskId)
@@ -982,78 +1058,21 @@ func (s *battleLogic) GetRoundResult(ctx context.Context, roleId uint64, deskId
 		return nil, err
 	}
 
-	// 机器人逻辑
-	if err := s.robotJoin(ctx, rb, battleData, true); err != nil {
-		return nil, err
-	}
-
-	if battleData.StartAt == 0 {
-		// 人数已满则直接开始
-		battleConfig := s.tables.BattleConfigTb.Get(battleData.BattleId)
-		if len(battleData.Players) == int(battleConfig.PlayerNum) {
-			// 倒计时到了
-			if time.Now().Unix() >= battleData.CreateAt+int64(battleConfig.MatchTime) {
-				battleData.StartAt = battleData.CreateAt + int64(battleConfig.MatchTime)
-			} else {
-				battleData.StartAt = time.Now().Unix()
-			}
-			// 保存战斗数据
-			if err := s.saveBattleData(ctx, rb, battleData); err != nil {
-				logger.Errorf("BattleLogic.GetRoundResult error:%s", err.Error())
-				return nil, err
-			}
-		} else {
-			// 倒计时到了
-			if time.Now().Unix() >= battleData.CreateAt+int64(battleConfig.MatchTime) {
-				battleData.StartAt = battleData.CreateAt + int64(battleConfig.MatchTime)
-				// 保存战斗数据
-				if err := s.saveBattleData(ctx, rb, battleData); err != nil {
-					logger.Errorf("BattleLogic.GetRoundResult error:%s", err.Error())
-					return nil, err
-				}
-			}
-		}
-	}
+	return s.getRoundResultResp(battleData, roleId), nil
+}
 
+func (s *battleLogic) getRoundResultResp(battleData *models.BattleData, roleId uint64) *schema.BattleRoundResultResp {
+	resp := new(schema.BattleRoundResultResp)
 	round := s.getRound(battleData)
 
 	resp.Round = round
 	if round == 0 {
 		// 游戏还没开始
 		resp.State = 2
-		return resp, nil
+		return resp
 	}
 
-	logger.Infof("BattleLogic.GetRoundResult deskId=%s, round:%d", deskId, round)
-
-	roundStartTime := s.getRoundStartTime(battleData, round)
-	resp.RoundStartTime = roundStartTime
-
-	n := time.Now().Unix() - roundStartTime
-	if len(battleData.Result) < round && len(battleData.Players) > 0 {
-		calcRound := 0
-		if n >= 0 {
-			calcRound = round
-		} else if len(battleData.Result) < round-1 { // 上一回合
-			calcRound = round - 1
-		}
-
-		logger.Infof("BattleLogic.GetRoundResult deskId=%s, n=%d,calcRound=%d", deskId, n, calcRound)
-
-		if calcRound > 0 {
-			for i := 1; i <= calcRound; i++ {
-				s.checkBet(battleData, i)
-
-				s.calcRoundResult(roleId, battleData, i)
-			}
-			logger.Infof("BattleLogic.GetRoundResult deskId=%s, result=%v", deskId, battleData.Result)
-			// 保存战斗数据
-			if err := s.saveBattleData(ctx, rb, battleData); err != nil {
-				logger.Errorf("BattleLogic.GetRoundResult error:%s", err.Error())
-				return nil, err
-			}
-		}
-	}
+	resp.RoundStartTime = s.getRoundStartTime(battleData, round)
 
 	playerData := s.getPlayerData(battleData, roleId)
 	isEnd := s.isBattleEnd(battleData, round)
@@ -1071,7 +1090,10 @@ func (s *battleLogic) GetRoundResult(ctx context.Context, roleId uint64, deskId
 		}
 	}
 
-	resp.SyncScore = s.getBattleSyncScoreResp(battleData, playerData, resp.Round)
+	// 一次都没有下注的回合没有分数
+	if resp.Round > 0 {
+		resp.SyncScore = s.getBattleSyncScoreResp(battleData, roleId, resp.Round)
+	}
 	resp.PetId = playerData.PetId
 	resp.LeftPlayerNum = len(battleData.Players)
 
@@ -1084,7 +1106,7 @@ func (s *battleLogic) GetRoundResult(ctx context.Context, roleId uint64, deskId
 
 	resp.BattleId = battleData.BattleId
 
-	return resp, nil
+	return resp
 }
 
 func (s *battleLogic) getGridList(battleData *models.BattleData) []int32 {
@@ -1099,6 +1121,45 @@ func (s *battleLogic) getGridList(battleData *models.BattleData) []int32 {
 	return gridList
 }
 
+// 由种子确定回合杀死的格子
+func (s *battleLogic) getKillGrid(seed string, gridList []int32, round int) int32 {
+	return gridList[fair.Intn(seed, fair.KillMessage(round), len(gridList))]
+}
+
+// 回合杀死的格子，战斗引导的真实玩家一直胜利，从没有真实玩家下注的格子中由种子确定
+func (s *battleLogic) getRoundKillGrid(battleConfig *cfg.IBattleConfig, seed string, gridList []int32, round int, playerData map[uint64]*models.BattlePlayerData) int32 {
+	if battleConfig.IsGuide != 1 {
+		return s.getKillGrid(seed, gridList, round)
+	}
+	killList := gridList
+	for _, data := range playerData {
+		if data.IsRobot != 1 && len(data.Bet) >= round {
+			killList, _ = utils.RemoveElement(killList, data.Bet[round-1])
+		}
+	}
+	if len(killList) == 0 {
+		return s.getKillGrid(seed, gridList, round)
+	}
+	return s.getKillGrid(seed, killList, round)
+}
+
+// 由种子确定宠物能力看到的安全格子，安全格子一定不是本回合杀死的格子
+func (s *battleLogic) getSafeGrid(battleConfig *cfg.IBattleConfig, seed string, gridList []int32, round int, roleId uint64, playerData map[uint64]*models.BattlePlayerData) int32 {
+	safeList, _ := utils.RemoveElement(gridList, s.getRoundKillGrid(battleConfig, seed, gridList, round, playerData))
+	if len(safeList) == 0 {
+		return 0
+	}
+	return safeList[fair.Intn(seed, fair.ScoutMessage(round, roleId), len(safeList))]
+}
+
+// 宠物能力的奖金加成
+func (s *battleLogic) getAbilityBonus(playerData *models.BattlePlayerData, bonus int32) int32 {
+	if playerData.Ability != cfg.PetAbilityType_Bonus || playerData.AbilityVal <= 0 {
+		return bonus
+	}
+	return int32(int64(bonus) * int64(10000+playerData.AbilityVal) / 10000)
+}
+
 // 检查下注
 func (s *battleLogic) checkBet(battleData *models.BattleData, round int) {
 	gridList := s.getGridList(battleData)
@@ -1108,39 +1169,29 @@ func (s *battleLogic) checkBet(battleData *models.BattleData, round int) {
 		var grid int32
 		// 没有下注，随机下注
 		if len(playerData.Bet) < round {
-			// 随机格子
-			grid = gridList[s.xorshift.Intn(len(gridList))]
+			// 由种子确定格子
+			grid = gridList[fair.Intn(battleData.ServerSeed, fair.BetMessage(round, roleId), len(gridList))]
 			// 保存下注信息
 			s.bet(roleId, grid, round, battleData)
 		}
 	}
 }
 
-func (s *battleLogic) calcRoundResult(rid uint64, battleData *models.BattleData, round int) {
+func (s *battleLogic) calcRoundResult(battleData *models.BattleData, round int) {
 	// 本回合还没结算
 	if len(battleData.Result) < round {
 		gridList := s.getGridList(battleData)
-		battleData.Result = append(battleData.Result, gridList[s.xorshift.Intn(len(gridList))]) // 杀死的格子
 		battleConfig := s.tables.BattleConfigTb.Get(battleData.BattleId)
-		n := rand.Int() % 8
-		for i := 0; i < n; i++ {
-			s.xorshift.Intn(len(gridList))
-		}
+		battleData.Result = append(battleData.Result, s.getRoundKillGrid(battleConfig, battleData.ServerSeed, gridList, round, battleData.PlayerData)) // 杀死的格子
 
 		players := utils.DeepCopyArray(battleData.Players)
 		for _, roleId := range players {
 			playerData := s.getPlayerData(battleData, roleId)
 			grid := playerData.Bet[round-1]
 
-			// 战斗引导则一直胜利
-			if battleConfig.IsGuide == 1 && roleId == rid {
-				var k int32
-				for k = 1; k <= battleConfig.GridNum; k++ {
-					if utils.IndexOf(battleData.Result, k) == -1 && grid != k {
-						battleData.Result[round-1] = k
-						break
-					}
-				}
+			// 记录看到的安全格子，用于审计
+			if playerData.Ability == cfg.PetAbilityType_Scout {
+				playerData.SafeGrid = append(playerData.SafeGrid, s.getSafeGrid(battleConfig, battleData.ServerSeed, gridList, round, roleId, battleData.PlayerData))
 			}
 
 			// 要杀的格子
@@ -1149,6 +1200,10 @@ func (s *battleLogic) calcRoundResult(rid uint64, battleData *models.BattleData,
 			// 胜利
 			if grid != result {
 				playerData.Win = 1
+			} else if playerData.Ability == cfg.PetAbilityType_Survive && playerData.Survived == 0 {
+				// 宠物能力存活一次
+				playerData.Survived = int32(round)
+				playerData.Win = 1
 			} else {
 				// 个人战斗结果保存
 				playerData.Win = 0
@@ -1162,13 +1217,13 @@ func (s *battleLogic) calcRoundResult(rid uint64, battleData *models.BattleData,
 		for _, roleId := range players {
 			playerData := s.getPlayerData(battleData, roleId)
 			if playerData.Win == 1 {
-				playerData.Bonus = bonus
+				playerData.Bonus = s.getAbilityBonus(playerData, bonus)
 			}
 		}
 		// 记录每回合奖金
 		battleData.Bonus[round] = bonus
 
-		if len(battleData.Players) == 0 || s.isAllRobot(battleData.Players) {
+		if len(battleData.Players) == 0 || s.isAllRobot(battleData) {
 			battleData.SettlementRound = round
 		}
 	}
@@ -1176,8 +1231,8 @@ func (s *battleLogic) calcRoundResult(rid uint64, battleData *models.BattleData,
 
 // 是否所有玩家都结算了
 func (s *battleLogic) isAllPlayersSettlement(battleData *models.BattleData) bool {
-	for roleId, playerData := range battleData.PlayerData {
-		if !s.isRobot(roleId) && playerData.Settlement != 2 {
+	for _, playerData := range battleData.PlayerData {
+		if playerData.IsRobot != 1 && playerData.Settlement != 2 {
 			return false
 		}
 	}
@@ -1210,7 +1265,7 @@ func (s *battleLogic) Settlement(ctx context.Context, db *gorm.DB, roleId uint64
 				return nil, err
 			}
 
-			if err := models.RoleRepo.UpdateColum(ctx, db, roleId, "lastDeskId", ""); err != nil {
+			if err := models.RoleRepo.ClearLastDeskId(ctx, db, roleId, deskId); err != nil {
 				return nil, err
 			}
 
@@ -1227,16 +1282,6 @@ func (s *battleLogic) Settlement(ctx context.Context, db *gorm.DB, roleId uint64
 		return nil, err
 	}
 
-	round := s.getRound(battleData)
-	isEnd := s.isBattleEnd(battleData, round)
-	if battleData.Settlement == 0 && (isEnd || (len(battleData.Players) == 0) ||
-		(battleData.SettlementRound > 0 && s.isRoundEnd(battleData, battleData.SettlementRound)) || battleData.SettlementRound == -1) {
-		// 进行结算
-		if err := s.doSettlement(ctx, db, rb, battleData); err != nil {
-			return nil, err
-		}
-	}
-
 	// 获取个人战斗结果
 	playerData := s.getPlayerData(battleData, roleId)
 
@@ -1244,42 +1289,9 @@ func (s *battleLogic) Settlement(ctx context.Context, db *gorm.DB, roleId uint64
 	resp.Win = playerData.Win
 	resp.Bonus = playerData.Bonus
 
-	battleId := cast.ToInt32(strings.Split(deskId, "-")[0])
-	battleConfig := s.tables.BattleConfigTb.Get(battleId)
-
 	// 还未结算进行结算
 	if playerData.Settlement == 1 {
-		playerData.Settlement = 2
-
-		role, err := models.RoleRepo.Get(ctx, db, roleId)
-		if err != nil {
-			return nil, err
-		}
-
-		// 检查是否合法
-		if role.LastDeskId != deskId {
-			return nil, errors.NewResponseError(constant.BattleAlreadyDismiss, nil)
-		}
-
-		err = db.Transaction(func(db *gorm.DB) error {
-			if err := models.RoleRepo.UpdateColum(ctx, db, roleId, "lastDeskId", ""); err != nil {
-				return err
-			}
-			// 胜利奖励
-			if playerData.Win == 1 {
-				for _, rewardData := range battleConfig.Reward {
-					reward, err := UtilsLogic.AddItem(ctx, db, roleId, rewardData.Id, rewardData.Num*playerData.Bonus, rewardData.Type)
-					if err != nil {
-						return err
-					}
-					resp.Reward = reward
-				}
-			}
-
-			return nil
-		})
-
-		if err != nil {
+		if err := s.settlePlayer(ctx, db, battleData, roleId, resp); err != nil {
 			logger.Errorf("BattleLogic.Settlement error:%s", err.Error())
 			return nil, err
 		}
@@ -1301,11 +1313,72 @@ func (s *battleLogic) Settlement(ctx context.Context, db *gorm.DB, roleId uint64
 	return resp, nil
 }
 
+// 结算单个玩家，增加宠物经验并发放胜利奖励
+func (s *battleLogic) settlePlayer(ctx context.Context, db *gorm.DB, battleData *models.BattleData, roleId uint64, resp *schema.BattleSettlementResp) error {
+	deskId := battleData.DeskId
+	playerData := s.getPlayerData(battleData, roleId)
+	playerData.Settlement = 2
+
+	battleConfig := s.tables.BattleConfigTb.Get(battleData.BattleId)
+
+	// 锦标赛的奖励由锦标赛发放，进入下一轮后玩家所在的房间已经改变
+	isTournament := battleData.TournamentId > 0
+	if !isTournament {
+		role, err := models.RoleRepo.Get(ctx, db, roleId)
+		if err != nil {
+			return err
+		}
+
+		// 检查是否合法
+		if role.LastDeskId != deskId {
+			return errors.NewResponseError(constant.BattleAlreadyDismiss, nil)
+		}
+	}
+
+	return db.Transaction(func(db *gorm.DB) error {
+		if err := models.RoleRepo.ClearLastDeskId(ctx, db, roleId, deskId); err != nil {
+			return err
+		}
+		// 参战的宠物已经消耗，存活的回合给同种宠物增加经验
+		rounds := s.survivedRounds(battleData, playerData)
+		if rounds > 0 && playerData.PetId > 0 {
+			if err := PetLogic.AddBattleExp(ctx, db, roleId, playerData.PetId, rounds); err != nil {
+				return err
+			}
+		}
+		// 胜利奖励
+		if playerData.Win == 1 && !isTournament && battleConfig != nil {
+			for _, rewardData := range battleConfig.Reward {
+				reward, err := UtilsLogic.AddItem(ctx, db, roleId, rewardData.Id, rewardData.Num*playerData.Bonus, rewardData.Type, constant.SourceBattleSettlement)
+				if err != nil {
+					return err
+				}
+				resp.Reward = reward
+			}
+		}
+
+		return nil
+	})
+}
+
+// 玩家存活的回合数
+func (s *battleLogic) survivedRounds(battleData *models.BattleData, playerData *models.BattlePlayerData) int32 {
+	var rounds int32
+	for i, result := range battleData.Result {
+		if i >= len(playerData.Bet) || (playerData.Bet[i] == result && playerData.Survived != int32(i+1)) {
+			break
+		}
+		rounds++
+	}
+	return rounds
+}
+
 func (s *battleLogic) doSettlement(ctx context.Context, db *gorm.DB, rb *redisbackend.RedisBackend, battleData *models.BattleData) error {
 	if battleData.Settlement == 1 {
 		return nil
 	}
 	battleData.Settlement = 1
+	battleData.SettleAt = time.Now().Unix()
 
 	logger := contextx.FromLogger(ctx)
 
@@ -1315,18 +1388,108 @@ func (s *battleLogic) doSettlement(ctx context.Context, db *gorm.DB, rb *redisba
 		playerData.Settlement = 1
 	}
 
-	// 保存战斗数据
-	if err := s.saveBattleData(ctx, rb, battleData); err != nil {
+	// 先保存战斗结果和玩家的参战记录到数据库，失败时redis中还是未结算，下次调度重试
+	// 数据库已经保存过则跳过，避免保存redis失败重试时重复写入
+	battleResult := new(models.BattleResult)
+	utils.Copy(battleResult, battleData)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if _, err := models.BattleResultRepo.FindOneByDeskId(ctx, tx, battleData.DeskId); err == nil {
+			return nil
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if err := models.BattleResultRepo.Save(ctx, tx, battleResult); err != nil {
+			return err
+		}
+		return models.BattleRecordRepo.Create(ctx, tx, s.getBattleRecords(battleData))
+	})
+	if err != nil {
 		logger.Errorf("BattleLogic.doSettlement error:%s", err.Error())
 		return err
 	}
 
-	// 保存战斗结果到数据库
-	battleResult := new(models.BattleResult)
-	utils.Copy(battleResult, battleData)
-	if err := models.BattleResultRepo.Save(ctx, db, battleResult); err != nil {
+	// 保存战斗数据
+	if err := s.saveBattleData(ctx, rb, battleData); err != nil {
 		logger.Errorf("BattleLogic.doSettlement error:%s", err.Error())
 		return err
 	}
 	return nil
 }
+
+// 每个真实玩家的参战记录
+func (s *battleLogic) getBattleRecords(battleData *models.BattleData) []*models.BattleRecord {
+	now := time.Now().Unix()
+	records := make([]*models.BattleRecord, 0, len(battleData.PlayerData))
+	for roleId, playerData := range battleData.PlayerData {
+		if playerData.IsRobot == 1 {
+			continue
+		}
+		// 锦标赛房间不发放奖金，记录为0
+		bonus := playerData.Bonus
+		if battleData.TournamentId > 0 {
+			bonus = 0
+		}
+		records = append(records, &models.BattleRecord{
+			RoleID:       roleId,
+			BattleId:     battleData.BattleId,
+			DeskId:       battleData.DeskId,
+			PetID:        playerData.PetId,
+			Rounds:       s.survivedRounds(battleData, playerData),
+			Win:          playerData.Win,
+			Bonus:        bonus,
+			TournamentId: battleData.TournamentId,
+			CreatedAt:    now,
+		})
+	}
+	return records
+}
+
+// Verify 验证已结束的战斗，用公开的种子重新计算每回合杀死的格子
+func (s *battleLogic) Verify(ctx context.Context, db *gorm.DB, deskId string) (*schema.BattleVerifyResp, error) {
+	battleResult, err := models.BattleResultRepo.FindOneByDeskId(ctx, db, deskId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.NewResponseError(constant.BattleNotFinished, nil)
+		}
+		return nil, err
+	}
+
+	battleConfig := s.tables.BattleConfigTb.Get(battleResult.BattleId)
+	if battleConfig == nil {
+		return nil, errors.NewResponseError(constant.ParametersInvalid, nil)
+	}
+
+	resp := new(schema.BattleVerifyResp)
+	resp.DeskId = battleResult.DeskId
+	resp.BattleId = battleResult.BattleId
+	resp.ServerSeed = battleResult.ServerSeed
+	resp.SeedHash = battleResult.SeedHash
+	resp.Result = battleResult.Result
+	resp.Verified = fair.Hash(battleResult.ServerSeed) == battleResult.SeedHash
+
+	// 按回合重新计算
+	gridList := make([]int32, 0)
+	var k int32
+	for k = 1; k <= battleConfig.GridNum; k++ {
+		gridList = append(gridList, k)
+	}
+	for i, result := range battleResult.Result {
+		if s.getRoundKillGrid(battleConfig, battleResult.ServerSeed, gridList, i+1, battleResult.PlayerData) != result {
+			resp.Verified = false
+			break
+		}
+
+		// 宠物能力的结果
+		for roleId, playerData := range battleResult.PlayerData {
+			if len(playerData.SafeGrid) > i && s.getSafeGrid(battleConfig, battleResult.ServerSeed, gridList, i+1, roleId, battleResult.PlayerData) != playerData.SafeGrid[i] {
+				resp.Verified = false
+			}
+			if playerData.Survived == int32(i+1) && (playerData.Ability != cfg.PetAbilityType_Survive || len(playerData.Bet) <= i || playerData.Bet[i] != result) {
+				resp.Verified = false
+			}
+		}
+		gridList, _ = utils.RemoveElement(gridList, result)
+	}
+
+	return resp, nil
+}
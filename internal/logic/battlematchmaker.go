@@ -0,0 +1,311 @@
+package logic
+
+import (
+	"context"
+	"eggServer/internal/constant"
+	"eggServer/internal/contextx"
+	cfg "eggServer/internal/gamedata"
+	"eggServer/internal/schema"
+	"eggServer/pkg/errors"
+	"eggServer/pkg/redisbackend"
+	"encoding/json"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/cast"
+	"gorm.io/gorm"
+	"sort"
+	"time"
+)
+
+const (
+	battleQueueBaseBand = 10 // 刚入队时可以匹配的战斗场次差
+	battleQueueBandStep = 2  // 每等待一秒增加的战斗场次差
+)
+
+// 匹配队列中的玩家，分数是战斗场次
+type battleQueuePlayer struct {
+	RoleId   uint64 `json:"-"`
+	PetId    int32  `json:"1"`
+	Rating   int32  `json:"2"`
+	QueuedAt int64  `json:"3"`
+}
+
+// 加入匹配队列，已经在队列中只更新宠物，保留入队时间
+// 同一时间只能在一个战斗的队列中，已经在其他战斗的队列中时拒绝
+func (s *battleLogic) enqueue(ctx context.Context, rb *redisbackend.RedisBackend, roleId uint64, battleId int32, petId int32, rating int32) (*schema.BattleMatchStateResp, error) {
+	infoKey := fmt.Sprintf(BattleQueueInfoKey, battleId)
+	player := &battleQueuePlayer{RoleId: roleId, PetId: petId, Rating: rating, QueuedAt: time.Now().Unix()}
+
+	roleKey := fmt.Sprintf(BattleQueueRoleKey, roleId)
+	ok, err := rb.Client().SetNX(ctx, roleKey, battleId, 0).Result()
+	if err != nil {
+		return nil, errors.NewResponseError(constant.RDBError, err)
+	}
+	if !ok {
+		queued, err := rb.Client().Get(ctx, roleKey).Int()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return nil, errors.NewResponseError(constant.RDBError, err)
+		}
+		if err == nil && queued != int(battleId) {
+			return nil, errors.NewResponseError(constant.AlreadyJoinOtherBattle, nil)
+		}
+	}
+
+	ret, err := rb.Client().HGet(ctx, infoKey, fmt.Sprint(roleId)).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, errors.NewResponseError(constant.RDBError, err)
+	}
+	if ret != "" {
+		old := new(battleQueuePlayer)
+		if err := json.Unmarshal([]byte(ret), old); err == nil {
+			player.QueuedAt = old.QueuedAt
+		}
+	}
+
+	jsonData, err := json.Marshal(player)
+	if err != nil {
+		return nil, errors.NewResponseError(constant.JsonMarshalError, err)
+	}
+
+	pipe := rb.Client().TxPipeline()
+	pipe.Set(ctx, roleKey, battleId, 0)
+	pipe.HSet(ctx, infoKey, fmt.Sprint(roleId), jsonData)
+	pipe.ZAdd(ctx, fmt.Sprintf(BattleQueueKey, battleId), redis.Z{Score: float64(rating), Member: roleId})
+	queueNum := pipe.ZCard(ctx, fmt.Sprintf(BattleQueueKey, battleId))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, errors.NewResponseError(constant.RDBError, err)
+	}
+
+	resp := new(schema.BattleMatchStateResp)
+	resp.BattleId = battleId
+	resp.CreatedAt = player.QueuedAt
+	resp.PlayerNum = int(queueNum.Val())
+	resp.MaxPlayerNum = int(s.tables.BattleConfigTb.Get(battleId).PlayerNum)
+	resp.Queued = 1
+	return resp, nil
+}
+
+// CancelMatch 退出匹配队列，已经分配房间的需要通过离开房间退出
+func (s *battleLogic) CancelMatch(ctx context.Context, roleId uint64, battleId int32) error {
+	logger := contextx.FromLogger(ctx)
+	rb := contextx.FromRB(ctx)
+	if s.tables.BattleConfigTb.Get(battleId) == nil {
+		return errors.NewResponseError(constant.ParametersInvalid, nil)
+	}
+
+	// 和调度器抢占队列信息，删除成功的一方处理该玩家
+	n, err := rb.Client().HDel(ctx, fmt.Sprintf(BattleQueueInfoKey, battleId), fmt.Sprint(roleId)).Result()
+	if err != nil {
+		logger.Errorf("BattleLogic.CancelMatch error:%s", err.Error())
+		return errors.NewResponseError(constant.RDBError, err)
+	}
+	if err := rb.Client().ZRem(ctx, fmt.Sprintf(BattleQueueKey, battleId), roleId).Err(); err != nil {
+		logger.Errorf("BattleLogic.CancelMatch error:%s", err.Error())
+	}
+	if err := s.clearQueueRole(ctx, rb, roleId, battleId); err != nil {
+		logger.Errorf("BattleLogic.CancelMatch error:%s", err.Error())
+	}
+	if n == 0 {
+		return errors.NewResponseError(constant.NotInMatchQueue, nil)
+	}
+	return nil
+}
+
+// 清除玩家所在的队列，只清除该战斗的
+func (s *battleLogic) clearQueueRole(ctx context.Context, rb *redisbackend.RedisBackend, roleId uint64, battleId int32) error {
+	roleKey := fmt.Sprintf(BattleQueueRoleKey, roleId)
+	queued, err := rb.Client().Get(ctx, roleKey).Int()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil
+		}
+		return err
+	}
+	if queued != int(battleId) {
+		return nil
+	}
+	return rb.Client().Del(ctx, roleKey).Err()
+}
+
+// 处理所有公开战斗的匹配队列
+func (s *battleLogic) matchmake(ctx context.Context, db *gorm.DB, rb *redisbackend.RedisBackend) {
+	logger := contextx.FromLogger(ctx)
+	for _, battleConfig := range s.tables.BattleConfigTb.GetDataList() {
+		if battleConfig.IsGuide == 1 || s.tournamentBattle[battleConfig.Id] {
+			continue
+		}
+		if err := s.matchmakeBattle(ctx, db, rb, battleConfig); err != nil {
+			logger.Errorf("BattleLogic.matchmake battleId=%d error:%s", battleConfig.Id, err.Error())
+		}
+	}
+}
+
+// 按战斗场次分组并批量创建房间，同一时间只有一个实例处理同一个队列
+func (s *battleLogic) matchmakeBattle(ctx context.Context, db *gorm.DB, rb *redisbackend.RedisBackend, battleConfig *cfg.IBattleConfig) error {
+	logger := contextx.FromLogger(ctx)
+	m := rb.NewMutex(fmt.Sprintf(BattleMatchKey, battleConfig.Id))
+	if err := m.TryLock(ctx); err != nil {
+		return nil
+	}
+
+	defer func() {
+		if _, err := m.Unlock(context.Background()); err != nil {
+			logger.WithError(err).Error("error on mutex unlock")
+		}
+	}()
+
+	queueKey := fmt.Sprintf(BattleQueueKey, battleConfig.Id)
+	infoKey := fmt.Sprintf(BattleQueueInfoKey, battleConfig.Id)
+	roleIds, err := rb.Client().ZRange(ctx, queueKey, 0, -1).Result()
+	if err != nil || len(roleIds) == 0 {
+		return err
+	}
+
+	values, err := rb.Client().HMGet(ctx, infoKey, roleIds...).Result()
+	if err != nil {
+		return err
+	}
+
+	list := make([]*battleQueuePlayer, 0, len(roleIds))
+	for i, v := range values {
+		player := new(battleQueuePlayer)
+		str, ok := v.(string)
+		if !ok || json.Unmarshal([]byte(str), player) != nil {
+			// 已经退出队列
+			if err := rb.Client().ZRem(ctx, queueKey, roleIds[i]).Err(); err != nil {
+				return err
+			}
+			continue
+		}
+		player.RoleId = cast.ToUint64(roleIds[i])
+		list = append(list, player)
+	}
+
+	for _, group := range s.groupQueue(list, int(battleConfig.PlayerNum), int64(battleConfig.MatchTime), time.Now().Unix()) {
+		if err := s.createQueuedDesk(ctx, db, rb, battleConfig.Id, group); err != nil {
+			logger.Errorf("BattleLogic.matchmakeBattle error:%s", err.Error())
+		}
+	}
+	return nil
+}
+
+// 可以匹配的战斗场次差，等待越久范围越大
+func (s *battleLogic) getQueueBand(wait int64) int64 {
+	return battleQueueBaseBand + battleQueueBandStep*wait
+}
+
+// 分组，队列按战斗场次升序排列
+// 人数满了或者等待最久的玩家超过匹配时间才成组，不足的人数由机器人补充
+func (s *battleLogic) groupQueue(list []*battleQueuePlayer, playerNum int, matchTime int64, now int64) [][]*battleQueuePlayer {
+	groups := make([][]*battleQueuePlayer, 0)
+	used := make([]bool, len(list))
+	for i := range list {
+		if used[i] {
+			continue
+		}
+
+		members := []int{i}
+		oldest := list[i].QueuedAt
+		for j := i + 1; j < len(list) && len(members) < playerNum; j++ {
+			if used[j] {
+				continue
+			}
+			// 按两人中等待较久的计算范围
+			queuedAt := list[i].QueuedAt
+			if list[j].QueuedAt < queuedAt {
+				queuedAt = list[j].QueuedAt
+			}
+			if int64(list[j].Rating-list[i].Rating) > s.getQueueBand(now-queuedAt) {
+				continue
+			}
+			members = append(members, j)
+			if list[j].QueuedAt < oldest {
+				oldest = list[j].QueuedAt
+			}
+		}
+
+		if len(members) < playerNum && now-oldest < matchTime {
+			continue
+		}
+
+		group := make([]*battleQueuePlayer, 0, len(members))
+		for _, j := range members {
+			used[j] = true
+			group = append(group, list[j])
+		}
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+// 为一组玩家创建房间，调度器按房间的创建时间补充机器人和开始战斗
+func (s *battleLogic) createQueuedDesk(ctx context.Context, db *gorm.DB, rb *redisbackend.RedisBackend, battleId int32, group []*battleQueuePlayer) error {
+	logger := contextx.FromLogger(ctx)
+	queueKey := fmt.Sprintf(BattleQueueKey, battleId)
+	infoKey := fmt.Sprintf(BattleQueueInfoKey, battleId)
+
+	// 抢占队列信息，已经退出队列的玩家跳过
+	players := make([]*battleQueuePlayer, 0, len(group))
+	for _, player := range group {
+		n, err := rb.Client().HDel(ctx, infoKey, fmt.Sprint(player.RoleId)).Result()
+		if err != nil {
+			return err
+		}
+		if err := rb.Client().ZRem(ctx, queueKey, player.RoleId).Err(); err != nil {
+			return err
+		}
+		if n > 0 {
+			if err := s.clearQueueRole(ctx, rb, player.RoleId, battleId); err != nil {
+				logger.Errorf("BattleLogic.createQueuedDesk roleId=%d error:%s", player.RoleId, err.Error())
+			}
+			players = append(players, player)
+		}
+	}
+	if len(players) == 0 {
+		return nil
+	}
+	sort.Slice(players, func(i, j int) bool {
+		return players[i].QueuedAt < players[j].QueuedAt
+	})
+
+	ret, err := rb.Client().Incr(ctx, fmt.Sprintf(BattleDeskIdKey, battleId)).Result()
+	if err != nil {
+		return err
+	}
+	deskId := fmt.Sprintf("%d-%d", battleId, ret)
+
+	// 分布式锁，房间设置完成前调度器不能处理
+	m := rb.NewMutex(deskId)
+	if err := m.Lock(ctx); err != nil {
+		return err
+	}
+
+	defer func() {
+		if _, err := m.Unlock(context.Background()); err != nil {
+			logger.WithError(err).Error("error on mutex unlock")
+		}
+	}()
+
+	// 创建时间为当前时间，匹配倒计时重新开始，机器人按倒计时逐渐补充
+	battleData, err := s.createBattleData(ctx, rb, battleId, deskId)
+	if err != nil {
+		return err
+	}
+
+	for _, player := range players {
+		// 排队期间加入了其他房间或者消耗了宠物的玩家不能入座
+		if _, err := s.takeSeat(ctx, db, player.RoleId, battleData, player.PetId); err != nil {
+			logger.Errorf("BattleLogic.createQueuedDesk roleId=%d error:%s", player.RoleId, err.Error())
+		}
+	}
+
+	if len(battleData.Players) == 0 {
+		return s.destroyBattleData(ctx, rb, battleData)
+	}
+
+	if err := s.saveBattleData(ctx, rb, battleData); err != nil {
+		return err
+	}
+	BattlePushLogic.Publish(ctx, rb, battleData.DeskId, BattleEventMatchState)
+	return nil
+}
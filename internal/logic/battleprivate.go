@@ -0,0 +1,218 @@
+package logic
+
+import (
+	"context"
+	"crypto/rand"
+	"eggServer/internal/constant"
+	"eggServer/internal/contextx"
+	"eggServer/internal/models"
+	"eggServer/internal/schema"
+	"eggServer/pkg/errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"strings"
+	"time"
+)
+
+var (
+	BattlePrivateDeskIdKey = "battle:privateDeskId:%d"
+	BattleInviteCodeKey    = "battle:invite:%s"
+)
+
+const (
+	battleInviteCodeLen      = 6
+	battleInviteCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789" // 去掉容易混淆的字符
+	battleInviteCodeRetry    = 5
+)
+
+// 生成邀请码
+func newInviteCode() (string, error) {
+	b := make([]byte, battleInviteCodeLen)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	for i := range b {
+		b[i] = battleInviteCodeAlphabet[int(b[i])%len(battleInviteCodeAlphabet)]
+	}
+	return string(b), nil
+}
+
+// Create 创建私人房间，其他玩家通过邀请码加入
+func (s *battleLogic) Create(ctx context.Context, db *gorm.DB, roleId uint64, req *schema.BattleCreateReq) (*schema.BattleMatchResp, error) {
+	logger := contextx.FromLogger(ctx)
+	rb := contextx.FromRB(ctx)
+	battleConfig := s.tables.BattleConfigTb.Get(req.BattleId)
+	if battleConfig == nil || battleConfig.IsGuide == 1 || s.tournamentBattle[req.BattleId] {
+		return nil, errors.NewResponseError(constant.ParametersInvalid, nil)
+	}
+
+	playerNum := req.PlayerNum
+	if playerNum == 0 {
+		playerNum = int(battleConfig.PlayerNum)
+	}
+	if playerNum < 2 || playerNum > int(battleConfig.PlayerNum) || req.AddRobot > 1 {
+		return nil, errors.NewResponseError(constant.ParametersInvalid, nil)
+	}
+
+	num, err := rb.Client().Incr(ctx, fmt.Sprintf(BattlePrivateDeskIdKey, req.BattleId)).Result()
+	if err != nil {
+		return nil, errors.NewResponseError(constant.RDBError, err)
+	}
+	deskId := fmt.Sprintf("%d-p%d", req.BattleId, num)
+
+	// 分布式锁，房间设置完成前调度器不能处理
+	m := rb.NewMutex(deskId)
+	if err := m.Lock(ctx); err != nil {
+		logger.Errorf("BattleLogic.Create error:%s", err.Error())
+		return nil, errors.NewResponseError(constant.ServerBusy, err)
+	}
+
+	defer func() {
+		if _, err := m.Unlock(context.Background()); err != nil {
+			logger.WithError(err).Error("error on mutex unlock")
+		}
+	}()
+
+	// 邀请码在匹配时间内有效
+	var code string
+	for i := 0; i < battleInviteCodeRetry && code == ""; i++ {
+		c, err := newInviteCode()
+		if err != nil {
+			return nil, errors.NewResponseError(constant.UnknownError, err)
+		}
+		ok, err := rb.Client().SetNX(ctx, fmt.Sprintf(BattleInviteCodeKey, c), deskId, time.Duration(battleConfig.MatchTime)*time.Second).Result()
+		if err != nil {
+			return nil, errors.NewResponseError(constant.RDBError, err)
+		}
+		if ok {
+			code = c
+		}
+	}
+	if code == "" {
+		return nil, errors.NewResponseError(constant.ServerBusy, nil)
+	}
+
+	battleData, err := s.createBattleData(ctx, rb, req.BattleId, deskId)
+	if err != nil {
+		logger.Errorf("BattleLogic.Create error:%s", err.Error())
+		return nil, err
+	}
+	battleData.Creator = roleId
+	battleData.InviteCode = code
+	battleData.PlayerNum = playerNum
+	battleData.AddRobot = req.AddRobot
+
+	resp, err := s.join(ctx, db, rb, roleId, battleData, req.PetId)
+	if err != nil {
+		// 创建者没有加入则房间无效
+		if err := s.destroyBattleData(ctx, rb, battleData); err != nil {
+			logger.Errorf("BattleLogic.Create error:%s", err.Error())
+		}
+		if err := rb.Client().Del(ctx, fmt.Sprintf(BattleInviteCodeKey, code)).Err(); err != nil {
+			logger.Errorf("BattleLogic.Create error:%s", err.Error())
+		}
+		return nil, err
+	}
+	return resp, nil
+}
+
+// JoinByCode 通过邀请码加入私人房间
+func (s *battleLogic) JoinByCode(ctx context.Context, db *gorm.DB, roleId uint64, req *schema.BattleJoinReq) (*schema.BattleMatchResp, error) {
+	logger := contextx.FromLogger(ctx)
+	rb := contextx.FromRB(ctx)
+
+	deskId, err := rb.Client().Get(ctx, fmt.Sprintf(BattleInviteCodeKey, strings.ToUpper(req.InviteCode))).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, errors.NewResponseError(constant.InviteCodeInvalid, nil)
+		}
+		return nil, errors.NewResponseError(constant.RDBError, err)
+	}
+
+	// 分布式锁
+	m := rb.NewMutex(deskId)
+	if err := m.Lock(ctx); err != nil {
+		logger.Errorf("BattleLogic.JoinByCode error:%s", err.Error())
+		return nil, errors.NewResponseError(constant.ServerBusy, err)
+	}
+
+	defer func() {
+		if _, err := m.Unlock(context.Background()); err != nil {
+			logger.WithError(err).Error("error on mutex unlock")
+		}
+	}()
+
+	battleData, err := s.getBattleData(ctx, rb, deskId)
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, errors.NewResponseError(constant.InviteCodeInvalid, nil)
+		}
+		return nil, err
+	}
+
+	return s.join(ctx, db, rb, roleId, battleData, req.PetId)
+}
+
+// Start 私人房间的创建者提前开始战斗
+func (s *battleLogic) Start(ctx context.Context, roleId uint64, deskId string) (*schema.BattleMatchStateResp, error) {
+	logger := contextx.FromLogger(ctx)
+	rb := contextx.FromRB(ctx)
+
+	// 分布式锁
+	m := rb.NewMutex(deskId)
+	if err := m.Lock(ctx); err != nil {
+		logger.Errorf("BattleLogic.Start error:%s", err.Error())
+		return nil, errors.NewResponseError(constant.ServerBusy, err)
+	}
+
+	defer func() {
+		if _, err := m.Unlock(context.Background()); err != nil {
+			logger.WithError(err).Error("error on mutex unlock")
+		}
+	}()
+
+	battleData, err := s.getBattleData(ctx, rb, deskId)
+	// 检查错误
+	if err := s.checkError(battleData, roleId, err); err != nil {
+		logger.Errorf("BattleLogic.Start error:%s", err.Error())
+		return nil, err
+	}
+
+	if battleData.Creator != roleId {
+		return nil, errors.NewResponseError(constant.NotBattleCreator, nil)
+	}
+
+	// 已经开始战斗
+	if s.isBattleStart(battleData) {
+		return nil, errors.NewResponseError(constant.BattleAlreadyStarted, nil)
+	}
+
+	// 机器人补满空位
+	if s.isAddRobot(battleData) {
+		s.addRobots(battleData, s.getPlayerNum(battleData)-len(battleData.Players))
+	}
+
+	if len(battleData.Players) < 2 {
+		return nil, errors.NewResponseError(constant.BattleMemberNotEnough, nil)
+	}
+
+	battleData.StartAt = time.Now().Unix()
+	if err := s.saveBattleData(ctx, rb, battleData); err != nil {
+		logger.Errorf("BattleLogic.Start error:%s", err.Error())
+		return nil, err
+	}
+
+	// 开始后不能再加入
+	if err := rb.Client().Del(ctx, fmt.Sprintf(BattleInviteCodeKey, battleData.InviteCode)).Err(); err != nil {
+		logger.Errorf("BattleLogic.Start error:%s", err.Error())
+	}
+	BattlePushLogic.Publish(ctx, rb, deskId, BattleEventMatchState, BattleEventRoundResult)
+
+	return s.getBattleMatchStateResp(roleId, battleData), nil
+}
+
+// 是否是私人房间
+func (s *battleLogic) isPrivate(battleData *models.BattleData) bool {
+	return battleData.Creator > 0
+}
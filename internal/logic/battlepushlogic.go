@@ -0,0 +1,204 @@
+package logic
+
+import (
+	"context"
+	"eggServer/internal/constant"
+	"eggServer/internal/contextx"
+	cfg "eggServer/internal/gamedata"
+	"eggServer/internal/schema"
+	"eggServer/pkg/errors"
+	"eggServer/pkg/redisbackend"
+	"eggServer/pkg/utils"
+	"encoding/json"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"sync"
+)
+
+var (
+	BattlePushKey = "battle:push:%s"
+)
+
+const (
+	BattleEventMatchState  byte = iota + 1 // 匹配状态
+	BattleEventSyncScore                   // 同步分数
+	BattleEventRoundResult                 // 回合结果
+	BattleEventSpectate                    // 观战
+)
+
+var BattlePushLogic = new(battlePushLogic)
+
+type battlePushLogic struct {
+	tables      *cfg.Tables
+	mu          sync.RWMutex
+	subscribers map[string]map[*BattleSubscriber]struct{}
+}
+
+// BattleSubscriber 订阅房间推送的玩家
+type BattleSubscriber struct {
+	RoleId uint64
+	DeskId string
+	C      chan byte // 需要推送的事件
+}
+
+// 通过redis广播的消息
+type battlePushMsg struct {
+	DeskId string `json:"deskId"`
+	Events []byte `json:"events"`
+}
+
+func (s *battlePushLogic) Init(tables *cfg.Tables) {
+	s.tables = tables
+	s.subscribers = make(map[string]map[*BattleSubscriber]struct{})
+}
+
+// Publish 通知所有实例推送房间事件
+func (s *battlePushLogic) Publish(ctx context.Context, rb *redisbackend.RedisBackend, deskId string, events ...byte) {
+	logger := contextx.FromLogger(ctx)
+	if len(events) == 0 {
+		return
+	}
+
+	val, err := json.Marshal(&battlePushMsg{DeskId: deskId, Events: events})
+	if err != nil {
+		logger.Errorf("BattlePushLogic.Publish error:%s", err.Error())
+		return
+	}
+
+	if err := rb.Client().Publish(ctx, fmt.Sprintf(BattlePushKey, deskId), val).Err(); err != nil {
+		logger.Errorf("BattlePushLogic.Publish error:%s", err.Error())
+	}
+}
+
+// Subscribe 订阅房间推送，收到的消息由Run分发
+func (s *battlePushLogic) Subscribe(roleId uint64, deskId string) *BattleSubscriber {
+	sub := &BattleSubscriber{
+		RoleId: roleId,
+		DeskId: deskId,
+		C:      make(chan byte, 16),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.subscribers[deskId] == nil {
+		s.subscribers[deskId] = make(map[*BattleSubscriber]struct{})
+	}
+	s.subscribers[deskId][sub] = struct{}{}
+	return sub
+}
+
+// Unsubscribe 取消订阅
+func (s *battlePushLogic) Unsubscribe(sub *BattleSubscriber) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.subscribers[sub.DeskId], sub)
+	if len(s.subscribers[sub.DeskId]) == 0 {
+		delete(s.subscribers, sub.DeskId)
+	}
+}
+
+// BuildConnect 连接时推送的数据，被淘汰的玩家还在房间中，推送回合结果直到结算
+func (s *battlePushLogic) BuildConnect(ctx context.Context, roleId uint64, deskId string) (*schema.BattlePushResp, error) {
+	GameDataLogic.RLock()
+	defer GameDataLogic.RUnlock()
+
+	battleData, err := BattleLogic.getBattleData(ctx, contextx.FromRB(ctx), deskId)
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			// 房间已经解散
+			return nil, errors.NewResponseError(constant.BattleAlreadyDismiss, nil)
+		}
+		return nil, err
+	}
+
+	// 只能订阅自己参加的房间
+	if _, ok := battleData.PlayerData[roleId]; !ok {
+		return nil, errors.NewResponseError(constant.BattleYouNotInRound, nil)
+	}
+	if utils.InArray(battleData.Players, roleId) {
+		return &schema.BattlePushResp{Event: BattleEventMatchState, Data: BattleLogic.getBattleMatchStateResp(roleId, battleData)}, nil
+	}
+	return &schema.BattlePushResp{Event: BattleEventRoundResult, Data: BattleLogic.getRoundResultResp(battleData, roleId)}, nil
+}
+
+// Build 生成玩家的推送数据
+func (s *battlePushLogic) Build(ctx context.Context, roleId uint64, deskId string, event byte) (*schema.BattlePushResp, error) {
+	GameDataLogic.RLock()
+	defer GameDataLogic.RUnlock()
+
+	var (
+		data interface{}
+		err  error
+	)
+
+	switch event {
+	case BattleEventMatchState:
+		data, err = BattleLogic.MatchState(ctx, roleId, deskId)
+	case BattleEventSyncScore:
+		data, err = BattleLogic.BattleSyncScore(ctx, roleId, deskId)
+	case BattleEventRoundResult:
+		data, err = BattleLogic.GetRoundResult(ctx, roleId, deskId)
+	default:
+		return nil, errors.NewResponseError(constant.ParametersInvalid, nil)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return &schema.BattlePushResp{Event: event, Data: data}, nil
+}
+
+// BuildSpectate 生成观战的推送数据，所有事件都推送完整的观战状态
+func (s *battlePushLogic) BuildSpectate(ctx context.Context, deskId string, inviteCode string) (*schema.BattlePushResp, error) {
+	GameDataLogic.RLock()
+	defer GameDataLogic.RUnlock()
+
+	data, err := BattleLogic.Spectate(ctx, deskId, inviteCode)
+	if err != nil {
+		return nil, err
+	}
+	return &schema.BattlePushResp{Event: BattleEventSpectate, Data: data}, nil
+}
+
+// Run 监听所有房间的推送消息，分发给本实例的玩家，随服务启动
+func (s *battlePushLogic) Run(ctx context.Context, rb *redisbackend.RedisBackend) {
+	logger := contextx.FromLogger(ctx)
+
+	pubsub := rb.Client().PSubscribe(ctx, fmt.Sprintf(BattlePushKey, "*"))
+	defer pubsub.Close()
+
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			data := new(battlePushMsg)
+			if err := json.Unmarshal([]byte(msg.Payload), data); err != nil {
+				logger.Errorf("BattlePushLogic.Run error:%s", err.Error())
+				continue
+			}
+			s.dispatch(data)
+		}
+	}
+}
+
+func (s *battlePushLogic) dispatch(data *battlePushMsg) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for sub := range s.subscribers[data.DeskId] {
+		for _, event := range data.Events {
+			select {
+			case sub.C <- event:
+			default:
+				// 客户端处理不过来则丢弃，等待下一次推送
+			}
+		}
+	}
+}
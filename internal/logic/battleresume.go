@@ -0,0 +1,114 @@
+package logic
+
+import (
+	"context"
+	"eggServer/internal/constant"
+	"eggServer/internal/contextx"
+	"eggServer/internal/models"
+	"eggServer/internal/schema"
+	"eggServer/pkg/errors"
+	"eggServer/pkg/utils"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"time"
+)
+
+// Resume 断线重连，返回玩家所在房间的完整状态
+// 房间已经被清理时从保存的战斗结果恢复
+func (s *battleLogic) Resume(ctx context.Context, db *gorm.DB, roleId uint64, deskId string) (*schema.BattleResumeResp, error) {
+	logger := contextx.FromLogger(ctx)
+	rb := contextx.FromRB(ctx)
+
+	if deskId == "" {
+		role, err := models.RoleRepo.Get(ctx, db, roleId)
+		if err != nil {
+			logger.Errorf("BattleLogic.Resume error:%s", err.Error())
+			return nil, errors.NewResponseError(constant.DatabaseError, err)
+		}
+		deskId = role.LastDeskId
+	}
+	if deskId == "" {
+		return nil, errors.NewResponseError(constant.NoBattleToResume, nil)
+	}
+
+	archived := false
+	battleData, err := s.getBattleData(ctx, rb, deskId)
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			logger.Errorf("BattleLogic.Resume error:%s", err.Error())
+			return nil, err
+		}
+
+		battleResult, err := models.BattleResultRepo.FindOneByDeskId(ctx, db, deskId)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				// 房间没有开始战斗就解散了
+				return nil, errors.NewResponseError(constant.BattleAlreadyDismiss, nil)
+			}
+			return nil, err
+		}
+		battleData = new(models.BattleData)
+		utils.Copy(battleData, battleResult)
+		archived = true
+	}
+
+	battleConfig := s.tables.BattleConfigTb.Get(battleData.BattleId)
+	if battleConfig == nil {
+		return nil, errors.NewResponseError(constant.ParametersInvalid, nil)
+	}
+
+	// 没有加入过该房间
+	playerData, ok := battleData.PlayerData[roleId]
+	if !ok || playerData == nil {
+		return nil, errors.NewResponseError(constant.BattleYouNotInRound, nil)
+	}
+
+	resp := new(schema.BattleResumeResp)
+	resp.DeskId = battleData.DeskId
+	resp.BattleId = battleData.BattleId
+	resp.ServerTime = time.Now().Unix()
+	resp.TotalRound = battleConfig.TotalRound
+	resp.MatchState = s.getBattleMatchStateResp(roleId, battleData)
+	resp.Bet = playerData.Bet
+	resp.SafeGrid = playerData.SafeGrid
+	resp.Win = playerData.Win
+	resp.Bonus = playerData.Bonus
+	resp.Settlement = playerData.Settlement
+	if utils.InArray(battleData.Players, roleId) {
+		resp.Alive = 1
+	}
+
+	if archived {
+		resp.Archived = 1
+		resp.RoundResult = s.getArchivedRoundResultResp(battleData, roleId)
+		return resp, nil
+	}
+
+	resp.RoundResult = s.getRoundResultResp(battleData, roleId)
+	if resp.RoundResult.State == 0 {
+		resp.RoundEndAt = resp.RoundResult.RoundStartTime + int64(battleConfig.RoundInterval)
+	}
+	return resp, nil
+}
+
+// 已经保存的战斗结果，战斗一定已经结束
+func (s *battleLogic) getArchivedRoundResultResp(battleData *models.BattleData, roleId uint64) *schema.BattleRoundResultResp {
+	playerData := s.getPlayerData(battleData, roleId)
+	resp := new(schema.BattleRoundResultResp)
+	resp.State = 1
+	resp.BattleId = battleData.BattleId
+	resp.PetId = playerData.PetId
+	resp.LeftPlayerNum = len(battleData.Players)
+
+	// 结束的回合
+	resp.Round = len(playerData.Bet)
+	if resp.Round > len(battleData.Result) {
+		resp.Round = len(battleData.Result)
+	}
+	resp.Result = battleData.Result[:resp.Round]
+	if resp.Round > 0 {
+		resp.RoundStartTime = s.getRoundStartTime(battleData, resp.Round)
+		resp.SyncScore = s.getBattleSyncScoreResp(battleData, roleId, resp.Round)
+	}
+	return resp
+}
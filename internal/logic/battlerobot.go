@@ -0,0 +1,163 @@
+package logic
+
+import (
+	cfg "eggServer/internal/gamedata"
+	"eggServer/internal/models"
+	"eggServer/pkg/utils/fair"
+)
+
+// 退出策略每回合退出的概率，万分比
+const robotQuitChance = 2500
+
+// 机器人策略，随机数都由服务器种子确定，同一回合重复计算结果不变
+type robotStrategy interface {
+	// 下注的时间点，回合下注时间的万分比
+	betPoint(seed string, round int, roleId uint64) int64
+	// 选择下注的格子，petNum为当前回合每个格子的宠物数量
+	pickGrid(seed string, round int, roleId uint64, gridList []int32, petNum map[int32]int) int32
+	// 下注前是否退出战斗
+	exit(seed string, round int, roleId uint64) bool
+}
+
+var robotStrategies = map[int32]robotStrategy{
+	cfg.RobotStrategyType_Random: randomRobot{},
+	cfg.RobotStrategyType_Herd:   herdRobot{},
+	cfg.RobotStrategyType_Avoid:  avoidRobot{},
+	cfg.RobotStrategyType_Late:   lateRobot{},
+	cfg.RobotStrategyType_Quit:   quitRobot{},
+}
+
+// 在[min, max)中取一个值
+func robotIntn(seed string, kind string, round int, roleId uint64, min int64, max int64) int64 {
+	return min + int64(fair.Intn(seed, fair.RobotMessage(kind, round, roleId), int(max-min)))
+}
+
+// 随机下注
+type randomRobot struct{}
+
+func (randomRobot) betPoint(seed string, round int, roleId uint64) int64 {
+	return robotIntn(seed, "at", round, roleId, 0, 10000)
+}
+
+func (randomRobot) pickGrid(seed string, round int, roleId uint64, gridList []int32, petNum map[int32]int) int32 {
+	return gridList[fair.Intn(seed, fair.BetMessage(round, roleId), len(gridList))]
+}
+
+func (randomRobot) exit(seed string, round int, roleId uint64) bool {
+	return false
+}
+
+// 等其他人下注后跟随宠物最多的格子
+type herdRobot struct {
+	randomRobot
+}
+
+func (herdRobot) betPoint(seed string, round int, roleId uint64) int64 {
+	return robotIntn(seed, "at", round, roleId, 3000, 9000)
+}
+
+func (r herdRobot) pickGrid(seed string, round int, roleId uint64, gridList []int32, petNum map[int32]int) int32 {
+	return pickGridByNum(gridList, petNum, r.randomRobot.pickGrid(seed, round, roleId, gridList, petNum), func(a, b int) bool { return a > b })
+}
+
+// 等其他人下注后避开宠物最多的格子
+type avoidRobot struct {
+	randomRobot
+}
+
+func (avoidRobot) betPoint(seed string, round int, roleId uint64) int64 {
+	return robotIntn(seed, "at", round, roleId, 3000, 9000)
+}
+
+func (r avoidRobot) pickGrid(seed string, round int, roleId uint64, gridList []int32, petNum map[int32]int) int32 {
+	return pickGridByNum(gridList, petNum, r.randomRobot.pickGrid(seed, round, roleId, gridList, petNum), func(a, b int) bool { return a < b })
+}
+
+// 回合快结束时才下注
+type lateRobot struct {
+	randomRobot
+}
+
+func (lateRobot) betPoint(seed string, round int, roleId uint64) int64 {
+	return robotIntn(seed, "at", round, roleId, 8000, 9800)
+}
+
+// 存活一回合以后有概率带着奖金退出
+type quitRobot struct {
+	randomRobot
+}
+
+func (quitRobot) exit(seed string, round int, roleId uint64) bool {
+	return round > 1 && robotIntn(seed, "exit", round, roleId, 0, 10000) < robotQuitChance
+}
+
+// 按宠物数量选择格子，数量相同时优先随机到的格子
+func pickGridByNum(gridList []int32, petNum map[int32]int, first int32, better func(a, b int) bool) int32 {
+	grid := first
+	for _, v := range gridList {
+		if better(petNum[v], petNum[grid]) {
+			grid = v
+		}
+	}
+	return grid
+}
+
+// 机器人的策略，配置修改后不存在的策略按随机下注处理
+func (s *battleLogic) getRobotStrategy(strategy int32) robotStrategy {
+	if v, ok := robotStrategies[strategy]; ok {
+		return v
+	}
+	return robotStrategies[cfg.RobotStrategyType_Random]
+}
+
+// 按战斗配置的权重随机机器人策略
+func (s *battleLogic) randomRobotStrategy(battleId int32) int32 {
+	if sampler, ok := s.robotStrategySampler[battleId]; ok {
+		return sampler.Sample(s.source)
+	}
+	return cfg.RobotStrategyType_Random
+}
+
+// 使用注入的随机源打乱0到n-1
+func (s *battleLogic) shuffle(n int) []int {
+	list := make([]int, n)
+	for i := range list {
+		list[i] = i
+	}
+	for i := n - 1; i > 0; i-- {
+		j := s.source.Intn(i + 1)
+		list[i], list[j] = list[j], list[i]
+	}
+	return list
+}
+
+// 加入机器人，使用房间中还没有的机器人配置，返回加入的数量
+func (s *battleLogic) addRobots(battleData *models.BattleData, n int) int {
+	robotList := s.tables.RobotTb.GetDataList()
+	petConfigList := s.tables.PetTb.GetDataList()
+	num := 0
+	for _, i := range s.shuffle(len(robotList)) {
+		if num >= n {
+			break
+		}
+		robotConfig := robotList[i]
+		roleId := uint64(robotConfig.Id)
+		if _, ok := battleData.PlayerData[roleId]; ok {
+			continue
+		}
+
+		// 优先使用机器人配置的宠物
+		var petId int32
+		if len(robotConfig.Pets) > 0 {
+			petId = robotConfig.Pets[s.source.Intn(len(robotConfig.Pets))]
+		}
+		if s.tables.PetTb.Get(petId) == nil {
+			petId = petConfigList[s.source.Intn(len(petConfigList))].Id
+		}
+
+		s.dealJoin(true, roleId, robotConfig.Name, battleData, petId)
+		s.getPlayerData(battleData, roleId).Strategy = s.randomRobotStrategy(battleData.BattleId)
+		num++
+	}
+	return num
+}
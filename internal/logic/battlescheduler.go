@@ -0,0 +1,247 @@
+package logic
+
+import (
+	"context"
+	"eggServer/internal/constant"
+	"eggServer/internal/contextx"
+	"eggServer/internal/models"
+	"eggServer/internal/schema"
+	"eggServer/pkg/errors"
+	"eggServer/pkg/redisbackend"
+	"eggServer/pkg/utils"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/cast"
+	"golang.org/x/sync/errgroup"
+	"gorm.io/gorm"
+	"time"
+)
+
+// 单次调度同时处理的房间数
+const battleScheduleLimit = 32
+
+// 战斗结算后等待玩家领取结算奖励的时间，超时后自动结算并清理房间
+const battleSettlementGrace = 600
+
+// RunScheduler 战斗调度器，负责推进房间的开始、回合结算、战斗结算和清理
+// 多个实例可以同时运行，通过房间的分布式锁保证同一时间只有一个实例处理同一个房间
+func (s *battleLogic) RunScheduler(ctx context.Context, db *gorm.DB, rb *redisbackend.RedisBackend, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.schedule(ctx, db, rb)
+		}
+	}
+}
+
+// 处理所有到期的房间
+func (s *battleLogic) schedule(ctx context.Context, db *gorm.DB, rb *redisbackend.RedisBackend) {
+	logger := contextx.FromLogger(ctx)
+
+	deskIds, err := rb.Client().ZRangeByScore(ctx, BattleScheduleKey, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: cast.ToString(time.Now().Unix()),
+	}).Result()
+	if err != nil {
+		logger.Errorf("BattleLogic.schedule error:%s", err.Error())
+		return
+	}
+
+	var g errgroup.Group
+	g.SetLimit(battleScheduleLimit)
+	for _, deskId := range deskIds {
+		deskId := deskId
+		g.Go(func() error {
+			s.tick(ctx, db, rb, deskId)
+			return nil
+		})
+	}
+	_ = g.Wait()
+
+	// 为排队的玩家分配房间
+	GameDataLogic.RLock()
+	s.matchmake(ctx, db, rb)
+	GameDataLogic.RUnlock()
+}
+
+// 推进单个房间
+func (s *battleLogic) tick(ctx context.Context, db *gorm.DB, rb *redisbackend.RedisBackend, deskId string) {
+	logger := contextx.FromLogger(ctx)
+
+	// 和玩家请求的加锁顺序一致，先持有配置表的读锁再锁定房间
+	GameDataLogic.RLock()
+	defer GameDataLogic.RUnlock()
+
+	// 其他实例或者玩家请求正在处理该房间，下次再处理
+	m := rb.NewMutex(deskId)
+	if err := m.TryLock(ctx); err != nil {
+		return
+	}
+
+	defer func() {
+		if _, err := m.Unlock(context.Background()); err != nil {
+			logger.WithError(err).Error("error on mutex unlock")
+		}
+	}()
+
+	nextAt, err := s.advance(ctx, db, rb, deskId)
+	if err != nil {
+		logger.Errorf("BattleLogic.tick deskId=%s error:%s", deskId, err.Error())
+		nextAt = time.Now().Unix() + 1
+	}
+
+	if nextAt == 0 {
+		if err := rb.Client().ZRem(ctx, BattleScheduleKey, deskId).Err(); err != nil {
+			logger.Errorf("BattleLogic.tick error:%s", err.Error())
+		}
+		return
+	}
+
+	if err := rb.Client().ZAdd(ctx, BattleScheduleKey, redis.Z{Score: float64(nextAt), Member: deskId}).Err(); err != nil {
+		logger.Errorf("BattleLogic.tick error:%s", err.Error())
+	}
+}
+
+// 推进房间状态，返回下次调度的时间，0表示不再调度
+func (s *battleLogic) advance(ctx context.Context, db *gorm.DB, rb *redisbackend.RedisBackend, deskId string) (int64, error) {
+	logger := contextx.FromLogger(ctx)
+
+	battleData, err := s.getBattleData(ctx, rb, deskId)
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			// 房间已经解散
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	now := time.Now().Unix()
+	battleConfig := s.tables.BattleConfigTb.Get(battleData.BattleId)
+	if battleConfig == nil {
+		return 0, s.destroyBattleData(ctx, rb, battleData)
+	}
+
+	// 匹配中
+	if battleData.StartAt == 0 {
+		matchEndAt := battleData.CreateAt + int64(battleConfig.MatchTime)
+
+		// 倒计时到了但没有真实玩家
+		if now >= matchEndAt && (battleData.State == 0 || len(battleData.Players) == 0) {
+			return 0, s.destroyBattleData(ctx, rb, battleData)
+		}
+
+		// 机器人逻辑
+		playerNum := len(battleData.Players)
+		if err := s.robotJoin(ctx, rb, battleData, false); err != nil {
+			return 0, err
+		}
+
+		if now >= matchEndAt {
+			// 倒计时到了
+			battleData.StartAt = matchEndAt
+		} else if len(battleData.Players) == s.getPlayerNum(battleData) {
+			// 人数已满则直接开始
+			battleData.StartAt = now
+		}
+
+		if err := s.saveBattleData(ctx, rb, battleData); err != nil {
+			return 0, err
+		}
+
+		if battleData.StartAt > 0 {
+			BattlePushLogic.Publish(ctx, rb, deskId, BattleEventMatchState, BattleEventRoundResult)
+		} else if len(battleData.Players) != playerNum {
+			BattlePushLogic.Publish(ctx, rb, deskId, BattleEventMatchState)
+		}
+		return now + 1, nil
+	}
+
+	if battleData.Settlement == 0 {
+		round := s.getRound(battleData)
+		resultNum := len(battleData.Result)
+
+		// 计算已经到时间的回合结果
+		for i := len(battleData.Result) + 1; i <= round && len(battleData.Players) > 0; i++ {
+			if now < s.getRoundStartTime(battleData, i) {
+				break
+			}
+			s.checkBet(battleData, i)
+			s.calcRoundResult(battleData, i)
+			logger.Infof("BattleLogic.advance deskId=%s, round:%d, result=%v", deskId, i, battleData.Result)
+		}
+
+		// 机器人下注
+		events := make([]byte, 0)
+		if len(battleData.Result) < round {
+			robotBetNum := s.getRobotBetNum(battleData, round)
+			playerNum := len(battleData.Players)
+			if err := s.robotBet(ctx, rb, battleData, false, round); err != nil {
+				return 0, err
+			}
+			if s.getRobotBetNum(battleData, round) != robotBetNum {
+				events = append(events, BattleEventSyncScore)
+			}
+			// 机器人退出
+			if len(battleData.Players) != playerNum {
+				events = append(events, BattleEventRoundResult)
+			}
+		}
+
+		if s.isBattleEnd(battleData, round) || len(battleData.Players) == 0 ||
+			(battleData.SettlementRound > 0 && s.isRoundEnd(battleData, battleData.SettlementRound)) || battleData.SettlementRound == -1 {
+			// 进行结算
+			if err := s.doSettlement(ctx, db, rb, battleData); err != nil {
+				return 0, err
+			}
+			BattlePushLogic.Publish(ctx, rb, deskId, BattleEventRoundResult)
+		} else {
+			if err := s.saveBattleData(ctx, rb, battleData); err != nil {
+				return 0, err
+			}
+
+			if len(battleData.Result) != resultNum && !utils.InArray(events, BattleEventRoundResult) {
+				events = append(events, BattleEventRoundResult)
+			}
+			BattlePushLogic.Publish(ctx, rb, deskId, events...)
+			return now + 1, nil
+		}
+	}
+
+	// 所有人都结算了则清除redis中的战斗数据，否则等待玩家领取结算奖励
+	if s.isAllPlayersSettlement(battleData) {
+		return 0, s.destroyBattleData(ctx, rb, battleData)
+	}
+	if reapAt := battleData.SettleAt + battleSettlementGrace; now < reapAt {
+		return reapAt, nil
+	}
+
+	// 超时没有领取的玩家自动结算，返还宠物并发放奖励
+	for roleId, playerData := range battleData.PlayerData {
+		if playerData.IsRobot == 1 || playerData.Settlement == 2 {
+			continue
+		}
+		// 玩家已经不在该房间时只标记为已结算
+		var e *errors.ResponseError
+		err := s.settlePlayer(ctx, db, battleData, roleId, new(schema.BattleSettlementResp))
+		if err != nil && !(errors.As(err, &e) && e.Code == constant.BattleAlreadyDismiss) {
+			// 已经结算的玩家先保存，下次调度重试剩下的玩家
+			if err := s.saveBattleData(ctx, rb, battleData); err != nil {
+				logger.Errorf("BattleLogic.advance deskId=%s error:%s", deskId, err.Error())
+			}
+			return 0, err
+		}
+		logger.Infof("BattleLogic.advance deskId=%s, reap roleId:%d", deskId, roleId)
+	}
+	return 0, s.destroyBattleData(ctx, rb, battleData)
+}
+
+// 销毁房间数据
+func (s *battleLogic) destroyBattleData(ctx context.Context, rb *redisbackend.RedisBackend, battleData *models.BattleData) error {
+	battleData.State = 2
+	return rb.Client().Del(ctx, fmt.Sprintf(BattleDataKey, battleData.DeskId)).Err()
+}
@@ -0,0 +1,162 @@
+package logic
+
+import (
+	"context"
+	"crypto/subtle"
+	"eggServer/internal/constant"
+	"eggServer/internal/contextx"
+	cfg "eggServer/internal/gamedata"
+	"eggServer/internal/models"
+	"eggServer/internal/schema"
+	"eggServer/pkg/errors"
+	"encoding/json"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"strings"
+	"time"
+)
+
+// 大厅最多返回的房间数
+const battleLobbyLimit = 50
+
+// Spectate 观战，只读取房间数据，不加入房间也不能下注
+// 不返回服务器种子和宠物能力看到的安全格子，私人房间需要邀请码
+func (s *battleLogic) Spectate(ctx context.Context, deskId string, inviteCode string) (*schema.BattleSpectateResp, error) {
+	logger := contextx.FromLogger(ctx)
+	rb := contextx.FromRB(ctx)
+
+	battleData, err := s.getBattleData(ctx, rb, deskId)
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			// 房间已经解散
+			return nil, errors.NewResponseError(constant.BattleAlreadyDismiss, nil)
+		}
+		logger.Errorf("BattleLogic.Spectate error:%s", err.Error())
+		return nil, err
+	}
+
+	// 私人房间的房间号可以猜到，需要邀请码才能观战
+	if s.isPrivate(battleData) && subtle.ConstantTimeCompare([]byte(strings.ToUpper(inviteCode)), []byte(battleData.InviteCode)) != 1 {
+		return nil, errors.NewResponseError(constant.InviteCodeInvalid, nil)
+	}
+
+	battleConfig := s.tables.BattleConfigTb.Get(battleData.BattleId)
+	if battleConfig == nil {
+		return nil, errors.NewResponseError(constant.ParametersInvalid, nil)
+	}
+
+	resp := new(schema.BattleSpectateResp)
+	resp.DeskId = battleData.DeskId
+	resp.BattleId = battleData.BattleId
+	resp.ServerTime = time.Now().Unix()
+	resp.TotalRound = battleConfig.TotalRound
+	resp.Result = battleData.Result
+	resp.LeftPlayerNum = len(battleData.Players)
+	resp.MaxPlayerNum = s.getPlayerNum(battleData)
+	resp.TournamentId = battleData.TournamentId
+	resp.State = s.getSpectateState(battleData)
+
+	round := s.getRound(battleData)
+	resp.Round = round
+	if round > 0 {
+		resp.RoundStartTime = s.getRoundStartTime(battleData, round)
+		resp.ScoreList, resp.PetList, resp.PetAbility = s.getGridOccupancy(battleData, round)
+	}
+	return resp, nil
+}
+
+// Lobby 正在进行的公开房间，私人房间只能通过邀请码观战
+func (s *battleLogic) Lobby(ctx context.Context, battleId int32) (*schema.BattleLobbyResp, error) {
+	logger := contextx.FromLogger(ctx)
+	rb := contextx.FromRB(ctx)
+	battleConfig := s.tables.BattleConfigTb.Get(battleId)
+	if battleConfig == nil || battleConfig.IsGuide == 1 {
+		return nil, errors.NewResponseError(constant.ParametersInvalid, nil)
+	}
+
+	// 调度中的房间包括等待领取奖励的已结算房间，按状态过滤
+	deskIds, err := rb.Client().ZRange(ctx, BattleScheduleKey, 0, -1).Result()
+	if err != nil {
+		logger.Errorf("BattleLogic.Lobby error:%s", err.Error())
+		return nil, errors.NewResponseError(constant.RDBError, err)
+	}
+
+	prefix := fmt.Sprintf("%d-", battleId)
+	keys := make([]string, 0)
+	for _, deskId := range deskIds {
+		if strings.HasPrefix(deskId, prefix) {
+			keys = append(keys, fmt.Sprintf(BattleDataKey, deskId))
+		}
+	}
+
+	resp := new(schema.BattleLobbyResp)
+	resp.BattleId = battleId
+	resp.List = make([]*schema.BattleLobbyDesk, 0)
+	if len(keys) == 0 {
+		return resp, nil
+	}
+
+	values, err := rb.Client().MGet(ctx, keys...).Result()
+	if err != nil {
+		logger.Errorf("BattleLogic.Lobby error:%s", err.Error())
+		return nil, errors.NewResponseError(constant.RDBError, err)
+	}
+
+	for _, v := range values {
+		str, ok := v.(string)
+		if !ok {
+			continue
+		}
+		battleData := new(models.BattleData)
+		if err := json.Unmarshal([]byte(str), battleData); err != nil {
+			continue
+		}
+		if s.isPrivate(battleData) || len(battleData.Players) == 0 || s.getSpectateState(battleData) == 2 {
+			continue
+		}
+
+		resp.List = append(resp.List, &schema.BattleLobbyDesk{
+			DeskId:        battleData.DeskId,
+			State:         s.getSpectateState(battleData),
+			Round:         s.getRound(battleData),
+			LeftPlayerNum: len(battleData.Players),
+			MaxPlayerNum:  s.getPlayerNum(battleData),
+			StartAt:       battleData.StartAt,
+			TournamentId:  battleData.TournamentId,
+		})
+		if len(resp.List) >= battleLobbyLimit {
+			break
+		}
+	}
+	return resp, nil
+}
+
+// 观战看到的房间状态 0匹配中 1战斗中 2战斗已结束
+func (s *battleLogic) getSpectateState(battleData *models.BattleData) byte {
+	if battleData.StartAt == 0 {
+		return 0
+	}
+	if battleData.Settlement == 1 || s.isBattleEnd(battleData, s.getRound(battleData)) {
+		return 2
+	}
+	return 1
+}
+
+// 回合中每个格子的分数和宠物，不修改房间数据
+func (s *battleLogic) getGridOccupancy(battleData *models.BattleData, round int) (map[int32]int32, map[int32][]int32, map[int32]int32) {
+	scoreList := make(map[int32]int32)
+	petList := make(map[int32][]int32)
+	petAbility := make(map[int32]int32)
+	for _, playerData := range battleData.PlayerData {
+		if len(playerData.Bet) < round {
+			continue
+		}
+		grid := playerData.Bet[round-1]
+		petList[grid] = append(petList[grid], playerData.PetId)
+		scoreList[grid] = scoreList[grid] + battleData.Bonus[round-1]
+		if playerData.Ability != cfg.PetAbilityType_None {
+			petAbility[playerData.PetId] = playerData.Ability
+		}
+	}
+	return scoreList, petList, petAbility
+}
@@ -9,28 +9,46 @@ import (
 	"eggServer/internal/schema"
 	"eggServer/pkg/errors"
 	"eggServer/pkg/utils"
+	"eggServer/pkg/utils/weighted"
 	"gorm.io/gorm"
-	"math/rand"
+	"log"
+	"sort"
 	"time"
 )
 
+const (
+	eggOpenBatchMax = 200 // 一次最多开蛋的数量
+	eggFusionMin    = 2   // 合成最少需要的蛋
+	eggFusionMax    = 5   // 合成最多使用的蛋
+)
+
 var EggLogic = new(eggLogic)
 
 type eggLogic struct {
-	tables             *cfg.Tables
-	eggPartTotalWeight map[int32]int32
-	eggPartTb          map[int32]map[int32][]*cfg.IEgg
-	eggOpenWeightTb    map[int32][]*cfg.IEggOpenWeight
-	eggOpenTotalWeight map[int32]int32
+	tables          *cfg.Tables
+	source          weighted.Source
+	eggPartTb       map[int32]map[int32][]*cfg.IEgg
+	eggPartSampler  map[int32]*weighted.Sampler[int32]               // 部件 -> 品质
+	eggGroupSampler []*weighted.Sampler[int32]                       // EggScoreTb下标 -> 开蛋组
+	eggOpenSampler  map[int32]*weighted.Sampler[*cfg.IEggOpenWeight] // 开蛋组 -> 奖励
+
+	pityList         []*cfg.IEggPity                                            // 按品质从高到低
+	pityGroupSampler []map[int32]*weighted.Sampler[int32]                       // EggScoreTb下标 -> 保底品质 -> 开蛋组
+	pityOpenSampler  map[int32]map[int32]*weighted.Sampler[*cfg.IEggOpenWeight] // 开蛋组 -> 保底品质 -> 该品质及以上的奖励
+
+	fusionSampler         []map[int32]*weighted.Sampler[int32] // EggFusionTb下标 -> 部件 -> 品质
+	fusionCatalystSampler []map[int32]*weighted.Sampler[int32] // 使用催化剂时的品质
 }
 
 func (s *eggLogic) Init(tables *cfg.Tables) {
 	s.tables = tables
+	if s.source == nil {
+		s.source = weighted.Default
+	}
 
-	s.eggPartTotalWeight = make(map[int32]int32)
 	s.eggPartTb = make(map[int32]map[int32][]*cfg.IEgg)
-	s.eggOpenWeightTb = make(map[int32][]*cfg.IEggOpenWeight)
-	s.eggOpenTotalWeight = make(map[int32]int32)
+	s.eggPartSampler = make(map[int32]*weighted.Sampler[int32])
+	s.eggOpenSampler = make(map[int32]*weighted.Sampler[*cfg.IEggOpenWeight])
 
 	s.eggPartTb[cfg.EggPartType_Part1] = make(map[int32][]*cfg.IEgg)
 	s.eggPartTb[cfg.EggPartType_Part2] = make(map[int32][]*cfg.IEgg)
@@ -41,28 +59,148 @@ func (s *eggLogic) Init(tables *cfg.Tables) {
 		s.eggPartTb[v.PartType][v.Quality] = append(s.eggPartTb[v.PartType][v.Quality], v)
 	}
 
-	eggPart1List := tables.EggPart1Tb.GetDataList()
-	for _, v := range eggPart1List {
-		s.eggPartTotalWeight[cfg.EggPartType_Part1] = s.eggPartTotalWeight[cfg.EggPartType_Part1] + v.Weight
+	partQualities := make(map[int32][]int32)
+	partWeights := make(map[int32][]int32)
+	addPart := func(part int32, quality int32, weight int32) {
+		partQualities[part] = append(partQualities[part], quality)
+		partWeights[part] = append(partWeights[part], weight)
+	}
+	for _, v := range tables.EggPart1Tb.GetDataList() {
+		addPart(cfg.EggPartType_Part1, v.Quality, v.Weight)
+	}
+	for _, v := range tables.EggPart2Tb.GetDataList() {
+		addPart(cfg.EggPartType_Part2, v.Quality, v.Weight)
+	}
+	for _, v := range tables.EggPart3Tb.GetDataList() {
+		addPart(cfg.EggPartType_Part3, v.Quality, v.Weight)
+	}
+	for _, part := range []int32{cfg.EggPartType_Part1, cfg.EggPartType_Part2, cfg.EggPartType_Part3} {
+		sampler, err := weighted.New(partQualities[part], partWeights[part])
+		if err != nil {
+			log.Fatalf("EggLogic.Init part %d error:%s", part, err.Error())
+		}
+		s.eggPartSampler[part] = sampler
 	}
 
-	eggPart2List := tables.EggPart1Tb.GetDataList()
-	for _, v := range eggPart2List {
-		s.eggPartTotalWeight[cfg.EggPartType_Part2] = s.eggPartTotalWeight[cfg.EggPartType_Part2] + v.Weight
+	groupArticles := make(map[int32][]*cfg.IEggOpenWeight)
+	groupWeights := make(map[int32][]int32)
+	for _, v := range tables.EggOpenWeightTb.GetDataList() {
+		groupArticles[v.GroupId] = append(groupArticles[v.GroupId], v)
+		groupWeights[v.GroupId] = append(groupWeights[v.GroupId], v.Weight)
+	}
+	for groupId, list := range groupArticles {
+		sampler, err := weighted.New(list, groupWeights[groupId])
+		if err != nil {
+			log.Fatalf("EggLogic.Init open group %d error:%s", groupId, err.Error())
+		}
+		s.eggOpenSampler[groupId] = sampler
 	}
 
-	eggPart3List := tables.EggPart1Tb.GetDataList()
-	for _, v := range eggPart3List {
-		s.eggPartTotalWeight[cfg.EggPartType_Part3] = s.eggPartTotalWeight[cfg.EggPartType_Part3] + v.Weight
+	eggScoreList := tables.EggScoreTb.GetDataList()
+	s.eggGroupSampler = make([]*weighted.Sampler[int32], len(eggScoreList))
+	for i, v := range eggScoreList {
+		sampler, err := weighted.New(v.OpenGroupId, v.GroupPb)
+		if err != nil {
+			log.Fatalf("EggLogic.Init eggScore %d error:%s", v.Id, err.Error())
+		}
+		s.eggGroupSampler[i] = sampler
 	}
 
-	eggOpenWeightList := tables.EggOpenWeightTb.GetDataList()
-	for _, v := range eggOpenWeightList {
-		s.eggOpenWeightTb[v.GroupId] = append(s.eggOpenWeightTb[v.GroupId], v)
-		s.eggOpenTotalWeight[v.GroupId] = s.eggOpenTotalWeight[v.GroupId] + v.Weight
+	s.initPity(tables)
+	s.initFusion(tables)
+}
+
+// 保底时只在该品质及以上的奖励中随机
+func (s *eggLogic) initPity(tables *cfg.Tables) {
+	s.pityList = make([]*cfg.IEggPity, 0, len(tables.EggPityTb.GetDataList()))
+	s.pityList = append(s.pityList, tables.EggPityTb.GetDataList()...)
+	sort.Slice(s.pityList, func(i, j int) bool { return s.pityList[i].Quality > s.pityList[j].Quality })
+
+	s.pityOpenSampler = make(map[int32]map[int32]*weighted.Sampler[*cfg.IEggOpenWeight])
+	for groupId := range s.eggOpenSampler {
+		s.pityOpenSampler[groupId] = make(map[int32]*weighted.Sampler[*cfg.IEggOpenWeight])
+	}
+	for _, v := range s.pityList {
+		articles := make(map[int32][]*cfg.IEggOpenWeight)
+		weights := make(map[int32][]int32)
+		for _, data := range tables.EggOpenWeightTb.GetDataList() {
+			if data.ArticleId != 0 && s.articleQuality(data.ItemType, data.ArticleId) >= v.Quality {
+				articles[data.GroupId] = append(articles[data.GroupId], data)
+				weights[data.GroupId] = append(weights[data.GroupId], data.Weight)
+			}
+		}
+		for groupId, list := range articles {
+			sampler, err := weighted.New(list, weights[groupId])
+			if err != nil {
+				log.Fatalf("EggLogic.initPity open group %d error:%s", groupId, err.Error())
+			}
+			s.pityOpenSampler[groupId][v.Quality] = sampler
+		}
+	}
+
+	eggScoreList := tables.EggScoreTb.GetDataList()
+	s.pityGroupSampler = make([]map[int32]*weighted.Sampler[int32], len(eggScoreList))
+	for i, data := range eggScoreList {
+		s.pityGroupSampler[i] = make(map[int32]*weighted.Sampler[int32])
+		for _, v := range s.pityList {
+			groupIds := make([]int32, 0, len(data.OpenGroupId))
+			groupPb := make([]int32, 0, len(data.OpenGroupId))
+			for j, groupId := range data.OpenGroupId {
+				if s.pityOpenSampler[groupId][v.Quality] != nil {
+					groupIds = append(groupIds, groupId)
+					groupPb = append(groupPb, data.GroupPb[j])
+				}
+			}
+			if len(groupIds) == 0 {
+				continue
+			}
+			sampler, err := weighted.New(groupIds, groupPb)
+			if err != nil {
+				log.Fatalf("EggLogic.initPity eggScore %d error:%s", data.Id, err.Error())
+			}
+			s.pityGroupSampler[i][v.Quality] = sampler
+		}
 	}
 }
 
+// 合成时每个部件只在有部件的品质中随机
+func (s *eggLogic) initFusion(tables *cfg.Tables) {
+	newSamplers := func(id int32, weights []int32) map[int32]*weighted.Sampler[int32] {
+		samplers := make(map[int32]*weighted.Sampler[int32])
+		for part, qualityTb := range s.eggPartTb {
+			qualities := make([]int32, 0, len(weights))
+			partWeights := make([]int32, 0, len(weights))
+			for quality, w := range weights {
+				if w > 0 && len(qualityTb[int32(quality)]) > 0 {
+					qualities = append(qualities, int32(quality))
+					partWeights = append(partWeights, w)
+				}
+			}
+			sampler, err := weighted.New(qualities, partWeights)
+			if err != nil {
+				log.Fatalf("EggLogic.initFusion fusion %d part %d error:%s", id, part, err.Error())
+			}
+			samplers[part] = sampler
+		}
+		return samplers
+	}
+
+	fusionList := tables.EggFusionTb.GetDataList()
+	s.fusionSampler = make([]map[int32]*weighted.Sampler[int32], len(fusionList))
+	s.fusionCatalystSampler = make([]map[int32]*weighted.Sampler[int32], len(fusionList))
+	for i, v := range fusionList {
+		s.fusionSampler[i] = newSamplers(v.Id, v.QualityWeight)
+		if v.CatalystId != 0 {
+			s.fusionCatalystSampler[i] = newSamplers(v.Id, v.CatalystWeight)
+		}
+	}
+}
+
+// SetSource 替换随机数源
+func (s *eggLogic) SetSource(source weighted.Source) {
+	s.source = source
+}
+
 func (s *eggLogic) ClickScreen(ctx context.Context, db *gorm.DB, roleId uint64, req *schema.ClickScreenReq) (*schema.ClickScreenResp, error) {
 	logger := contextx.FromLogger(ctx)
 	globalConfig := s.tables.GlobalTb.GetDataList()[0]
@@ -112,7 +250,7 @@ func (s *eggLogic) ClickScreen(ctx context.Context, db *gorm.DB, roleId uint64,
 		}
 
 		if cost > 0 {
-			reward, err := ItemLogic.AddItem(ctx, db, roleId, constant.VIT, -cost)
+			reward, err := ItemLogic.AddItem(ctx, db, roleId, constant.VIT, -cost, constant.SourceClickScreen)
 			if err != nil {
 				return err
 			}
@@ -209,50 +347,22 @@ func (s *eggLogic) TryLayEgg(ctx context.Context, db *gorm.DB, roleId uint64) (*
 }
 
 func (s *eggLogic) randOneGggPart(part int32) int32 {
-	var temp int32 = 0
-	var quality int32 = 0
-	var n = rand.Int31n(s.eggPartTotalWeight[part]) + 1
-
-	if part == cfg.EggPartType_Part1 {
-		temp = 0
-		tempList := s.tables.EggPart1Tb.GetDataList()
-		for i := 0; i < len(tempList); i++ {
-			if n > temp && n <= temp+tempList[i].Weight {
-				quality = tempList[i].Quality
-				break
-			}
-			temp = temp + tempList[i].Weight
-		}
-	} else if part == cfg.EggPartType_Part2 {
-		temp = 0
-		tempList := s.tables.EggPart2Tb.GetDataList()
-		for i := 0; i < len(tempList); i++ {
-			if n > temp && n <= temp+tempList[i].Weight {
-				quality = tempList[i].Quality
-				break
-			}
-			temp = temp + tempList[i].Weight
-		}
-	} else if part == cfg.EggPartType_Part3 {
-		temp = 0
-		tempList := s.tables.EggPart3Tb.GetDataList()
-		for i := 0; i < len(tempList); i++ {
-			if n > temp && n <= temp+tempList[i].Weight {
-				quality = tempList[i].Quality
-				break
-			}
-			temp = temp + tempList[i].Weight
-		}
+	sampler := s.eggPartSampler[part]
+	if sampler == nil {
+		return 0
 	}
+	return s.randPartByQuality(part, sampler.Sample(s.source))
+}
 
+// 在部件和品质相同的部件中随机一个
+func (s *eggLogic) randPartByQuality(part int32, quality int32) int32 {
 	tempList := s.eggPartTb[part]
 	eggList := tempList[quality]
 
-	eggPart, err := utils.RandomElement(eggList)
-	if err != nil {
+	if len(eggList) == 0 {
 		return 0
 	}
-	return eggPart.Id
+	return eggList[s.source.Intn(len(eggList))].Id
 }
 
 func (s *eggLogic) AddEgg(ctx context.Context, db *gorm.DB, roleId uint64) (*models.Egg, error) {
@@ -279,23 +389,11 @@ func (s *eggLogic) EggOpen(ctx context.Context, db *gorm.DB, roleId uint64, eggI
 		return nil, err
 	}
 
-	// 蛋评分
-	score := s.tables.EggTb.Get(egg.Part1).EggScore +
-		s.tables.EggTb.Get(egg.Part2).EggScore +
-		s.tables.EggTb.Get(egg.Part3).EggScore
-
-	eggScoreList := s.tables.EggScoreTb.GetDataList()
-
 	// 从评分中选择
-	var index = -1
-	for i := 0; i < len(eggScoreList); i++ {
-		if len(eggScoreList[i].EggScore) == 2 && eggScoreList[i].EggScore[0] < score && score <= eggScoreList[i].EggScore[1] {
-			index = i
-			break
-		}
-	}
+	index := s.eggScoreIndex(egg)
 
-	// 在引导中特殊处理砸蛋
+	// 在引导中特殊处理砸蛋，不计入保底
+	var guideOpen bool
 	if guideId == 202 || guideId == 206 {
 		guide, err := models.GuideRepo.Get(ctx, db, roleId)
 		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
@@ -303,63 +401,300 @@ func (s *eggLogic) EggOpen(ctx context.Context, db *gorm.DB, roleId uint64, eggI
 		}
 		if !utils.InArray(guide.Step, guideId) {
 			index = 10 // 索引
+			guideOpen = true
 		}
 	}
 	var resp *schema.RewardData
 
-	if index == -1 {
-		// 如果是空蛋则标记删除，可以使用挽回卡重新获得该蛋
-		if err := models.EggRepo.UpdateColum(ctx, db, egg.ID, "isDel", 1); err != nil {
-			logger.Errorf("EggLogic.EggOpen error: %s", err.Error())
-			return nil, err
+	err = db.Transaction(func(db *gorm.DB) error {
+		var pity *models.EggPity
+		if !guideOpen {
+			// 保底计数和奖励在同一个事务中更新
+			var err error
+			if pity, err = s.getPity(ctx, db, roleId); err != nil {
+				return err
+			}
+		}
+
+		reward, err := s.openEgg(ctx, db, roleId, index, pity)
+		if err != nil {
+			return err
+		}
+
+		if pity != nil {
+			if err := models.EggPityRepo.Save(ctx, db, roleId, pity); err != nil {
+				return err
+			}
 		}
+
+		if reward == nil {
+			// 如果是空蛋则标记删除，可以使用挽回卡重新获得该蛋
+			return models.EggRepo.UpdateColum(ctx, db, egg.ID, "isDel", 1)
+		}
+
+		resp = reward
+		return models.EggRepo.Delete(ctx, db, egg)
+	})
+
+	if err != nil {
+		logger.Errorf("EggLogic.EggOpen error: %s", err.Error())
+		return nil, err
+	}
+
+	if resp == nil {
 		// 空蛋
-		return new(schema.RewardData), nil
+		resp = new(schema.RewardData)
 	}
+	return resp, nil
+}
 
-	itemType, id, num := s.eggOpenWeight(index)
+// EggOpenBatch 在一个事务中开多个蛋，相同的奖励合并返回
+func (s *eggLogic) EggOpenBatch(ctx context.Context, db *gorm.DB, roleId uint64, req *schema.EggOpenBatchReq) (*schema.EggOpenBatchResp, error) {
+	logger := contextx.FromLogger(ctx)
 
-	if id != 0 {
-		err := db.Transaction(func(db *gorm.DB) error {
-			if itemType == cfg.RewardType_Pet {
-				reward, err := PetLogic.AddPet(ctx, db, roleId, id, num)
-				if err != nil {
-					return err
-				}
-				resp = reward
-			} else if itemType == cfg.RewardType_Item {
-				reward, err := ItemLogic.AddItem(ctx, db, roleId, id, num)
-				if err != nil {
-					return err
-				}
-				resp = reward
+	if len(req.IDs) > eggOpenBatchMax || (len(req.IDs) == 0 && req.Quality <= 0) {
+		return nil, errors.NewResponseError(constant.ParametersInvalid, nil)
+	}
+
+	resp := new(schema.EggOpenBatchResp)
+	resp.OpenIds = make([]uint64, 0)
+	resp.EmptyIds = make([]uint64, 0)
+	rewards := make([]*schema.RewardData, 0)
+
+	err := db.Transaction(func(db *gorm.DB) error {
+		// 锁定要开的蛋，防止同时开同一个蛋
+		var eggs []*models.Egg
+		var err error
+		if len(req.IDs) > 0 {
+			eggs, err = models.EggRepo.FindByIdsForUpdate(ctx, db, roleId, req.IDs)
+		} else {
+			eggs, err = models.EggRepo.FindAllByRoleIdForUpdate(ctx, db, roleId)
+		}
+		if err != nil {
+			return err
+		}
+
+		pity, err := s.getPity(ctx, db, roleId)
+		if err != nil {
+			return err
+		}
+
+		for _, egg := range eggs {
+			index := s.eggScoreIndex(egg)
+			if req.Quality > 0 && s.eggQuality(index) != req.Quality {
+				continue
+			}
+			if len(resp.OpenIds)+len(resp.EmptyIds) >= eggOpenBatchMax {
+				break
 			}
 
-			if err := models.EggRepo.Delete(ctx, db, egg); err != nil {
+			reward, err := s.openEgg(ctx, db, roleId, index, pity)
+			if err != nil {
 				return err
 			}
+			if reward == nil {
+				resp.EmptyIds = append(resp.EmptyIds, egg.ID)
+			} else {
+				resp.OpenIds = append(resp.OpenIds, egg.ID)
+				rewards = append(rewards, reward)
+			}
+		}
 
-			return nil
-		})
+		if err := models.EggPityRepo.Save(ctx, db, roleId, pity); err != nil {
+			return err
+		}
+
+		// 空蛋标记删除，可以使用挽回卡重新获得该蛋
+		if err := models.EggRepo.UpdateColumByIds(ctx, db, resp.EmptyIds, "isDel", 1); err != nil {
+			return err
+		}
+		return models.EggRepo.DeleteByIds(ctx, db, resp.OpenIds)
+	})
+
+	if err != nil {
+		logger.Errorf("EggLogic.EggOpenBatch error: %s", err.Error())
+		return nil, err
+	}
+
+	resp.RewardList = s.mergeRewards(rewards)
+	return resp, nil
+}
 
+// EggFusion 消耗多个蛋和可选的催化剂合成一个新蛋，新蛋部件的品质按输入蛋的总评分随机
+func (s *eggLogic) EggFusion(ctx context.Context, db *gorm.DB, roleId uint64, req *schema.EggFusionReq) (*schema.EggFusionResp, error) {
+	logger := contextx.FromLogger(ctx)
+
+	ids := make([]uint64, 0, len(req.IDs))
+	for _, id := range req.IDs {
+		if !utils.InArray(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) < eggFusionMin || len(ids) > eggFusionMax {
+		return nil, errors.NewResponseError(constant.ParametersInvalid, nil)
+	}
+
+	resp := new(schema.EggFusionResp)
+	err := db.Transaction(func(db *gorm.DB) error {
+		eggs, err := models.EggRepo.FindByIdsForUpdate(ctx, db, roleId, ids)
 		if err != nil {
-			logger.Errorf("EggLogic.EggOpen error: %s", err.Error())
-			return nil, err
+			return err
 		}
-		return resp, nil
-	} else {
-		// 如果是空蛋则标记删除，可以使用挽回卡重新获得该蛋
-		if err := models.EggRepo.UpdateColum(ctx, db, egg.ID, "isDel", 1); err != nil {
-			logger.Errorf("EggLogic.EggOpen error: %s", err.Error())
-			return nil, err
+		if len(eggs) != len(ids) {
+			return errors.NewResponseError(constant.ParametersInvalid, nil)
 		}
+
+		var score float64
+		for _, egg := range eggs {
+			score += s.eggScore(egg)
+		}
+
+		index := s.eggFusionIndex(score)
+		if index == -1 {
+			return errors.NewResponseError(constant.ParametersInvalid, nil)
+		}
+
+		fusionConfig := s.tables.EggFusionTb.GetDataList()[index]
+		samplers := s.fusionSampler[index]
+		if req.CatalystId != 0 {
+			if req.CatalystId != fusionConfig.CatalystId {
+				return errors.NewResponseError(constant.ParametersInvalid, nil)
+			}
+			if _, err := ItemLogic.UpdateItemCount(ctx, db, roleId, req.CatalystId, -fusionConfig.CatalystNum, constant.SourceEggFusion); err != nil {
+				return err
+			}
+			samplers = s.fusionCatalystSampler[index]
+		}
+
+		if err := models.EggRepo.DeleteByIds(ctx, db, ids); err != nil {
+			return err
+		}
+
+		egg := new(models.Egg)
+		egg.RoleID = roleId
+		egg.Part1 = s.randPartByQuality(cfg.EggPartType_Part1, samplers[cfg.EggPartType_Part1].Sample(s.source))
+		egg.Part2 = s.randPartByQuality(cfg.EggPartType_Part2, samplers[cfg.EggPartType_Part2].Sample(s.source))
+		egg.Part3 = s.randPartByQuality(cfg.EggPartType_Part3, samplers[cfg.EggPartType_Part3].Sample(s.source))
+		if err := models.EggRepo.Create(ctx, db, egg); err != nil {
+			return err
+		}
+
+		// 记录合成蛋进度
+		if err := TaskLogic.RecordTaskProgress(ctx, db, roleId, 10, 1); err != nil {
+			return err
+		}
+
+		resp.Egg = new(schema.Egg)
+		utils.Copy(resp.Egg, egg)
+		resp.FusedIds = ids
+		return nil
+	})
+
+	if err != nil {
+		logger.Errorf("EggLogic.EggFusion error: %s", err.Error())
+		return nil, err
 	}
+	return resp, nil
+}
 
-	// 空蛋
-	return new(schema.RewardData), nil
+// 输入蛋总评分对应的EggFusionTb下标，没有对应的评分返回-1
+func (s *eggLogic) eggFusionIndex(score float64) int {
+	fusionList := s.tables.EggFusionTb.GetDataList()
+	for i := 0; i < len(fusionList); i++ {
+		if len(fusionList[i].EggScore) == 2 && fusionList[i].EggScore[0] < score && score <= fusionList[i].EggScore[1] {
+			return i
+		}
+	}
+	return -1
 }
 
-func (s *eggLogic) EggOpenByIndex(ctx context.Context, db *gorm.DB, roleId uint64, index int) (*schema.RewardData, error) {
+// 蛋评分
+func (s *eggLogic) eggScore(egg *models.Egg) float64 {
+	return s.tables.EggTb.Get(egg.Part1).EggScore +
+		s.tables.EggTb.Get(egg.Part2).EggScore +
+		s.tables.EggTb.Get(egg.Part3).EggScore
+}
+
+// 蛋评分对应的EggScoreTb下标，没有对应的评分返回-1
+func (s *eggLogic) eggScoreIndex(egg *models.Egg) int {
+	score := s.eggScore(egg)
+
+	eggScoreList := s.tables.EggScoreTb.GetDataList()
+	for i := 0; i < len(eggScoreList); i++ {
+		if len(eggScoreList[i].EggScore) == 2 && eggScoreList[i].EggScore[0] < score && score <= eggScoreList[i].EggScore[1] {
+			return i
+		}
+	}
+	return -1
+}
+
+// 蛋的品质为评分对应的EggScoreTb的id，没有对应的评分为0
+func (s *eggLogic) eggQuality(index int) int32 {
+	if index < 0 {
+		return 0
+	}
+	return s.tables.EggScoreTb.GetDataList()[index].Id
+}
+
+// 读取并锁定保底计数，需要在事务中调用
+func (s *eggLogic) getPity(ctx context.Context, db *gorm.DB, roleId uint64) (*models.EggPity, error) {
+	pity, err := models.EggPityRepo.GetForUpdate(ctx, db, roleId)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if pity.Count == nil {
+		pity.Count = make(map[int32]int32)
+	}
+	return pity, nil
+}
+
+// 按评分开蛋并发放奖励，pity不为nil时计入保底，空蛋返回nil，需要在事务中调用
+func (s *eggLogic) openEgg(ctx context.Context, db *gorm.DB, roleId uint64, index int, pity *models.EggPity) (*schema.RewardData, error) {
+	var itemType, id, num int32
+	if pity != nil {
+		itemType, id, num = s.eggOpenPity(index, pity.Count)
+		s.updatePity(pity.Count, s.articleQuality(itemType, id))
+	} else if index >= 0 {
+		itemType, id, num = s.eggOpenWeight(index)
+	}
+
+	if id == 0 {
+		return nil, nil
+	}
+
+	if itemType == cfg.RewardType_Pet {
+		return PetLogic.AddPet(ctx, db, roleId, id, num, constant.SourceEggOpen)
+	} else if itemType == cfg.RewardType_Item {
+		return ItemLogic.AddItem(ctx, db, roleId, id, num, constant.SourceEggOpen)
+	}
+	return nil, nil
+}
+
+// 合并相同的道具和宠物奖励，保持第一次出现的顺序
+func (s *eggLogic) mergeRewards(rewards []*schema.RewardData) []*schema.RewardData {
+	list := make([]*schema.RewardData, 0, len(rewards))
+	items := make(map[[2]int32]*schema.ItemData)
+	for _, reward := range rewards {
+		if reward.Item != nil {
+			key := [2]int32{reward.Item.Type, reward.Item.ID}
+			if item, ok := items[key]; ok {
+				item.Num += reward.Item.Num
+				reward = &schema.RewardData{Egg: reward.Egg}
+				if reward.Egg == nil {
+					continue
+				}
+			} else {
+				item := *reward.Item
+				items[key] = &item
+				reward = &schema.RewardData{Item: &item, Egg: reward.Egg}
+			}
+		}
+		list = append(list, reward)
+	}
+	return list
+}
+
+func (s *eggLogic) EggOpenByIndex(ctx context.Context, db *gorm.DB, roleId uint64, index int, source int32) (*schema.RewardData, error) {
 	logger := contextx.FromLogger(ctx)
 
 	var resp *schema.RewardData
@@ -368,13 +703,13 @@ func (s *eggLogic) EggOpenByIndex(ctx context.Context, db *gorm.DB, roleId uint6
 	if id != 0 {
 		err := db.Transaction(func(db *gorm.DB) error {
 			if itemType == cfg.RewardType_Pet {
-				reward, err := PetLogic.AddPet(ctx, db, roleId, id, num)
+				reward, err := PetLogic.AddPet(ctx, db, roleId, id, num, source)
 				if err != nil {
 					return err
 				}
 				resp = reward
 			} else if itemType == cfg.RewardType_Item {
-				reward, err := ItemLogic.AddItem(ctx, db, roleId, id, num)
+				reward, err := ItemLogic.AddItem(ctx, db, roleId, id, num, source)
 				if err != nil {
 					return err
 				}
@@ -395,42 +730,82 @@ func (s *eggLogic) EggOpenByIndex(ctx context.Context, db *gorm.DB, roleId uint6
 }
 
 func (s *eggLogic) eggOpenWeight(index int) (int32, int32, int32) {
-	eggScoreList := s.tables.EggScoreTb.GetDataList()
-	// 总的组概率
-	groupPb := eggScoreList[index].GroupPb
-	var totalGroupPb int32 = 0
-	for i := 0; i < len(groupPb); i++ {
-		totalGroupPb = totalGroupPb + groupPb[i]
+	// 选出组id
+	groupId := s.eggGroupSampler[index].Sample(s.source)
+
+	if sampler := s.eggOpenSampler[groupId]; sampler != nil {
+		tempData := sampler.Sample(s.source)
+		if tempData.ArticleId != 0 {
+			return tempData.ItemType, tempData.ArticleId, tempData.ArticleNum
+		}
 	}
+	return 0, 0, 0
+}
 
-	// 选出组id
-	var groupId int32
-	var temp int32 = 0
-	var n = rand.Int31n(totalGroupPb) + 1
-	for i := 0; i < len(groupPb); i++ {
-		if n > temp && n <= temp+groupPb[i] {
-			groupId = eggScoreList[index].OpenGroupId[i]
-			break
-		}
-		temp = temp + groupPb[i]
-	}
-
-	if groupId != 0 {
-		list := s.eggOpenWeightTb[groupId]
-		var tempData *cfg.IEggOpenWeight
-		var temp int32 = 0
-		n := rand.Int31n(s.eggOpenTotalWeight[groupId]) + 1
-		for i := 0; i < len(list); i++ {
-			if n > temp && n <= temp+list[i].Weight {
-				tempData = list[i]
-				break
+// 按保底开蛋，从高品质开始检查，达到硬保底必出，超过软保底后每次增加概率
+// 评分对应的开蛋组里没有该品质时无法保底，计数保留到能开出该品质的蛋
+func (s *eggLogic) eggOpenPity(index int, count map[int32]int32) (int32, int32, int32) {
+	if index < 0 {
+		return 0, 0, 0
+	}
+
+	for _, v := range s.pityList {
+		n := count[v.Quality] + 1
+		if n < v.HardPity {
+			if n <= v.SoftPity || int32(s.source.Intn(10000)) >= (n-v.SoftPity)*v.SoftRate {
+				continue
 			}
-			temp = temp + list[i].Weight
 		}
 
-		if tempData != nil && tempData.ArticleId != 0 {
-			return tempData.ItemType, tempData.ArticleId, tempData.ArticleNum
+		groupSampler := s.pityGroupSampler[index][v.Quality]
+		if groupSampler == nil {
+			continue
 		}
+		groupId := groupSampler.Sample(s.source)
+		tempData := s.pityOpenSampler[groupId][v.Quality].Sample(s.source)
+		return tempData.ItemType, tempData.ArticleId, tempData.ArticleNum
 	}
-	return 0, 0, 0
+
+	return s.eggOpenWeight(index)
+}
+
+// 开出的品质及以下的保底计数清零，其他的加一，最多累计到硬保底
+func (s *eggLogic) updatePity(count map[int32]int32, quality int32) {
+	for _, v := range s.pityList {
+		if quality >= v.Quality {
+			count[v.Quality] = 0
+		} else if count[v.Quality] < v.HardPity {
+			count[v.Quality]++
+		}
+	}
+}
+
+// 奖励的品质，空奖励为0
+func (s *eggLogic) articleQuality(itemType int32, articleId int32) int32 {
+	switch itemType {
+	case cfg.RewardType_Pet:
+		if pet := s.tables.PetTb.Get(articleId); pet != nil {
+			return pet.Quality
+		}
+	case cfg.RewardType_Item:
+		if item := s.tables.ItemTb.Get(articleId); item != nil {
+			return item.Quality
+		}
+	}
+	return 0
+}
+
+// PityData 开蛋保底计数
+func (s *eggLogic) PityData(ctx context.Context, db *gorm.DB, roleId uint64) (*schema.EggPityDataResp, error) {
+	pity, err := models.EggPityRepo.Get(ctx, db, roleId)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	resp := new(schema.EggPityDataResp)
+	resp.Data = make(map[int32]int32, len(s.pityList))
+	for _, v := range s.pityList {
+		resp.Data[v.Quality] = pity.Count[v.Quality]
+	}
+	return resp, nil
 }
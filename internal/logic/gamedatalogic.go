@@ -0,0 +1,104 @@
+package logic
+
+import (
+	"context"
+	"eggServer/internal/contextx"
+	cfg "eggServer/internal/gamedata"
+	"eggServer/internal/gamedatax"
+	"eggServer/pkg/redisbackend"
+	"sync"
+	"time"
+)
+
+var (
+	GameDataReloadKey = "gamedata:reload"
+)
+
+var GameDataLogic = new(gameDataLogic)
+
+type gameDataLogic struct {
+	mu  sync.RWMutex
+	dir string
+}
+
+func (s *gameDataLogic) Init(dir string) {
+	s.dir = dir
+}
+
+// RLock 处理请求期间持有读锁，重新加载配置表时等待所有请求处理完，保证一次请求内看到的配置表是同一个版本
+func (s *gameDataLogic) RLock() {
+	s.mu.RLock()
+}
+
+func (s *gameDataLogic) RUnlock() {
+	s.mu.RUnlock()
+}
+
+// Reload 校验新的配置表，通过后通知所有实例重新加载
+// 调用时可能持有读锁，所以本实例也在收到通知后再加载
+func (s *gameDataLogic) Reload(ctx context.Context, rb *redisbackend.RedisBackend) error {
+	logger := contextx.FromLogger(ctx)
+
+	if _, err := gamedatax.Load(s.dir); err != nil {
+		logger.Errorf("GameDataLogic.Reload error:%s", err.Error())
+		return err
+	}
+
+	if err := rb.Client().Publish(ctx, GameDataReloadKey, time.Now().Unix()).Err(); err != nil {
+		logger.Errorf("GameDataLogic.Reload error:%s", err.Error())
+		return err
+	}
+	return nil
+}
+
+// Run 监听重新加载配置表的通知
+func (s *gameDataLogic) Run(ctx context.Context, rb *redisbackend.RedisBackend) {
+	logger := contextx.FromLogger(ctx)
+
+	pubsub := rb.Client().Subscribe(ctx, GameDataReloadKey)
+	defer pubsub.Close()
+
+	for range pubsub.Channel() {
+		if err := s.reload(ctx); err != nil {
+			logger.Errorf("GameDataLogic.Run error:%s", err.Error())
+		}
+	}
+}
+
+// 加载并校验新的配置表，通过后替换所有模块的配置
+func (s *gameDataLogic) reload(ctx context.Context) error {
+	logger := contextx.FromLogger(ctx)
+
+	tables, err := gamedatax.Load(s.dir)
+	if err != nil {
+		logger.Errorf("GameDataLogic.reload error:%s", err.Error())
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	initTables(tables)
+	logger.Infof("GameDataLogic.reload %s success", s.dir)
+	return nil
+}
+
+// 替换配置表时需要更新的模块
+func initTables(tables *cfg.Tables) {
+	UserLogic.Init(tables)
+	GuideLogic.Init(tables)
+	LeaderboardLogic.Init(tables)
+	ItemLogic.Init(tables)
+	LedgerLogic.Init(tables)
+	PetLogic.Init(tables)
+	TaskLogic.Init(tables)
+	EggLogic.Init(tables)
+	BattleLogic.Init(tables)
+	BattlePushLogic.tables = tables
+	TournamentLogic.Init(tables)
+	ShopLogic.Init(tables)
+	PaymentLogic.Init(tables)
+	OrderLogic.Init(tables)
+	SignLogic.Init(tables)
+	PassPortLogic.Init(tables)
+}
@@ -24,7 +24,7 @@ func (s *itemLogic) Init(tables *cfg.Tables) {
 	s.tables = tables
 }
 
-func (s *itemLogic) AddItem(ctx context.Context, db *gorm.DB, roleId uint64, itemId int32, count int32) (*schema.RewardData, error) {
+func (s *itemLogic) AddItem(ctx context.Context, db *gorm.DB, roleId uint64, itemId int32, count int32, source int32) (*schema.RewardData, error) {
 	logger := contextx.FromLogger(ctx)
 	itemConfig := s.tables.ItemTb.Get(itemId)
 	if itemConfig == nil {
@@ -33,11 +33,11 @@ func (s *itemLogic) AddItem(ctx context.Context, db *gorm.DB, roleId uint64, ite
 	}
 
 	if itemConfig.UseType == 1 {
-		return s.UseItem(ctx, db, roleId, itemId, itemConfig.Param, false)
+		return s.UseItem(ctx, db, roleId, itemId, itemConfig.Param, false, source)
 	}
 
 	// 更新道具数量
-	_, err := s.UpdateItemCount(ctx, db, roleId, itemId, count)
+	_, err := s.UpdateItemCount(ctx, db, roleId, itemId, count, source)
 	if err != nil {
 		return nil, err
 	}
@@ -51,36 +51,31 @@ func (s *itemLogic) AddItem(ctx context.Context, db *gorm.DB, roleId uint64, ite
 	return resp, nil
 }
 
-func (s *itemLogic) UpdateItemCount(ctx context.Context, db *gorm.DB, roleId uint64, itemId int32, count int32) (int32, error) {
+func (s *itemLogic) UpdateItemCount(ctx context.Context, db *gorm.DB, roleId uint64, itemId int32, count int32, source int32) (int32, error) {
 	logger := contextx.FromLogger(ctx)
 
-	item, err := models.ItemRepo.Get(ctx, db, roleId, itemId)
-	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+	// 数据库中原子更新，数量不足时更新失败
+	balance, err := models.ItemRepo.AddNum(ctx, db, roleId, itemId, count)
+	if err != nil {
+		if errors.Is(err, models.ErrNumNotEnough) {
+			if itemId == constant.Diamond {
+				return 0, errors.NewResponseError(constant.DiamondNotEnough, errors.New("not enough diamonds"))
+			}
+			return 0, errors.NewResponseError(constant.ItemNotEnough, errors.New("not enough items"))
+		}
 		logger.Errorf("ItemLogic.AddItem itemId=%d error: %s", itemId, err.Error())
 		return 0, err
 	}
 
-	itemNum := item.ItemNum
-	item.RoleID = roleId
-	item.ItemID = itemId
-	item.ItemNum = item.ItemNum + count
-
-	if item.ItemNum >= 0 {
-		if err := models.ItemRepo.Save(ctx, db, item); err != nil {
-			logger.Errorf("ItemLogic.AddItem itemId=%d error: %s", item.ItemID, err.Error())
-			return item.ItemNum, err
-		}
-	} else {
-		if itemId == constant.Diamond {
-			return 0, errors.NewResponseError(constant.DiamondNotEnough, errors.New("not enough diamonds"))
-		}
-		return 0, errors.NewResponseError(constant.ItemNotEnough, errors.New("not enough items"))
+	// 记录账本
+	if err := LedgerLogic.Record(ctx, db, roleId, cfg.RewardType_Item, itemId, count, balance, source); err != nil {
+		return balance, err
 	}
 
 	// 体力小于最大恢复值
-	if item.ItemID == constant.VIT {
+	if itemId == constant.VIT {
 		globalConfig := s.tables.GlobalTb.GetDataList()[0]
-		if itemNum >= globalConfig.VitMaxAuto && count < 0 && item.ItemNum < globalConfig.VitMaxAuto {
+		if balance-count >= globalConfig.VitMaxAuto && count < 0 && balance < globalConfig.VitMaxAuto {
 			lastAddVitTime := time.Now().Unix()
 			if err := models.RoleRepo.UpdateColum(ctx, db, roleId, "lastAddVitTime", lastAddVitTime); err != nil {
 				logger.Errorf("VitLogic.AutoAddVit error: %s", err.Error())
@@ -88,10 +83,10 @@ func (s *itemLogic) UpdateItemCount(ctx context.Context, db *gorm.DB, roleId uin
 			}
 		}
 	}
-	return 0, nil
+	return balance, nil
 }
 
-func (s *itemLogic) UseItem(ctx context.Context, db *gorm.DB, roleId uint64, itemId int32, param string, checkCount bool) (*schema.RewardData, error) {
+func (s *itemLogic) UseItem(ctx context.Context, db *gorm.DB, roleId uint64, itemId int32, param string, checkCount bool, source int32) (*schema.RewardData, error) {
 	logger := contextx.FromLogger(ctx)
 	itemConfig := s.tables.ItemTb.Get(itemId)
 	if itemConfig == nil {
@@ -101,7 +96,7 @@ func (s *itemLogic) UseItem(ctx context.Context, db *gorm.DB, roleId uint64, ite
 
 	if checkCount {
 		// 扣除道具
-		_, err := s.UpdateItemCount(ctx, db, roleId, itemId, -1)
+		_, err := s.UpdateItemCount(ctx, db, roleId, itemId, -1, source)
 		if err != nil {
 			return nil, err
 		}
@@ -125,7 +120,7 @@ func (s *itemLogic) UseItem(ctx context.Context, db *gorm.DB, roleId uint64, ite
 		resp.Egg = e
 		return resp, nil
 	} else if itemConfig.Cmd == "openBox" {
-		return EggLogic.EggOpenByIndex(ctx, db, roleId, cast.ToInt(itemConfig.Param)-1)
+		return EggLogic.EggOpenByIndex(ctx, db, roleId, cast.ToInt(itemConfig.Param)-1, source)
 	} else if itemConfig.Cmd == "autoEggCollect" {
 		role, err := models.RoleRepo.Get(ctx, db, roleId)
 		if err != nil {
@@ -0,0 +1,77 @@
+package logic
+
+import (
+	"context"
+	"eggServer/internal/contextx"
+	cfg "eggServer/internal/gamedata"
+	"eggServer/internal/models"
+	"eggServer/internal/schema"
+	"eggServer/pkg/utils"
+	"gorm.io/gorm"
+	"time"
+)
+
+// 每页最多条数
+const ledgerMaxPageSize = 100
+
+var LedgerLogic = new(ledgerLogic)
+
+type ledgerLogic struct {
+	tables *cfg.Tables
+}
+
+func (s *ledgerLogic) Init(tables *cfg.Tables) {
+	s.tables = tables
+}
+
+// Record 记录道具或宠物的变化，需要和数量变化在同一个事务中
+func (s *ledgerLogic) Record(ctx context.Context, db *gorm.DB, roleId uint64, itemType int32, itemId int32, delta int32, balance int32, source int32) error {
+	logger := contextx.FromLogger(ctx)
+	if delta == 0 {
+		return nil
+	}
+
+	ledger := &models.Ledger{
+		RoleID:    roleId,
+		Type:      itemType,
+		ItemID:    itemId,
+		Delta:     delta,
+		Balance:   balance,
+		Source:    source,
+		CreatedAt: time.Now().Unix(),
+	}
+	if err := models.LedgerRepo.Create(ctx, db, ledger); err != nil {
+		logger.Errorf("LedgerLogic.Record itemId=%d error: %s", itemId, err.Error())
+		return err
+	}
+	return nil
+}
+
+// Data 分页获取账本，按时间倒序
+func (s *ledgerLogic) Data(ctx context.Context, db *gorm.DB, roleId uint64, req *schema.LedgerDataReq) (*schema.LedgerDataResp, error) {
+	pageNum, pageSize := req.Page, req.Limit
+	if pageNum <= 0 {
+		pageNum = 1
+	}
+	if pageSize <= 0 {
+		pageSize = 10
+	}
+	if pageSize > ledgerMaxPageSize {
+		pageSize = ledgerMaxPageSize
+	}
+
+	ledgers, total, err := models.LedgerRepo.FindPageByRoleId(ctx, db, roleId, pageNum, pageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := new(schema.LedgerDataResp)
+	resp.Total = total
+	resp.List = make([]*schema.LedgerData, 0, len(ledgers))
+	for _, ledger := range ledgers {
+		data := new(schema.LedgerData)
+		utils.Copy(data, ledger)
+		resp.List = append(resp.List, data)
+	}
+	return resp, nil
+}
@@ -0,0 +1,202 @@
+package logic
+
+import (
+	"context"
+	"eggServer/internal/constant"
+	"eggServer/internal/contextx"
+	cfg "eggServer/internal/gamedata"
+	"eggServer/internal/models"
+	"eggServer/internal/schema"
+	"eggServer/pkg/errors"
+	"eggServer/pkg/redisbackend"
+	"eggServer/pkg/utils"
+	"github.com/spf13/cast"
+	"gorm.io/gorm"
+	"time"
+)
+
+var (
+	OrderSweeperLockKey = "order:sweeper"
+)
+
+const (
+	orderMaxPageSize   = 100
+	orderRedeliverSize = 100 // 单次重新发货的订单数
+)
+
+var OrderLogic = new(orderLogic)
+
+type orderLogic struct {
+	tables *cfg.Tables
+}
+
+func (s *orderLogic) Init(tables *cfg.Tables) {
+	s.tables = tables
+}
+
+// List 玩家的订单记录
+func (s *orderLogic) List(ctx context.Context, db *gorm.DB, roleId uint64, req *schema.OrderListReq) (*schema.OrderListResp, error) {
+	pageNum, pageSize := req.Page, req.Limit
+	if pageNum <= 0 {
+		pageNum = 1
+	}
+	if pageSize <= 0 {
+		pageSize = 10
+	}
+	if pageSize > orderMaxPageSize {
+		pageSize = orderMaxPageSize
+	}
+
+	orders, total, err := models.OrderRepo.FindPageByRoleId(ctx, db, roleId, pageNum, pageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := new(schema.OrderListResp)
+	resp.Total = total
+	resp.List = make([]*schema.OrderData, 0, len(orders))
+	for _, order := range orders {
+		resp.List = append(resp.List, s.toOrderData(order))
+	}
+	return resp, nil
+}
+
+// Cancel 取消待支付的订单
+func (s *orderLogic) Cancel(ctx context.Context, db *gorm.DB, roleId uint64, req *schema.OrderCancelReq) (*schema.OrderCancelResp, error) {
+	logger := contextx.FromLogger(ctx)
+
+	order, err := models.OrderRepo.Get(ctx, db, cast.ToInt64(req.OrderId))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.NewResponseError(constant.ParametersInvalid, nil)
+		}
+		logger.Errorf("OrderLogic.Cancel error:%s", err.Error())
+		return nil, err
+	}
+
+	if order.RoleID != roleId {
+		return nil, errors.NewResponseError(constant.ParametersInvalid, nil)
+	}
+
+	if order.OrderStatus != constant.OrderStatusPending {
+		return nil, errors.NewResponseError(constant.OrderStatusInvalid, nil)
+	}
+
+	now := time.Now().Unix()
+	ok, err := models.OrderRepo.UpdateStatus(ctx, db, order.OrderId, []byte{constant.OrderStatusPending}, map[string]interface{}{"orderStatus": constant.OrderStatusCancelled, "updatedAt": now})
+	if err != nil {
+		logger.Errorf("OrderLogic.Cancel error:%s", err.Error())
+		return nil, err
+	}
+	if !ok {
+		// 订单已经支付或者过期
+		return nil, errors.NewResponseError(constant.OrderStatusInvalid, nil)
+	}
+
+	order.OrderStatus = constant.OrderStatusCancelled
+	order.UpdatedAt = now
+
+	resp := new(schema.OrderCancelResp)
+	resp.Order = s.toOrderData(order)
+	return resp, nil
+}
+
+// Refund 标记订单和没有计入订单的付款已经退款，退款需要人工转账
+func (s *orderLogic) Refund(ctx context.Context, db *gorm.DB, orderId int64) error {
+	order, err := models.OrderRepo.Get(ctx, db, orderId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.NewResponseError(constant.ParametersInvalid, nil)
+		}
+		return err
+	}
+
+	// 只有收到付款但没有正常完成的订单需要退款
+	status := []byte{constant.OrderStatusUnderpaid, constant.OrderStatusOverpaid, constant.OrderStatusExpired, constant.OrderStatusCancelled}
+	refundOrder := order.PaidAmount > 0 && utils.InArray(status, order.OrderStatus)
+
+	now := time.Now().Unix()
+	return db.Transaction(func(db *gorm.DB) error {
+		count, err := models.OrderPaymentRepo.Refund(ctx, db, orderId, now)
+		if err != nil {
+			return err
+		}
+
+		if !refundOrder {
+			if count == 0 {
+				return errors.NewResponseError(constant.OrderStatusInvalid, nil)
+			}
+			return nil
+		}
+
+		ok, err := models.OrderRepo.UpdateStatus(ctx, db, orderId, status, map[string]interface{}{"orderStatus": constant.OrderStatusRefunded, "updatedAt": now})
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errors.NewResponseError(constant.OrderStatusInvalid, nil)
+		}
+		return nil
+	})
+}
+
+// RunSweeper 订单清理，将超过支付期限的订单设为过期，给已支付但发货失败的订单重新发货
+// 多个实例可以同时运行，通过分布式锁保证同一时间只有一个实例在清理
+func (s *orderLogic) RunSweeper(ctx context.Context, db *gorm.DB, rb *redisbackend.RedisBackend, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.sweep(ctx, db, rb)
+		}
+	}
+}
+
+func (s *orderLogic) sweep(ctx context.Context, db *gorm.DB, rb *redisbackend.RedisBackend) {
+	logger := contextx.FromLogger(ctx)
+
+	// 其他实例正在清理
+	m := rb.NewMutex(OrderSweeperLockKey)
+	if err := m.TryLock(ctx); err != nil {
+		return
+	}
+
+	defer func() {
+		if _, err := m.Unlock(context.Background()); err != nil {
+			logger.WithError(err).Error("error on mutex unlock")
+		}
+	}()
+
+	count, err := models.OrderRepo.Expire(ctx, db, time.Now().Unix())
+	if err != nil {
+		logger.Errorf("OrderLogic.sweep error:%s", err.Error())
+	} else if count > 0 {
+		logger.Infof("OrderLogic.sweep expired %d orders", count)
+	}
+
+	orders, err := models.OrderRepo.FindByStatus(ctx, db, constant.OrderStatusPaid, orderRedeliverSize)
+	if err != nil {
+		logger.Errorf("OrderLogic.sweep error:%s", err.Error())
+		return
+	}
+
+	GameDataLogic.RLock()
+	defer GameDataLogic.RUnlock()
+
+	for _, order := range orders {
+		if _, err := ShopLogic.deliverPaidOrder(ctx, db, order); err != nil {
+			logger.Errorf("OrderLogic.sweep orderId=%d error:%s", order.OrderId, err.Error())
+		}
+	}
+}
+
+func (s *orderLogic) toOrderData(order *models.Order) *schema.OrderData {
+	data := new(schema.OrderData)
+	utils.Copy(data, order)
+	data.OrderId = cast.ToString(order.OrderId)
+	return data
+}
@@ -64,7 +64,7 @@ func (s *passPortLogic) Get(ctx context.Context, db *gorm.DB, roleId uint64, id
 
 		if deluxe && role.PassPortDeluxeReward == 1 {
 			if passPort.State != 2 && passPort.State != 3 {
-				resp.Reward, err = UtilsLogic.AddItem(ctx, db, roleId, passPortRewardConfig.DeluxeReward.Id, passPortRewardConfig.DeluxeReward.Num, passPortRewardConfig.DeluxeReward.Type)
+				resp.Reward, err = UtilsLogic.AddItem(ctx, db, roleId, passPortRewardConfig.DeluxeReward.Id, passPortRewardConfig.DeluxeReward.Num, passPortRewardConfig.DeluxeReward.Type, constant.SourcePassPort)
 				if err != nil {
 					return nil, err
 				}
@@ -82,7 +82,7 @@ func (s *passPortLogic) Get(ctx context.Context, db *gorm.DB, roleId uint64, id
 			}
 		} else {
 			if passPort.State != 1 && passPort.State != 3 {
-				resp.Reward, err = UtilsLogic.AddItem(ctx, db, roleId, passPortRewardConfig.OrdinaryReward.Id, passPortRewardConfig.OrdinaryReward.Num, passPortRewardConfig.OrdinaryReward.Type)
+				resp.Reward, err = UtilsLogic.AddItem(ctx, db, roleId, passPortRewardConfig.OrdinaryReward.Id, passPortRewardConfig.OrdinaryReward.Num, passPortRewardConfig.OrdinaryReward.Type, constant.SourcePassPort)
 				if err != nil {
 					return nil, err
 				}
@@ -0,0 +1,292 @@
+package logic
+
+import (
+	"context"
+	"eggServer/internal/config"
+	"eggServer/internal/constant"
+	"eggServer/internal/contextx"
+	cfg "eggServer/internal/gamedata"
+	"eggServer/pkg/errors"
+	"eggServer/pkg/redisbackend"
+	"encoding/json"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/cast"
+	"github.com/tonkeeper/tonapi-go"
+	"gorm.io/gorm"
+	"time"
+)
+
+var (
+	PaymentLastLtKey = "payment:lastLt" // 已经处理的最后一笔交易的逻辑时间
+	PaymentLockKey   = "payment:watcher"
+)
+
+// 单次拉取的交易数
+const paymentScanLimit = 100
+
+// ChainTransfer 转入收款钱包的一笔支付
+type ChainTransfer struct {
+	Lt       int64  // 逻辑时间
+	Hash     string // 交易哈希
+	Utime    int64  // 交易时间
+	Sender   string // 付款钱包地址
+	Currency int    // 币种 constant.USDT constant.TON
+	Amount   int64  // 金额，最小单位
+	OrderId  int64  // 转账备注中的订单号
+}
+
+// ChainClient 区块链客户端
+type ChainClient interface {
+	// GetTransfers 按逻辑时间升序返回account在afterLt之后收到的支付，lastLt为本次扫描到的最后一笔交易的逻辑时间
+	GetTransfers(ctx context.Context, account string, afterLt int64, limit int) (transfers []*ChainTransfer, lastLt int64, err error)
+}
+
+// 扫描进度，已经处理的最后一笔交易的逻辑时间
+type paymentCursor interface {
+	Load(ctx context.Context) (int64, error)
+	Save(ctx context.Context, lt int64) error
+}
+
+// 给一笔支付发货
+type paymentDeliver func(ctx context.Context, transfer *ChainTransfer) error
+
+var PaymentLogic = new(paymentLogic)
+
+type paymentLogic struct {
+	tables *cfg.Tables
+	client ChainClient
+}
+
+func (s *paymentLogic) Init(tables *cfg.Tables) {
+	s.tables = tables
+	if s.client == nil {
+		s.client = new(tonapiChainClient)
+	}
+}
+
+// SetChainClient 替换区块链客户端
+func (s *paymentLogic) SetChainClient(client ChainClient) {
+	s.client = client
+}
+
+// RunWatcher 扫描收款钱包的转入交易，自动给匹配的订单发货
+// 多个实例可以同时运行，通过分布式锁保证同一时间只有一个实例在扫描
+func (s *paymentLogic) RunWatcher(ctx context.Context, db *gorm.DB, rb *redisbackend.RedisBackend, interval time.Duration) {
+	logger := contextx.FromLogger(ctx)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.scan(ctx, db, rb); err != nil {
+				logger.Errorf("PaymentLogic.RunWatcher error:%s", err.Error())
+			}
+		}
+	}
+}
+
+// 处理上次扫描之后的所有交易
+func (s *paymentLogic) scan(ctx context.Context, db *gorm.DB, rb *redisbackend.RedisBackend) error {
+	logger := contextx.FromLogger(ctx)
+
+	// 其他实例正在扫描
+	m := rb.NewMutex(PaymentLockKey)
+	if err := m.TryLock(ctx); err != nil {
+		return nil
+	}
+
+	defer func() {
+		if _, err := m.Unlock(context.Background()); err != nil {
+			logger.WithError(err).Error("error on mutex unlock")
+		}
+	}()
+
+	return s.scanTransfers(ctx, &redisPaymentCursor{rb: rb}, func(ctx context.Context, transfer *ChainTransfer) error {
+		GameDataLogic.RLock()
+		defer GameDataLogic.RUnlock()
+		_, err := ShopLogic.deliverOrder(ctx, db, 0, transfer)
+		return err
+	})
+}
+
+// 从保存的扫描进度开始处理交易，发货需要重试时保存进度并停止，下次从这笔交易重新开始
+func (s *paymentLogic) scanTransfers(ctx context.Context, cursor paymentCursor, deliver paymentDeliver) error {
+	logger := contextx.FromLogger(ctx)
+
+	afterLt, err := cursor.Load(ctx)
+	if err != nil {
+		return err
+	}
+
+	for {
+		transfers, lastLt, err := s.client.GetTransfers(ctx, config.C.WalletAddress, afterLt, paymentScanLimit)
+		if err != nil {
+			return err
+		}
+
+		for _, transfer := range transfers {
+			if err := s.deliver(ctx, deliver, transfer); err != nil {
+				// 发货失败则从这笔交易重新开始
+				if lt := transfer.Lt - 1; lt > afterLt {
+					if err := cursor.Save(ctx, lt); err != nil {
+						logger.Errorf("PaymentLogic.scanTransfers error:%s", err.Error())
+					}
+				}
+				return err
+			}
+		}
+
+		if lastLt <= afterLt {
+			return nil
+		}
+
+		if err := cursor.Save(ctx, lastLt); err != nil {
+			return err
+		}
+		afterLt = lastLt
+	}
+}
+
+// 给支付对应的订单发货，只有数据库错误需要重试，其他错误说明支付和订单不匹配，跳过
+// 订单已经处理过时付款单独记录在OrderPayment中，人工退款后通过OrderLogic.Refund标记
+func (s *paymentLogic) deliver(ctx context.Context, deliver paymentDeliver, transfer *ChainTransfer) error {
+	logger := contextx.FromLogger(ctx)
+
+	err := deliver(ctx, transfer)
+	if err == nil {
+		logger.Infof("PaymentLogic.deliver orderId=%d hash=%s delivered", transfer.OrderId, transfer.Hash)
+		return nil
+	}
+
+	var e *errors.ResponseError
+	if errors.As(err, &e) && e.Code == constant.DatabaseError {
+		return err
+	}
+
+	logger.Warnf("PaymentLogic.deliver orderId=%d hash=%s sender=%s amount=%d skipped:%s", transfer.OrderId, transfer.Hash, transfer.Sender, transfer.Amount, err.Error())
+	return nil
+}
+
+// 保存在redis中的扫描进度
+type redisPaymentCursor struct {
+	rb *redisbackend.RedisBackend
+}
+
+func (c *redisPaymentCursor) Load(ctx context.Context) (int64, error) {
+	result, err := c.rb.Client().Get(ctx, PaymentLastLtKey).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return 0, err
+	}
+	return cast.ToInt64(result), nil
+}
+
+func (c *redisPaymentCursor) Save(ctx context.Context, lt int64) error {
+	return c.rb.Client().Set(ctx, PaymentLastLtKey, lt, 0).Err()
+}
+
+// 通过tonapi读取链上交易
+type tonapiChainClient struct {
+	jettonWallet string // 收款钱包的usdt钱包地址
+}
+
+func (c *tonapiChainClient) GetTransfers(ctx context.Context, account string, afterLt int64, limit int) ([]*ChainTransfer, int64, error) {
+	if c.jettonWallet == "" {
+		c.jettonWallet = TonapiLogic.GetJettonWalletAddress(ctx, account)
+		if c.jettonWallet == "" {
+			return nil, afterLt, errors.New("jettonWalletAddress not found")
+		}
+	}
+
+	resp, err := TonapiLogic.TonApi().GetBlockchainAccountTransactions(ctx, tonapi.GetBlockchainAccountTransactionsParams{
+		AccountID: account,
+		AfterLt:   tonapi.NewOptInt64(afterLt),
+		Limit:     tonapi.NewOptInt32(int32(limit)),
+		SortOrder: tonapi.NewOptGetBlockchainAccountTransactionsSortOrder(tonapi.GetBlockchainAccountTransactionsSortOrderAsc),
+	})
+	if err != nil {
+		return nil, afterLt, err
+	}
+
+	lastLt := afterLt
+	transfers := make([]*ChainTransfer, 0, len(resp.Transactions))
+	for _, transaction := range resp.Transactions {
+		if transaction.Lt > lastLt {
+			lastLt = transaction.Lt
+		}
+
+		if transfer := c.parseTransfer(transaction); transfer != nil {
+			transfers = append(transfers, transfer)
+		}
+	}
+
+	return transfers, lastLt, nil
+}
+
+// 解析转入的支付，订单号在转账备注中，不是有效支付时返回nil
+func (c *tonapiChainClient) parseTransfer(transaction tonapi.Transaction) *ChainTransfer {
+	if !transaction.Success || transaction.Aborted || !transaction.InMsg.Set {
+		return nil
+	}
+
+	inMsg := transaction.InMsg.Value
+	if inMsg.Bounced || !inMsg.Source.Set {
+		return nil
+	}
+
+	transfer := &ChainTransfer{
+		Lt:    transaction.Lt,
+		Hash:  transaction.Hash,
+		Utime: transaction.Utime,
+	}
+
+	switch inMsg.OpCode.Value {
+	case "0x7362d09c": // jetton转账通知
+		// 只接受自己的usdt钱包发来的通知，防止伪造的代币
+		if inMsg.Source.Value.Address != c.jettonWallet {
+			return nil
+		}
+
+		var body struct {
+			Amount         string `json:"amount"`
+			Sender         string `json:"sender"`
+			ForwardPayload struct {
+				Value struct {
+					Value struct {
+						Text string `json:"text"`
+					} `json:"value"`
+				} `json:"value"`
+			} `json:"forward_payload"`
+		}
+		if err := json.Unmarshal(inMsg.DecodedBody, &body); err != nil {
+			return nil
+		}
+
+		transfer.Sender = body.Sender
+		transfer.Currency = constant.USDT
+		transfer.Amount = cast.ToInt64(body.Amount)
+		transfer.OrderId = cast.ToInt64(body.ForwardPayload.Value.Value.Text)
+	case "0x00000000": // ton转账
+		var body struct {
+			Text string `json:"text"`
+		}
+		if err := json.Unmarshal(inMsg.DecodedBody, &body); err != nil {
+			return nil
+		}
+
+		transfer.Sender = inMsg.Source.Value.Address
+		transfer.Currency = constant.TON
+		transfer.Amount = inMsg.Value
+		transfer.OrderId = cast.ToInt64(body.Text)
+	default:
+		return nil
+	}
+
+	if transfer.OrderId <= 0 {
+		return nil
+	}
+	return transfer
+}
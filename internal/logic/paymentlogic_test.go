@@ -0,0 +1,602 @@
+package logic
+
+import (
+	"context"
+	"database/sql"
+	"eggServer/internal/constant"
+	cfg "eggServer/internal/gamedata"
+	"eggServer/internal/gamedatax"
+	"eggServer/internal/models"
+	"eggServer/internal/schema"
+	"eggServer/pkg/errors"
+	"eggServer/pkg/utils"
+	"github.com/tonkeeper/tonapi-go"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	gormschema "gorm.io/gorm/schema"
+	"testing"
+)
+
+// 模拟的区块链，按逻辑时间升序保存转入的支付
+type fakeChainClient struct {
+	transfers []*ChainTransfer
+	calls     []int64 // 每次请求的afterLt
+}
+
+func (c *fakeChainClient) GetTransfers(ctx context.Context, account string, afterLt int64, limit int) ([]*ChainTransfer, int64, error) {
+	c.calls = append(c.calls, afterLt)
+	lastLt := afterLt
+	list := make([]*ChainTransfer, 0)
+	for _, transfer := range c.transfers {
+		if transfer.Lt <= afterLt {
+			continue
+		}
+		if len(list) >= limit {
+			break
+		}
+		list = append(list, transfer)
+		lastLt = transfer.Lt
+	}
+	return list, lastLt, nil
+}
+
+// 内存中的扫描进度，重启后保留
+type fakePaymentCursor struct {
+	lastLt int64
+}
+
+func (c *fakePaymentCursor) Load(ctx context.Context) (int64, error) {
+	return c.lastLt, nil
+}
+
+func (c *fakePaymentCursor) Save(ctx context.Context, lt int64) error {
+	c.lastLt = lt
+	return nil
+}
+
+// 内存中的订单表，和OrderRepo一样按状态条件更新
+type fakeOrderStore struct {
+	orders   map[int64]*models.Order
+	fail     map[int64]int  // 模拟数据库错误的次数
+	conflict map[int64]bool // 模拟订单状态已经被其他请求修改
+}
+
+func newFakeOrderStore(orders ...*models.Order) *fakeOrderStore {
+	store := &fakeOrderStore{orders: make(map[int64]*models.Order), fail: make(map[int64]int), conflict: make(map[int64]bool)}
+	for _, order := range orders {
+		store.orders[order.OrderId] = order
+	}
+	return store
+}
+
+func (f *fakeOrderStore) Get(ctx context.Context, db *gorm.DB, orderId int64) (*models.Order, error) {
+	order, ok := f.orders[orderId]
+	if !ok {
+		return new(models.Order), gorm.ErrRecordNotFound
+	}
+	copied := *order
+	return &copied, nil
+}
+
+func (f *fakeOrderStore) UpdateStatus(ctx context.Context, db *gorm.DB, orderId int64, status []byte, values interface{}) (bool, error) {
+	if f.fail[orderId] > 0 {
+		f.fail[orderId]--
+		return false, errors.NewResponseError(constant.DatabaseError, errors.New("database unavailable"))
+	}
+
+	order, ok := f.orders[orderId]
+	if !ok || f.conflict[orderId] || !utils.InArray(status, order.OrderStatus) {
+		return false, nil
+	}
+	for k, v := range values.(map[string]interface{}) {
+		switch k {
+		case "orderStatus":
+			order.OrderStatus = v.(byte)
+		case "paidAmount":
+			order.PaidAmount = v.(int64)
+		case "txHash":
+			order.TxHash = v.(string)
+		case "updatedAt":
+			order.UpdatedAt = v.(int64)
+		}
+	}
+	return true, nil
+}
+
+// 内存中没有计入订单的付款，同一笔交易只记录一次
+type fakePaymentStore struct {
+	payments map[string]*models.OrderPayment
+	fail     int // 模拟数据库错误的次数
+}
+
+func (f *fakePaymentStore) Create(ctx context.Context, db *gorm.DB, payment *models.OrderPayment) error {
+	if f.fail > 0 {
+		f.fail--
+		return errors.NewResponseError(constant.DatabaseError, errors.New("database unavailable"))
+	}
+	if _, ok := f.payments[payment.TxHash]; !ok {
+		f.payments[payment.TxHash] = payment
+	}
+	return nil
+}
+
+// 记录发放的道具，玩家id和订单号相同
+type fakeItemAdder struct {
+	granted map[uint64]int
+	err     error
+}
+
+func (f *fakeItemAdder) AddItem(ctx context.Context, db *gorm.DB, roleId uint64, itemId int32, count int32, itemType int32, source int32) (*schema.RewardData, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	f.granted[roleId]++
+	return nil, nil
+}
+
+// 不执行sql的数据库连接，只记录事务的提交和回滚
+type fakeConnPool struct {
+	commits   int
+	rollbacks int
+}
+
+func (p *fakeConnPool) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+
+func (p *fakeConnPool) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return nil, errors.New("not supported")
+}
+
+func (p *fakeConnPool) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return nil, errors.New("not supported")
+}
+
+func (p *fakeConnPool) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return nil
+}
+
+func (p *fakeConnPool) BeginTx(ctx context.Context, opts *sql.TxOptions) (gorm.ConnPool, error) {
+	return &fakeTx{fakeConnPool: p}, nil
+}
+
+type fakeTx struct {
+	*fakeConnPool
+}
+
+func (tx *fakeTx) Commit() error {
+	tx.commits++
+	return nil
+}
+
+func (tx *fakeTx) Rollback() error {
+	tx.rollbacks++
+	return nil
+}
+
+type fakeDialector struct {
+	pool *fakeConnPool
+}
+
+func (d fakeDialector) Name() string { return "fake" }
+
+func (d fakeDialector) Initialize(db *gorm.DB) error {
+	db.ConnPool = d.pool
+	return nil
+}
+
+func (d fakeDialector) Migrator(db *gorm.DB) gorm.Migrator { return nil }
+
+func (d fakeDialector) DataTypeOf(*gormschema.Field) string { return "" }
+
+func (d fakeDialector) DefaultValueOf(*gormschema.Field) clause.Expression { return nil }
+
+func (d fakeDialector) BindVarTo(writer clause.Writer, stmt *gorm.Statement, v interface{}) {
+	_ = writer.WriteByte('?')
+}
+
+func (d fakeDialector) QuoteTo(writer clause.Writer, str string) {
+	_, _ = writer.WriteString(str)
+}
+
+func (d fakeDialector) Explain(sql string, vars ...interface{}) string { return sql }
+
+// 使用真实的发货流程，订单和道具保存在内存中
+type testShop struct {
+	*shopLogic
+	store    *fakeOrderStore
+	payments *fakePaymentStore
+	items    *fakeItemAdder
+	pool     *fakeConnPool
+	db       *gorm.DB
+}
+
+func newTestShop(t *testing.T, store *fakeOrderStore) *testShop {
+	t.Helper()
+	tables, err := gamedatax.Load("../../data/static")
+	if err != nil {
+		t.Fatalf("load tables: %v", err)
+	}
+
+	pool := new(fakeConnPool)
+	db, err := gorm.Open(fakeDialector{pool: pool}, &gorm.Config{SkipDefaultTransaction: true})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+
+	payments := &fakePaymentStore{payments: make(map[string]*models.OrderPayment)}
+	items := &fakeItemAdder{granted: make(map[uint64]int)}
+	s := &shopLogic{orders: store, payments: payments, addItem: items.AddItem}
+	s.Init(tables)
+	return &testShop{shopLogic: s, store: store, payments: payments, items: items, pool: pool, db: db}
+}
+
+func (s *testShop) deliver(ctx context.Context, transfer *ChainTransfer) error {
+	_, err := s.deliverOrder(ctx, s.db, 0, transfer)
+	return err
+}
+
+func newTestOrder(orderId int64, currency int, amount int64) *models.Order {
+	return &models.Order{OrderId: orderId, RoleID: uint64(orderId), ShopType: cfg.ShopType_Diamond, ShopId: 1630001, Currency: currency, TotalAmount: amount, CreatedAt: 1000, ExpireAt: 2000}
+}
+
+func newTestPaymentLogic(client ChainClient) *paymentLogic {
+	s := new(paymentLogic)
+	s.SetChainClient(client)
+	return s
+}
+
+func TestParseTransfer(t *testing.T) {
+	c := &tonapiChainClient{jettonWallet: "0:jetton"}
+	newTransaction := func(source string, opCode string, value int64, body string) tonapi.Transaction {
+		return tonapi.Transaction{
+			Lt:      10,
+			Hash:    "hash",
+			Utime:   1500,
+			Success: true,
+			InMsg: tonapi.NewOptMessage(tonapi.Message{
+				Source:      tonapi.NewOptAccountAddress(tonapi.AccountAddress{Address: source}),
+				OpCode:      tonapi.NewOptString(opCode),
+				Value:       value,
+				DecodedBody: []byte(body),
+			}),
+		}
+	}
+
+	jettonBody := `{"amount":"2500000","sender":"0:buyer","forward_payload":{"value":{"value":{"text":"1001"}}}}`
+	transfer := c.parseTransfer(newTransaction("0:jetton", "0x7362d09c", 0, jettonBody))
+	if transfer == nil || transfer.OrderId != 1001 || transfer.Currency != constant.USDT || transfer.Amount != 2500000 || transfer.Sender != "0:buyer" {
+		t.Fatalf("jetton transfer parsed as %+v", transfer)
+	}
+
+	// 其他钱包发来的代币通知
+	if transfer := c.parseTransfer(newTransaction("0:fake", "0x7362d09c", 0, jettonBody)); transfer != nil {
+		t.Fatalf("forged jetton transfer parsed as %+v", transfer)
+	}
+
+	transfer = c.parseTransfer(newTransaction("0:buyer", "0x00000000", 300000000, `{"text":"1002"}`))
+	if transfer == nil || transfer.OrderId != 1002 || transfer.Currency != constant.TON || transfer.Amount != 300000000 || transfer.Sender != "0:buyer" {
+		t.Fatalf("ton transfer parsed as %+v", transfer)
+	}
+
+	// 备注不是订单号
+	if transfer := c.parseTransfer(newTransaction("0:buyer", "0x00000000", 300000000, `{"text":"thanks"}`)); transfer != nil {
+		t.Fatalf("transfer without order parsed as %+v", transfer)
+	}
+
+	bounced := newTransaction("0:buyer", "0x00000000", 300000000, `{"text":"1002"}`)
+	bounced.InMsg.Value.Bounced = true
+	if transfer := c.parseTransfer(bounced); transfer != nil {
+		t.Fatalf("bounced transfer parsed as %+v", transfer)
+	}
+}
+
+func TestMatchPayment(t *testing.T) {
+	cases := []struct {
+		name     string
+		status   byte
+		transfer *ChainTransfer
+		want     byte
+		code     int
+		err      int
+	}{
+		{"paid", constant.OrderStatusPending, &ChainTransfer{OrderId: 1, Currency: constant.TON, Amount: 100, Utime: 1500}, constant.OrderStatusPaid, 0, 0},
+		{"overpaid", constant.OrderStatusPending, &ChainTransfer{OrderId: 1, Currency: constant.TON, Amount: 150, Utime: 1500}, constant.OrderStatusPaid, 0, 0},
+		{"paid after sweep", constant.OrderStatusExpired, &ChainTransfer{OrderId: 1, Currency: constant.TON, Amount: 100, Utime: 1500}, constant.OrderStatusPaid, 0, 0},
+		{"underpaid", constant.OrderStatusPending, &ChainTransfer{OrderId: 1, Currency: constant.TON, Amount: 99, Utime: 1500}, constant.OrderStatusUnderpaid, constant.OrderUnderpaid, 0},
+		{"late", constant.OrderStatusPending, &ChainTransfer{OrderId: 1, Currency: constant.TON, Amount: 100, Utime: 2001}, constant.OrderStatusPending, constant.OrderExpired, 0},
+		{"other order", constant.OrderStatusPending, &ChainTransfer{OrderId: 2, Currency: constant.TON, Amount: 100, Utime: 1500}, 0, 0, constant.ParametersInvalid},
+		{"other currency", constant.OrderStatusPending, &ChainTransfer{OrderId: 1, Currency: constant.USDT, Amount: 100, Utime: 1500}, 0, 0, constant.ParametersInvalid},
+		{"before order", constant.OrderStatusPending, &ChainTransfer{OrderId: 1, Currency: constant.TON, Amount: 100, Utime: 999}, 0, 0, constant.ParametersInvalid},
+		{"delivered", constant.OrderStatusDelivered, &ChainTransfer{OrderId: 1, Currency: constant.TON, Amount: 100, Utime: 1500}, 0, 0, constant.OrderDelivered},
+	}
+
+	for _, c := range cases {
+		order := newTestOrder(1, constant.TON, 100)
+		order.OrderStatus = c.status
+		status, code, err := ShopLogic.matchPayment(order, c.transfer)
+		if c.err != 0 {
+			var e *errors.ResponseError
+			if !errors.As(err, &e) || e.Code != c.err {
+				t.Errorf("%s: err = %v, want code %d", c.name, err, c.err)
+			}
+			continue
+		}
+		if err != nil || status != c.want || code != c.code {
+			t.Errorf("%s: got status=%d code=%d err=%v, want status=%d code=%d", c.name, status, code, err, c.want, c.code)
+		}
+	}
+}
+
+func TestScanDeliversMatchingOrders(t *testing.T) {
+	client := &fakeChainClient{transfers: []*ChainTransfer{
+		{Lt: 10, Hash: "a", Utime: 1500, Currency: constant.TON, Amount: 100, OrderId: 1},
+		{Lt: 20, Hash: "b", Utime: 1500, Currency: constant.USDT, Amount: 500, OrderId: 99},
+		{Lt: 30, Hash: "c", Utime: 1500, Currency: constant.USDT, Amount: 500, OrderId: 2},
+		{Lt: 40, Hash: "d", Utime: 1500, Currency: constant.TON, Amount: 10, OrderId: 3},
+	}}
+	store := newFakeOrderStore(newTestOrder(1, constant.TON, 100), newTestOrder(2, constant.USDT, 500), newTestOrder(3, constant.TON, 100))
+	shop := newTestShop(t, store)
+	cursor := new(fakePaymentCursor)
+
+	if err := newTestPaymentLogic(client).scanTransfers(context.Background(), cursor, shop.deliver); err != nil {
+		t.Fatalf("scan error: %v", err)
+	}
+
+	if shop.items.granted[1] != 1 || shop.items.granted[2] != 1 {
+		t.Fatalf("granted = %v, want orders 1 and 2", shop.items.granted)
+	}
+	if shop.items.granted[3] != 0 || store.orders[3].OrderStatus != constant.OrderStatusUnderpaid {
+		t.Fatalf("underpaid order 3 status = %d granted = %d", store.orders[3].OrderStatus, shop.items.granted[3])
+	}
+	if cursor.lastLt != 40 {
+		t.Fatalf("lastLt = %d, want 40", cursor.lastLt)
+	}
+}
+
+func TestScanResumesFromLastLt(t *testing.T) {
+	client := &fakeChainClient{transfers: []*ChainTransfer{
+		{Lt: 10, Hash: "a", Utime: 1500, Currency: constant.TON, Amount: 100, OrderId: 1},
+		{Lt: 20, Hash: "b", Utime: 1500, Currency: constant.TON, Amount: 100, OrderId: 2},
+		{Lt: 30, Hash: "c", Utime: 1500, Currency: constant.TON, Amount: 100, OrderId: 3},
+	}}
+	store := newFakeOrderStore(newTestOrder(1, constant.TON, 100), newTestOrder(2, constant.TON, 100), newTestOrder(3, constant.TON, 100))
+	store.fail[2] = 1
+	shop := newTestShop(t, store)
+	cursor := new(fakePaymentCursor)
+
+	// 数据库错误，停在失败的交易之前
+	if err := newTestPaymentLogic(client).scanTransfers(context.Background(), cursor, shop.deliver); err == nil {
+		t.Fatal("scan should fail on database error")
+	}
+	if cursor.lastLt != 19 {
+		t.Fatalf("lastLt = %d, want 19", cursor.lastLt)
+	}
+
+	// 重启后从保存的进度继续
+	client.calls = nil
+	if err := newTestPaymentLogic(client).scanTransfers(context.Background(), cursor, shop.deliver); err != nil {
+		t.Fatalf("scan error: %v", err)
+	}
+	if len(client.calls) == 0 || client.calls[0] != 19 {
+		t.Fatalf("resumed from %v, want 19", client.calls)
+	}
+	for roleId := uint64(1); roleId <= 3; roleId++ {
+		if shop.items.granted[roleId] != 1 {
+			t.Fatalf("order %d delivered %d times", roleId, shop.items.granted[roleId])
+		}
+	}
+	if cursor.lastLt != 30 {
+		t.Fatalf("lastLt = %d, want 30", cursor.lastLt)
+	}
+}
+
+func TestScanDeliversDuplicateTransferOnce(t *testing.T) {
+	transfer := &ChainTransfer{Lt: 10, Hash: "a", Utime: 1500, Currency: constant.TON, Amount: 100, OrderId: 1}
+	client := &fakeChainClient{transfers: []*ChainTransfer{
+		transfer,
+		// 同一个订单号的第二笔付款
+		{Lt: 20, Hash: "b", Utime: 1500, Currency: constant.TON, Amount: 100, OrderId: 1},
+	}}
+	store := newFakeOrderStore(newTestOrder(1, constant.TON, 100))
+	shop := newTestShop(t, store)
+	cursor := new(fakePaymentCursor)
+
+	s := newTestPaymentLogic(client)
+	if err := s.scanTransfers(context.Background(), cursor, shop.deliver); err != nil {
+		t.Fatalf("scan error: %v", err)
+	}
+
+	// 扫描进度丢失后重新处理所有交易
+	cursor.lastLt = 0
+	if err := s.scanTransfers(context.Background(), cursor, shop.deliver); err != nil {
+		t.Fatalf("scan error: %v", err)
+	}
+
+	if shop.items.granted[1] != 1 {
+		t.Fatalf("order delivered %d times, want 1", shop.items.granted[1])
+	}
+	if store.orders[1].TxHash != transfer.Hash {
+		t.Fatalf("txHash = %s, want %s", store.orders[1].TxHash, transfer.Hash)
+	}
+}
+
+func TestDeliverOrderTransitions(t *testing.T) {
+	cases := []struct {
+		name      string
+		order     func(order *models.Order)
+		transfer  *ChainTransfer
+		code      int    // 发货返回的错误码
+		status    byte   // 处理后订单的状态
+		txHash    string // 处理后订单记录的交易
+		granted   int
+		retryable bool // 扫描时是否停下来重试
+	}{
+		{"paid", nil, &ChainTransfer{Hash: "a", OrderId: 1, Currency: constant.TON, Amount: 100, Utime: 1500}, 0, constant.OrderStatusDelivered, "a", 1, false},
+		{"overpaid", nil, &ChainTransfer{Hash: "a", OrderId: 1, Currency: constant.TON, Amount: 150, Utime: 1500}, 0, constant.OrderStatusOverpaid, "a", 1, false},
+		{"underpaid", nil, &ChainTransfer{Hash: "a", OrderId: 1, Currency: constant.TON, Amount: 99, Utime: 1500}, constant.OrderUnderpaid, constant.OrderStatusUnderpaid, "a", 0, false},
+		{"paid after sweep", func(order *models.Order) {
+			order.OrderStatus = constant.OrderStatusExpired
+		}, &ChainTransfer{Hash: "a", OrderId: 1, Currency: constant.TON, Amount: 100, Utime: 1500}, 0, constant.OrderStatusDelivered, "a", 1, false},
+		{"expired", func(order *models.Order) {
+			order.OrderStatus = constant.OrderStatusExpired
+		}, &ChainTransfer{Hash: "a", OrderId: 1, Currency: constant.TON, Amount: 100, Utime: 2500}, constant.OrderExpired, constant.OrderStatusExpired, "a", 0, false},
+		// 已经记录过一笔超时付款，之后的付款不覆盖
+		{"expired with txHash", func(order *models.Order) {
+			order.OrderStatus = constant.OrderStatusExpired
+			order.TxHash = "first"
+		}, &ChainTransfer{Hash: "a", OrderId: 1, Currency: constant.TON, Amount: 100, Utime: 2500}, constant.OrderExpired, constant.OrderStatusExpired, "first", 0, false},
+		{"cancelled before payment", func(order *models.Order) {
+			order.OrderStatus = constant.OrderStatusCancelled
+			order.UpdatedAt = 1200
+		}, &ChainTransfer{Hash: "a", OrderId: 1, Currency: constant.TON, Amount: 100, Utime: 1500}, constant.OrderExpired, constant.OrderStatusCancelled, "a", 0, false},
+		{"cancelled after payment", func(order *models.Order) {
+			order.OrderStatus = constant.OrderStatusCancelled
+			order.UpdatedAt = 1200
+		}, &ChainTransfer{Hash: "a", OrderId: 1, Currency: constant.TON, Amount: 100, Utime: 1100}, 0, constant.OrderStatusDelivered, "a", 1, false},
+		// 上次付款后发货失败
+		{"paid not delivered", func(order *models.Order) {
+			order.OrderStatus = constant.OrderStatusPaid
+			order.PaidAmount = 100
+			order.TxHash = "first"
+		}, &ChainTransfer{Hash: "a", OrderId: 1, Currency: constant.TON, Amount: 100, Utime: 1500}, 0, constant.OrderStatusDelivered, "first", 1, false},
+		{"delivered", func(order *models.Order) {
+			order.OrderStatus = constant.OrderStatusDelivered
+			order.TxHash = "first"
+		}, &ChainTransfer{Hash: "a", OrderId: 1, Currency: constant.TON, Amount: 100, Utime: 1500}, constant.OrderDelivered, constant.OrderStatusDelivered, "first", 0, false},
+		{"refunded", func(order *models.Order) {
+			order.OrderStatus = constant.OrderStatusRefunded
+		}, &ChainTransfer{Hash: "a", OrderId: 1, Currency: constant.TON, Amount: 100, Utime: 1500}, constant.OrderStatusInvalid, constant.OrderStatusRefunded, "", 0, false},
+		{"other currency", nil, &ChainTransfer{Hash: "a", OrderId: 1, Currency: constant.USDT, Amount: 100, Utime: 1500}, constant.ParametersInvalid, constant.OrderStatusPending, "", 0, false},
+	}
+
+	for _, c := range cases {
+		order := newTestOrder(1, constant.TON, 100)
+		if c.order != nil {
+			c.order(order)
+		}
+		shop := newTestShop(t, newFakeOrderStore(order))
+
+		err := shop.deliver(context.Background(), c.transfer)
+		if c.code == 0 && err != nil {
+			t.Errorf("%s: err = %v", c.name, err)
+		}
+		if c.code != 0 {
+			var e *errors.ResponseError
+			if !errors.As(err, &e) || e.Code != c.code {
+				t.Errorf("%s: err = %v, want code %d", c.name, err, c.code)
+			}
+		}
+		if order.OrderStatus != c.status || order.TxHash != c.txHash || shop.items.granted[1] != c.granted {
+			t.Errorf("%s: status=%d txHash=%q granted=%d, want status=%d txHash=%q granted=%d", c.name, order.OrderStatus, order.TxHash, shop.items.granted[1], c.status, c.txHash, c.granted)
+		}
+		if retryable := PaymentLogic.deliver(context.Background(), shop.deliver, c.transfer) != nil; retryable {
+			t.Errorf("%s: redelivery should not be retried", c.name)
+		}
+	}
+}
+
+func TestDeliverOrderRetryable(t *testing.T) {
+	transfer := &ChainTransfer{Hash: "a", OrderId: 1, Currency: constant.TON, Amount: 100, Utime: 1500}
+
+	// 记录付款时数据库错误，订单不变，需要重试
+	store := newFakeOrderStore(newTestOrder(1, constant.TON, 100))
+	store.fail[1] = 1
+	shop := newTestShop(t, store)
+	if err := PaymentLogic.deliver(context.Background(), shop.deliver, transfer); err == nil {
+		t.Fatal("database error should be retried")
+	}
+	if store.orders[1].OrderStatus != constant.OrderStatusPending {
+		t.Fatalf("status = %d after database error", store.orders[1].OrderStatus)
+	}
+	if err := PaymentLogic.deliver(context.Background(), shop.deliver, transfer); err != nil || shop.items.granted[1] != 1 {
+		t.Fatalf("retry err = %v granted = %d", err, shop.items.granted[1])
+	}
+
+	// 发放道具失败时回滚事务，需要重试
+	store = newFakeOrderStore(newTestOrder(1, constant.TON, 100))
+	shop = newTestShop(t, store)
+	shop.items.err = errors.NewResponseError(constant.DatabaseError, errors.New("database unavailable"))
+	if err := PaymentLogic.deliver(context.Background(), shop.deliver, transfer); err == nil {
+		t.Fatal("item error should be retried")
+	}
+	if shop.pool.rollbacks != 1 || shop.pool.commits != 0 {
+		t.Fatalf("commits = %d rollbacks = %d, want rollback", shop.pool.commits, shop.pool.rollbacks)
+	}
+
+	// 订单状态被其他请求修改，不重试
+	store = newFakeOrderStore(newTestOrder(1, constant.TON, 100))
+	store.conflict[1] = true
+	shop = newTestShop(t, store)
+	err := shop.deliver(context.Background(), transfer)
+	var e *errors.ResponseError
+	if !errors.As(err, &e) || e.Code != constant.OrderStatusInvalid {
+		t.Fatalf("conflict err = %v, want code %d", err, constant.OrderStatusInvalid)
+	}
+	if err := PaymentLogic.deliver(context.Background(), shop.deliver, transfer); err != nil {
+		t.Fatalf("conflict should not be retried: %v", err)
+	}
+}
+
+func TestDeliverOrderRecordsStrayPayments(t *testing.T) {
+	cases := []struct {
+		name   string
+		status byte
+		reason int // 单独记录的付款的原因，0表示不记录
+	}{
+		{"underpaid", constant.OrderStatusUnderpaid, constant.OrderStatusInvalid},
+		{"delivered", constant.OrderStatusDelivered, constant.OrderDelivered},
+		{"overpaid", constant.OrderStatusOverpaid, constant.OrderDelivered},
+		{"refunded", constant.OrderStatusRefunded, constant.OrderStatusInvalid},
+		{"paid", constant.OrderStatusPaid, constant.OrderDelivered},
+		{"expired", constant.OrderStatusExpired, constant.OrderExpired},
+	}
+
+	for _, c := range cases {
+		order := newTestOrder(1, constant.TON, 100)
+		order.OrderStatus = c.status
+		order.PaidAmount = 100
+		order.TxHash = "first"
+		shop := newTestShop(t, newFakeOrderStore(order))
+
+		// 同一个订单号的第二笔付款
+		second := &ChainTransfer{Hash: "second", Sender: "0:buyer", OrderId: 1, Currency: constant.TON, Amount: 100, Utime: 2500}
+		for i := 0; i < 2; i++ {
+			if err := PaymentLogic.deliver(context.Background(), shop.deliver, second); err != nil {
+				t.Fatalf("%s: err = %v", c.name, err)
+			}
+		}
+		payment := shop.payments.payments["second"]
+		if payment == nil || len(shop.payments.payments) != 1 {
+			t.Errorf("%s: payments = %v, want second recorded once", c.name, shop.payments.payments)
+			continue
+		}
+		if payment.OrderId != 1 || payment.RoleID != 1 || payment.Amount != 100 || payment.Sender != "0:buyer" || payment.Reason != c.reason {
+			t.Errorf("%s: payment = %+v, want reason %d", c.name, payment, c.reason)
+		}
+		if order.TxHash != "first" {
+			t.Errorf("%s: txHash = %s, want first", c.name, order.TxHash)
+		}
+
+		// 订单已经记录的付款不重复记录
+		first := &ChainTransfer{Hash: "first", OrderId: 1, Currency: constant.TON, Amount: 100, Utime: 1500}
+		_ = shop.deliver(context.Background(), first)
+		if _, ok := shop.payments.payments["first"]; ok {
+			t.Errorf("%s: order payment recorded again", c.name)
+		}
+	}
+
+	// 记录失败时需要重试
+	order := newTestOrder(1, constant.TON, 100)
+	order.OrderStatus = constant.OrderStatusDelivered
+	order.TxHash = "first"
+	shop := newTestShop(t, newFakeOrderStore(order))
+	shop.payments.fail = 1
+	second := &ChainTransfer{Hash: "second", OrderId: 1, Currency: constant.TON, Amount: 100, Utime: 1500}
+	if err := PaymentLogic.deliver(context.Background(), shop.deliver, second); err == nil {
+		t.Fatal("record error should be retried")
+	}
+	if err := PaymentLogic.deliver(context.Background(), shop.deliver, second); err != nil || shop.payments.payments["second"] == nil {
+		t.Fatalf("retry err = %v payments = %v", err, shop.payments.payments)
+	}
+}
@@ -8,51 +8,129 @@ import (
 	"eggServer/internal/models"
 	"eggServer/internal/schema"
 	"eggServer/pkg/errors"
+	"eggServer/pkg/utils"
 	"gorm.io/gorm"
+	"time"
 )
 
 var PetLogic = new(petLogic)
 
 type petLogic struct {
-	tables *cfg.Tables
+	tables           *cfg.Tables
+	petEvolution     map[int32]map[int32]*cfg.IPetEvolution // 宠物id -> 阶段 -> 配置，单独配置的宠物
+	qualityEvolution map[int32]map[int32]*cfg.IPetEvolution // 品质 -> 阶段 -> 配置
 }
 
 func (s *petLogic) Init(tables *cfg.Tables) {
 	s.tables = tables
+	s.petEvolution = make(map[int32]map[int32]*cfg.IPetEvolution)
+	s.qualityEvolution = make(map[int32]map[int32]*cfg.IPetEvolution)
+	for _, v := range tables.PetEvolutionTb.GetDataList() {
+		if v.PetId != 0 {
+			if s.petEvolution[v.PetId] == nil {
+				s.petEvolution[v.PetId] = make(map[int32]*cfg.IPetEvolution)
+			}
+			s.petEvolution[v.PetId][v.Stage] = v
+		} else {
+			if s.qualityEvolution[v.Quality] == nil {
+				s.qualityEvolution[v.Quality] = make(map[int32]*cfg.IPetEvolution)
+			}
+			s.qualityEvolution[v.Quality][v.Stage] = v
+		}
+	}
+}
+
+// 宠物在某个阶段的配置，优先使用单独配置的宠物，没有则按品质
+func (s *petLogic) evolution(petId int32, stage int32) *cfg.IPetEvolution {
+	if stages, ok := s.petEvolution[petId]; ok {
+		return stages[stage]
+	}
+	petConfig := s.tables.PetTb.Get(petId)
+	if petConfig == nil {
+		return nil
+	}
+	return s.qualityEvolution[petConfig.Quality][stage]
+}
+
+func (s *petLogic) AddPet(ctx context.Context, db *gorm.DB, roleId uint64, petId int32, count int32, source int32) (*schema.RewardData, error) {
+	_, resp, err := s.addPet(ctx, db, roleId, petId, count, nil, source)
+	return resp, err
+}
+
+// ConsumePets 消耗阶段和等级最低的宠物，返回被消耗的宠物
+func (s *petLogic) ConsumePets(ctx context.Context, db *gorm.DB, roleId uint64, petId int32, count int32, source int32) ([]*models.PetInstance, *schema.RewardData, error) {
+	return s.addPet(ctx, db, roleId, petId, -count, nil, source)
 }
 
-func (s *petLogic) AddPet(ctx context.Context, db *gorm.DB, roleId uint64, petId int32, count int32) (*schema.RewardData, error) {
+// RestorePets 返还消耗的宠物，保留原来的等级和经验
+func (s *petLogic) RestorePets(ctx context.Context, db *gorm.DB, roleId uint64, petId int32, count int32, pet *models.PetInstance, source int32) (*schema.RewardData, error) {
+	_, resp, err := s.addPet(ctx, db, roleId, petId, count, pet, source)
+	return resp, err
+}
+
+// 增加或减少宠物，同时维护宠物数量和单独的宠物，增加时按template的等级和经验创建
+func (s *petLogic) addPet(ctx context.Context, db *gorm.DB, roleId uint64, petId int32, count int32, template *models.PetInstance, source int32) ([]*models.PetInstance, *schema.RewardData, error) {
 	logger := contextx.FromLogger(ctx)
 	if petConfig := s.tables.PetTb.Get(petId); petConfig == nil {
 		logger.Errorf("PetLogic.AddPet petId=%d pet not found", petId)
-		return nil, errors.NewResponseError(constant.MinionsNotFound, errors.New("minions not found"))
+		return nil, nil, errors.NewResponseError(constant.MinionsNotFound, errors.New("minions not found"))
 	}
 
-	pet, err := models.PetRepo.Get(ctx, db, roleId, petId)
-	if err != nil {
-		if !errors.Is(err, gorm.ErrRecordNotFound) {
+	var removed []*models.PetInstance
+	err := db.Transaction(func(db *gorm.DB) error {
+		// 数据库中原子更新，数量不足时更新失败
+		balance, err := models.PetRepo.AddNum(ctx, db, roleId, petId, count)
+		if err != nil {
+			if errors.Is(err, models.ErrNumNotEnough) {
+				return errors.NewResponseError(constant.MinionsNotEnough, errors.New("minions not enough"))
+			}
 			logger.Errorf("PetLogic.AddPet petId=%d error: %s", petId, err.Error())
-			return nil, err
+			return err
 		}
-	}
-
-	pet.RoleID = roleId
-	pet.PetID = petId
-	pet.PetNum = pet.PetNum + count
 
-	if pet.PetNum >= 0 {
-		if err := models.PetRepo.Save(ctx, db, pet); err != nil {
-			logger.Errorf("PetLogic.AddPet petId=%d error: %s", pet.PetID, err.Error())
-			return nil, err
+		// 记录账本
+		if err := LedgerLogic.Record(ctx, db, roleId, cfg.RewardType_Pet, petId, count, balance, source); err != nil {
+			return err
 		}
+
 		if count > 0 {
+			now := time.Now().Unix()
+			list := make([]*models.PetInstance, 0, count)
+			for i := int32(0); i < count; i++ {
+				pet := &models.PetInstance{RoleID: roleId, PetID: petId, Level: 1, CreatedAt: now}
+				if template != nil {
+					pet.ID = template.ID
+					pet.Level = template.Level
+					pet.Exp = template.Exp
+					pet.Stage = template.Stage
+					template = nil
+				}
+				list = append(list, pet)
+			}
+			if err := models.PetInstanceRepo.Create(ctx, db, list); err != nil {
+				return err
+			}
+
 			// 记录收集宠物进度
 			if err := TaskLogic.RecordTaskProgress(ctx, db, roleId, 4, count); err != nil {
-				return nil, err
+				return err
+			}
+		} else if count < 0 {
+			if removed, err = models.PetInstanceRepo.FindLowestForUpdate(ctx, db, roleId, petId, int(-count)); err != nil {
+				return err
+			}
+			ids := make([]uint64, 0, len(removed))
+			for _, pet := range removed {
+				ids = append(ids, pet.ID)
+			}
+			if err := models.PetInstanceRepo.DeleteByIds(ctx, db, ids); err != nil {
+				return err
 			}
 		}
-	} else {
-		return nil, errors.NewResponseError(constant.MinionsNotEnough, errors.New("minions not enough"))
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
 	}
 
 	resp := new(schema.RewardData)
@@ -61,5 +139,187 @@ func (s *petLogic) AddPet(ctx context.Context, db *gorm.DB, roleId uint64, petId
 	itemData.Num = count
 	itemData.Type = cfg.RewardType_Pet
 	resp.Item = itemData
+	return removed, resp, nil
+}
+
+// 增加经验并升级，达到当前阶段的最高等级后多余的经验作废
+func (s *petLogic) addExp(pet *models.PetInstance, exp int32) {
+	evolutionConfig := s.evolution(pet.PetID, pet.Stage)
+	if evolutionConfig == nil {
+		return
+	}
+
+	pet.Exp += exp
+	for pet.Level < evolutionConfig.MaxLevel && pet.Exp >= evolutionConfig.LevelExp*pet.Level {
+		pet.Exp -= evolutionConfig.LevelExp * pet.Level
+		pet.Level++
+	}
+	if pet.Level >= evolutionConfig.MaxLevel {
+		pet.Exp = 0
+	}
+}
+
+// AddBattleExp 存活的回合给同种宠物中阶段和等级最高的宠物增加经验
+func (s *petLogic) AddBattleExp(ctx context.Context, db *gorm.DB, roleId uint64, petId int32, rounds int32) error {
+	pet, err := models.PetInstanceRepo.FindHighestForUpdate(ctx, db, roleId, petId)
+	if err != nil {
+		// 同种宠物都已经消耗了
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	evolutionConfig := s.evolution(pet.PetID, pet.Stage)
+	if evolutionConfig == nil || evolutionConfig.RoundExp*rounds <= 0 {
+		return nil
+	}
+
+	s.addExp(pet, evolutionConfig.RoundExp*rounds)
+	return models.PetInstanceRepo.Updates(ctx, db, pet.ID, map[string]interface{}{"level": pet.Level, "exp": pet.Exp})
+}
+
+// Feed 喂养同种的宠物和道具增加经验
+func (s *petLogic) Feed(ctx context.Context, db *gorm.DB, roleId uint64, req *schema.PetFeedReq) (*schema.PetFeedResp, error) {
+	logger := contextx.FromLogger(ctx)
+
+	ids := make([]uint64, 0, len(req.PetIds))
+	for _, id := range req.PetIds {
+		if id == req.ID || utils.InArray(ids, id) {
+			return nil, errors.NewResponseError(constant.ParametersInvalid, nil)
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 && req.ItemNum <= 0 {
+		return nil, errors.NewResponseError(constant.ParametersInvalid, nil)
+	}
+
+	var feedItemConfig *cfg.IPetFeedItem
+	if req.ItemNum > 0 {
+		if feedItemConfig = s.tables.PetFeedItemTb.Get(req.ItemId); feedItemConfig == nil {
+			return nil, errors.NewResponseError(constant.ParametersInvalid, nil)
+		}
+	}
+
+	resp := new(schema.PetFeedResp)
+	err := db.Transaction(func(db *gorm.DB) error {
+		pet, err := models.PetInstanceRepo.GetForUpdate(ctx, db, roleId, req.ID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.NewResponseError(constant.MinionsNotFound, nil)
+			}
+			return err
+		}
+
+		evolutionConfig := s.evolution(pet.PetID, pet.Stage)
+		if evolutionConfig == nil {
+			return errors.NewResponseError(constant.MinionsNotFound, nil)
+		}
+		if pet.Level >= evolutionConfig.MaxLevel {
+			return errors.NewResponseError(constant.PetMaxLevel, nil)
+		}
+
+		var exp int32
+		if len(ids) > 0 {
+			foods, err := models.PetInstanceRepo.FindByIdsForUpdate(ctx, db, roleId, ids)
+			if err != nil {
+				return err
+			}
+			if len(foods) != len(ids) {
+				return errors.NewResponseError(constant.MinionsNotFound, nil)
+			}
+			for _, food := range foods {
+				if food.PetID != pet.PetID {
+					return errors.NewResponseError(constant.ParametersInvalid, nil)
+				}
+				if foodConfig := s.evolution(food.PetID, food.Stage); foodConfig != nil {
+					exp += foodConfig.FeedExp
+				}
+			}
+
+			// 减少宠物数量并删除喂掉的宠物
+			balance, err := models.PetRepo.AddNum(ctx, db, roleId, pet.PetID, -int32(len(foods)))
+			if err != nil {
+				if errors.Is(err, models.ErrNumNotEnough) {
+					return errors.NewResponseError(constant.MinionsNotEnough, nil)
+				}
+				return err
+			}
+			if err := LedgerLogic.Record(ctx, db, roleId, cfg.RewardType_Pet, pet.PetID, -int32(len(foods)), balance, constant.SourcePetFeed); err != nil {
+				return err
+			}
+			if err := models.PetInstanceRepo.DeleteByIds(ctx, db, ids); err != nil {
+				return err
+			}
+		}
+
+		if feedItemConfig != nil {
+			if _, err := ItemLogic.UpdateItemCount(ctx, db, roleId, req.ItemId, -req.ItemNum, constant.SourcePetFeed); err != nil {
+				return err
+			}
+			exp += feedItemConfig.Exp * req.ItemNum
+		}
+
+		s.addExp(pet, exp)
+		if err := models.PetInstanceRepo.Updates(ctx, db, pet.ID, map[string]interface{}{"level": pet.Level, "exp": pet.Exp}); err != nil {
+			return err
+		}
+
+		resp.Pet = s.toPetInstance(pet)
+		resp.FedIds = ids
+		return nil
+	})
+
+	if err != nil {
+		logger.Errorf("PetLogic.Feed error: %s", err.Error())
+		return nil, err
+	}
 	return resp, nil
 }
+
+// Evolve 达到当前阶段的最高等级后消耗道具进化到下一阶段
+func (s *petLogic) Evolve(ctx context.Context, db *gorm.DB, roleId uint64, req *schema.PetEvolveReq) (*schema.PetEvolveResp, error) {
+	logger := contextx.FromLogger(ctx)
+
+	resp := new(schema.PetEvolveResp)
+	err := db.Transaction(func(db *gorm.DB) error {
+		pet, err := models.PetInstanceRepo.GetForUpdate(ctx, db, roleId, req.ID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.NewResponseError(constant.MinionsNotFound, nil)
+			}
+			return err
+		}
+
+		evolutionConfig := s.evolution(pet.PetID, pet.Stage)
+		if evolutionConfig == nil || s.evolution(pet.PetID, pet.Stage+1) == nil || pet.Level < evolutionConfig.MaxLevel {
+			return errors.NewResponseError(constant.PetCannotEvolve, nil)
+		}
+
+		if evolutionConfig.CostId != 0 {
+			if _, err := ItemLogic.UpdateItemCount(ctx, db, roleId, evolutionConfig.CostId, -evolutionConfig.CostNum, constant.SourcePetEvolve); err != nil {
+				return err
+			}
+		}
+
+		pet.Stage++
+		if err := models.PetInstanceRepo.Updates(ctx, db, pet.ID, map[string]interface{}{"stage": pet.Stage}); err != nil {
+			return err
+		}
+
+		resp.Pet = s.toPetInstance(pet)
+		return nil
+	})
+
+	if err != nil {
+		logger.Errorf("PetLogic.Evolve error: %s", err.Error())
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s *petLogic) toPetInstance(pet *models.PetInstance) *schema.PetInstance {
+	data := new(schema.PetInstance)
+	utils.Copy(data, pet)
+	return data
+}
@@ -0,0 +1,139 @@
+package logic
+
+import (
+	"context"
+	"eggServer/internal/config"
+	"eggServer/internal/constant"
+	"eggServer/internal/contextx"
+	"eggServer/pkg/errors"
+	"eggServer/pkg/oracle"
+	"encoding/json"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
+	"log"
+	"net/http"
+	"time"
+)
+
+var (
+	PriceKey = "price:%s"
+)
+
+var PriceLogic = new(priceLogic)
+
+type priceLogic struct {
+	oracle       oracle.PriceOracle
+	cache        priceCacheStore
+	override     map[string]float64 // 人工指定的价格
+	cacheExpired time.Duration      // 缓存时间
+	maxStale     time.Duration      // 价格源都不可用时缓存的最大时效
+	group        singleflight.Group // 同一个实例的并发请求只查询一次价格源
+}
+
+// 缓存的报价
+type priceCache struct {
+	Price float64 `json:"price"`
+	Time  int64   `json:"time"` // 报价时间
+}
+
+// 报价的缓存
+type priceCacheStore interface {
+	Get(ctx context.Context, instId string) (*priceCache, error)
+	Set(ctx context.Context, instId string, cache *priceCache, expiration time.Duration) error
+}
+
+func (s *priceLogic) Init() {
+	c := config.C.PriceOracle
+	client := &http.Client{Timeout: c.Timeout * time.Second}
+
+	oracles := make([]oracle.PriceOracle, 0, len(c.Providers))
+	for _, name := range c.Providers {
+		o, err := oracle.New(name, client, c.Static)
+		if err != nil {
+			log.Fatal(err)
+		}
+		oracles = append(oracles, oracle.NewBreaker(o, c.BreakerThreshold, c.BreakerCooldown*time.Second))
+	}
+
+	s.oracle = oracle.NewMedian(c.MaxStale*time.Second, c.MinSources, c.Timeout*time.Second, oracles...)
+	s.cache = new(redisPriceCache)
+	s.override = c.Override
+	s.cacheExpired = c.CacheExpired * time.Second
+	s.maxStale = c.MaxStale * time.Second
+}
+
+// Price 行情价格，配置了人工价格时直接使用，价格源都不可用时使用未过期的缓存
+func (s *priceLogic) Price(ctx context.Context, instId string) (float64, error) {
+	if price := s.override[instId]; price > 0 {
+		return price, nil
+	}
+
+	// 查询价格源时不持有分布式锁，多个实例同时过期最多各查询一次
+	v, err, _ := s.group.Do(instId, func() (interface{}, error) {
+		return s.price(ctx, instId)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return v.(float64), nil
+}
+
+func (s *priceLogic) price(ctx context.Context, instId string) (float64, error) {
+	logger := contextx.FromLogger(ctx)
+	now := time.Now()
+
+	cache, err := s.cache.Get(ctx, instId)
+	if err != nil {
+		logger.Errorf("PriceLogic.Price error:%s", err.Error())
+		cache = new(priceCache)
+	}
+
+	cacheAt := time.Unix(cache.Time, 0)
+	if cache.Price > 0 && now.Sub(cacheAt) < s.cacheExpired {
+		return cache.Price, nil
+	}
+
+	quote, err := s.oracle.Price(ctx, instId)
+	if err != nil {
+		logger.Errorf("PriceLogic.Price %s error:%s", instId, err.Error())
+
+		if cache.Price > 0 && now.Sub(cacheAt) <= s.maxStale {
+			logger.Warnf("PriceLogic.Price %s use cached price %v at %d", instId, cache.Price, cache.Time)
+			return cache.Price, nil
+		}
+		return 0, errors.NewResponseError(constant.UnknownError, err)
+	}
+
+	if err := s.cache.Set(ctx, instId, &priceCache{Price: quote.Price, Time: quote.Time.Unix()}, s.maxStale); err != nil {
+		logger.Errorf("PriceLogic.Price error:%s", err.Error())
+	}
+
+	return quote.Price, nil
+}
+
+// 保存在redis中的报价缓存，多个实例共用
+type redisPriceCache struct{}
+
+func (c *redisPriceCache) Get(ctx context.Context, instId string) (*priceCache, error) {
+	cache := new(priceCache)
+	result, err := contextx.FromRB(ctx).Client().Get(ctx, fmt.Sprintf(PriceKey, instId)).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return cache, nil
+		}
+		return cache, err
+	}
+	if err := json.Unmarshal([]byte(result), cache); err != nil {
+		return new(priceCache), err
+	}
+	return cache, nil
+}
+
+func (c *redisPriceCache) Set(ctx context.Context, instId string, cache *priceCache, expiration time.Duration) error {
+	val, err := json.Marshal(cache)
+	if err != nil {
+		return err
+	}
+	return contextx.FromRB(ctx).Client().Set(ctx, fmt.Sprintf(PriceKey, instId), val, expiration).Err()
+}
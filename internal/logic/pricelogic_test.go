@@ -0,0 +1,111 @@
+package logic
+
+import (
+	"context"
+	"eggServer/internal/constant"
+	"eggServer/pkg/errors"
+	"eggServer/pkg/oracle"
+	"testing"
+	"time"
+)
+
+// 内存中的报价缓存
+type fakePriceCache struct {
+	caches map[string]*priceCache
+}
+
+func (c *fakePriceCache) Get(ctx context.Context, instId string) (*priceCache, error) {
+	if cache, ok := c.caches[instId]; ok {
+		return cache, nil
+	}
+	return new(priceCache), nil
+}
+
+func (c *fakePriceCache) Set(ctx context.Context, instId string, cache *priceCache, expiration time.Duration) error {
+	c.caches[instId] = cache
+	return nil
+}
+
+// 可以控制报价和错误的价格源
+type fakePriceOracle struct {
+	price float64
+	err   error
+	calls int
+}
+
+func (o *fakePriceOracle) Name() string {
+	return "fake"
+}
+
+func (o *fakePriceOracle) Price(ctx context.Context, instId string) (oracle.Quote, error) {
+	o.calls++
+	return oracle.Quote{Price: o.price, Time: time.Now()}, o.err
+}
+
+func newTestPriceLogic(o oracle.PriceOracle, caches map[string]*priceCache) *priceLogic {
+	return &priceLogic{
+		oracle:       o,
+		cache:        &fakePriceCache{caches: caches},
+		override:     map[string]float64{},
+		cacheExpired: time.Minute,
+		maxStale:     5 * time.Minute,
+	}
+}
+
+func TestPriceUsesFreshCache(t *testing.T) {
+	o := &fakePriceOracle{price: 3}
+	s := newTestPriceLogic(o, map[string]*priceCache{"TON-USDT": {Price: 2, Time: time.Now().Unix()}})
+
+	price, err := s.Price(context.Background(), "TON-USDT")
+	if err != nil || price != 2 {
+		t.Fatalf("price %v error %v, want 2", price, err)
+	}
+	if o.calls != 0 {
+		t.Fatalf("oracle called %d times, want 0", o.calls)
+	}
+}
+
+func TestPriceRefreshesExpiredCache(t *testing.T) {
+	o := &fakePriceOracle{price: 3}
+	caches := map[string]*priceCache{"TON-USDT": {Price: 2, Time: time.Now().Add(-2 * time.Minute).Unix()}}
+	s := newTestPriceLogic(o, caches)
+
+	price, err := s.Price(context.Background(), "TON-USDT")
+	if err != nil || price != 3 {
+		t.Fatalf("price %v error %v, want 3", price, err)
+	}
+	if caches["TON-USDT"].Price != 3 {
+		t.Fatalf("cached price %v, want 3", caches["TON-USDT"].Price)
+	}
+}
+
+// 价格源都不可用时使用未超过最大时效的缓存
+func TestPriceFallsBackToStaleCache(t *testing.T) {
+	o := &fakePriceOracle{err: oracle.ErrNotEnoughSources}
+	s := newTestPriceLogic(o, map[string]*priceCache{"TON-USDT": {Price: 2, Time: time.Now().Add(-2 * time.Minute).Unix()}})
+
+	price, err := s.Price(context.Background(), "TON-USDT")
+	if err != nil || price != 2 {
+		t.Fatalf("price %v error %v, want 2", price, err)
+	}
+
+	s = newTestPriceLogic(o, map[string]*priceCache{"TON-USDT": {Price: 2, Time: time.Now().Add(-10 * time.Minute).Unix()}})
+	var e *errors.ResponseError
+	if _, err := s.Price(context.Background(), "TON-USDT"); !errors.As(err, &e) || e.Code != constant.UnknownError {
+		t.Fatalf("error %v, want code %d", err, constant.UnknownError)
+	}
+}
+
+func TestPriceOverride(t *testing.T) {
+	o := &fakePriceOracle{err: oracle.ErrNotEnoughSources}
+	s := newTestPriceLogic(o, map[string]*priceCache{})
+	s.override["TON-USDT"] = 5
+
+	price, err := s.Price(context.Background(), "TON-USDT")
+	if err != nil || price != 5 {
+		t.Fatalf("price %v error %v, want 5", price, err)
+	}
+	if o.calls != 0 {
+		t.Fatalf("oracle called %d times, want 0", o.calls)
+	}
+}
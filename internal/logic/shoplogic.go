@@ -10,32 +10,82 @@ import (
 	"eggServer/internal/schema"
 	"eggServer/pkg/errors"
 	"eggServer/pkg/utils"
+	"eggServer/pkg/utils/weighted"
 	"encoding/json"
 	"fmt"
 	"github.com/spf13/cast"
 	"github.com/tonkeeper/tonapi-go"
 	"gorm.io/gorm"
-	"math/rand"
+	"log"
+	"sort"
 	"time"
 )
 
+// 订单的读取和状态修改
+type orderStore interface {
+	Get(ctx context.Context, db *gorm.DB, orderId int64) (*models.Order, error)
+	UpdateStatus(ctx context.Context, db *gorm.DB, orderId int64, status []byte, values interface{}) (bool, error)
+}
+
+// 没有计入订单的付款的记录
+type orderPaymentStore interface {
+	Create(ctx context.Context, db *gorm.DB, payment *models.OrderPayment) error
+}
+
+// 发放订单的道具
+type orderItemAdder func(ctx context.Context, db *gorm.DB, roleId uint64, itemId int32, count int32, itemType int32, source int32) (*schema.RewardData, error)
+
 var ShopLogic = new(shopLogic)
 
 type shopLogic struct {
-	tables          *cfg.Tables
-	shopTotalWeight map[int32]int32
-	shopWeightTb    map[int32][]*cfg.IDailyShop
+	tables       *cfg.Tables
+	source       weighted.Source
+	orders       orderStore
+	payments     orderPaymentStore
+	addItem      orderItemAdder
+	shopGroupIds []int32 // 按组id排序，保证随机数源相同时结果相同
+	shopSampler  map[int32]*weighted.Sampler[*cfg.IDailyShop]
 }
 
 func (s *shopLogic) Init(tables *cfg.Tables) {
 	s.tables = tables
-	s.shopTotalWeight = make(map[int32]int32)
-	s.shopWeightTb = make(map[int32][]*cfg.IDailyShop)
+	if s.source == nil {
+		s.source = weighted.Default
+	}
+	if s.orders == nil {
+		s.orders = models.OrderRepo
+	}
+	if s.payments == nil {
+		s.payments = models.OrderPaymentRepo
+	}
+	if s.addItem == nil {
+		s.addItem = UtilsLogic.AddItem
+	}
+
+	shopWeightTb := make(map[int32][]*cfg.IDailyShop)
+	shopWeights := make(map[int32][]int32)
 	shopList := s.tables.DailyShopTb.GetDataList()
 	for _, v := range shopList {
-		s.shopTotalWeight[v.GroupId] = s.shopTotalWeight[v.GroupId] + v.Weight
-		s.shopWeightTb[v.GroupId] = append(s.shopWeightTb[v.GroupId], v)
+		shopWeightTb[v.GroupId] = append(shopWeightTb[v.GroupId], v)
+		shopWeights[v.GroupId] = append(shopWeights[v.GroupId], v.Weight)
 	}
+
+	s.shopGroupIds = make([]int32, 0, len(shopWeightTb))
+	s.shopSampler = make(map[int32]*weighted.Sampler[*cfg.IDailyShop])
+	for groupId, list := range shopWeightTb {
+		sampler, err := weighted.New(list, shopWeights[groupId])
+		if err != nil {
+			log.Fatalf("ShopLogic.Init group %d error:%s", groupId, err.Error())
+		}
+		s.shopGroupIds = append(s.shopGroupIds, groupId)
+		s.shopSampler[groupId] = sampler
+	}
+	sort.Slice(s.shopGroupIds, func(i, j int) bool { return s.shopGroupIds[i] < s.shopGroupIds[j] })
+}
+
+// SetSource 替换随机数源
+func (s *shopLogic) SetSource(source weighted.Source) {
+	s.source = source
 }
 
 func (s *shopLogic) Daily(ctx context.Context, db *gorm.DB, role *models.Role) (*schema.ShopRefreshResp, error) {
@@ -43,18 +93,9 @@ func (s *shopLogic) Daily(ctx context.Context, db *gorm.DB, role *models.Role) (
 }
 
 func (s *shopLogic) calcRefresh(roleId uint64, shop *models.Shop, free bool) *models.Shop {
-	shopList := make([]*cfg.IDailyShop, 0)
-	for groupId, v := range s.shopTotalWeight {
-		var n = rand.Int31n(v) + 1
-		var temp int32 = 0
-		tempList := s.shopWeightTb[groupId]
-		for i := 0; i < len(tempList); i++ {
-			if n > temp && n <= temp+tempList[i].Weight {
-				shopList = append(shopList, tempList[i])
-				break
-			}
-			temp = temp + tempList[i].Weight
-		}
+	shopList := make([]*cfg.IDailyShop, 0, len(s.shopGroupIds))
+	for _, groupId := range s.shopGroupIds {
+		shopList = append(shopList, s.shopSampler[groupId].Sample(s.source))
 	}
 	shop.RoleID = roleId
 	shop.Discount = make([]int32, 0)
@@ -64,7 +105,7 @@ func (s *shopLogic) calcRefresh(roleId uint64, shop *models.Shop, free bool) *mo
 		if v.Discount[1] == v.Discount[0] {
 			shop.Discount = append(shop.Discount, v.Discount[0])
 		} else {
-			shop.Discount = append(shop.Discount, (rand.Int31n((v.Discount[1]-v.Discount[0])/10+1)+v.Discount[0]/10)*10)
+			shop.Discount = append(shop.Discount, (int32(s.source.Intn(int((v.Discount[1]-v.Discount[0])/10+1)))+v.Discount[0]/10)*10)
 		}
 	}
 	shop.Buy = make([]int, len(shopList))
@@ -95,7 +136,7 @@ func (s *shopLogic) Refresh(ctx context.Context, db *gorm.DB, roleId uint64, fre
 		err := db.Transaction(func(db *gorm.DB) error {
 			if !free {
 				item := shopRefresh[shop.RefreshTimes-1]
-				reward, err := UtilsLogic.AddItem(ctx, db, roleId, item.Id, -item.Num, item.Type)
+				reward, err := UtilsLogic.AddItem(ctx, db, roleId, item.Id, -item.Num, item.Type, constant.SourceShopRefresh)
 				if err != nil {
 					return err
 				}
@@ -150,7 +191,7 @@ func (s *shopLogic) dealBuy(ctx context.Context, db *gorm.DB, roleId uint64, pri
 
 	err := db.Transaction(func(db *gorm.DB) error {
 		if price.Id > 0 {
-			reward, err := UtilsLogic.AddItem(ctx, db, roleId, price.Id, -price.Num, price.Type)
+			reward, err := UtilsLogic.AddItem(ctx, db, roleId, price.Id, -price.Num, price.Type, constant.SourceShopBuy)
 			if err != nil {
 				return err
 			}
@@ -165,7 +206,7 @@ func (s *shopLogic) dealBuy(ctx context.Context, db *gorm.DB, roleId uint64, pri
 
 		if itemConfig.UseType == 1 {
 			for k := 0; k < int(item.Num); k++ {
-				reward, err := UtilsLogic.AddItem(ctx, db, roleId, item.Id, 1, item.Type)
+				reward, err := UtilsLogic.AddItem(ctx, db, roleId, item.Id, 1, item.Type, constant.SourceShopBuy)
 				if err != nil {
 					return err
 				}
@@ -174,7 +215,7 @@ func (s *shopLogic) dealBuy(ctx context.Context, db *gorm.DB, roleId uint64, pri
 				}
 			}
 		} else {
-			reward, err := UtilsLogic.AddItem(ctx, db, roleId, item.Id, item.Num, item.Type)
+			reward, err := UtilsLogic.AddItem(ctx, db, roleId, item.Id, item.Num, item.Type, constant.SourceShopBuy)
 			if err != nil {
 				return err
 			}
@@ -218,7 +259,7 @@ func (s *shopLogic) dailyBuy(ctx context.Context, db *gorm.DB, roleId uint64, id
 	err = db.Transaction(func(db *gorm.DB) error {
 		if shopConfig.Price.Id > 0 {
 			price := shopConfig.Price
-			reward, err := UtilsLogic.AddItem(ctx, db, roleId, price.Id, -price.Num*shop.Discount[index]/100, price.Type)
+			reward, err := UtilsLogic.AddItem(ctx, db, roleId, price.Id, -price.Num*shop.Discount[index]/100, price.Type, constant.SourceShopBuy)
 			if err != nil {
 				return err
 			}
@@ -245,7 +286,7 @@ func (s *shopLogic) dailyBuy(ctx context.Context, db *gorm.DB, roleId uint64, id
 
 		if itemConfig.UseType == 1 {
 			for k := 0; k < int(item.Num); k++ {
-				reward, err := UtilsLogic.AddItem(ctx, db, roleId, item.Id, 1, item.Type)
+				reward, err := UtilsLogic.AddItem(ctx, db, roleId, item.Id, 1, item.Type, constant.SourceShopBuy)
 				if err != nil {
 					return err
 				}
@@ -254,7 +295,7 @@ func (s *shopLogic) dailyBuy(ctx context.Context, db *gorm.DB, roleId uint64, id
 				}
 			}
 		} else {
-			reward, err := UtilsLogic.AddItem(ctx, db, roleId, item.Id, item.Num, item.Type)
+			reward, err := UtilsLogic.AddItem(ctx, db, roleId, item.Id, item.Num, item.Type, constant.SourceShopBuy)
 			if err != nil {
 				return err
 			}
@@ -326,7 +367,7 @@ func (s *shopLogic) CreateOrder(ctx context.Context, db *gorm.DB, roleId uint64,
 	order := new(models.Order)
 
 	if req.Currency == constant.TON {
-		ton, err := TonapiLogic.Ticker(ctx, "TON-USDT")
+		ton, err := PriceLogic.Price(ctx, "TON-USDT")
 		if err != nil {
 			return nil, err
 		}
@@ -346,8 +387,9 @@ func (s *shopLogic) CreateOrder(ctx context.Context, db *gorm.DB, roleId uint64,
 	order.RoleID = roleId
 	order.Platform = user.Platform
 	order.CreatedAt = time.Now().Unix()
+	order.ExpireAt = order.CreatedAt + int64(config.C.Order.PayTimeout)
 	order.Currency = req.Currency
-	order.OrderStatus = 0
+	order.OrderStatus = constant.OrderStatusPending
 	order.ShopId = req.ID
 	order.ShopType = req.ShopType
 
@@ -358,6 +400,7 @@ func (s *shopLogic) CreateOrder(ctx context.Context, db *gorm.DB, roleId uint64,
 	resp := new(schema.ShopCreateOrderResp)
 	resp.Price = order.TotalAmount
 	resp.OrderId = cast.ToString(order.OrderId)
+	resp.ExpireAt = order.ExpireAt
 	fmt.Printf("订单id: %s\n", resp.OrderId)
 
 	return resp, nil
@@ -380,6 +423,7 @@ func (s *shopLogic) Delivery(ctx context.Context, db *gorm.DB, roleId uint64, re
 		success := transaction.Success
 		opCode := transaction.OutMsgs[0].OpCode.Value
 		var orderId int64
+		currency := constant.TON
 
 		if opCode == "0x0f8a7ea5" { // jetton转账
 			type Body struct {
@@ -409,6 +453,7 @@ func (s *shopLogic) Delivery(ctx context.Context, db *gorm.DB, roleId uint64, re
 			orderId = cast.ToInt64(body.ForwardPayload.Value.Value.Text)
 			amount = cast.ToInt64(body.Amount)
 			receiver = body.Destination
+			currency = constant.USDT
 		} else if opCode == "0x00000000" { // ton转账
 			type Body struct {
 				Text string `json:"text"`
@@ -428,76 +473,224 @@ func (s *shopLogic) Delivery(ctx context.Context, db *gorm.DB, roleId uint64, re
 		fmt.Printf("交易时间：%d 发送者: %s 接收者: %s 发送了: %d TON备注信息: %s 是否成功: %v\n", transactionTime, sender, receiver, amount, transaction.OutMsgs[0].DecodedBody.String(), success)
 
 		if receiver == config.C.WalletAddress {
-			order, err := models.OrderRepo.Get(ctx, db, orderId)
-			if err != nil {
-				logger.Errorf("ShopLogic.Delivery error: %s", err.Error())
-				return nil, err
+			transfer := &ChainTransfer{
+				Hash:     req.TransactionID,
+				Utime:    transactionTime,
+				Sender:   sender,
+				Currency: currency,
+				Amount:   amount,
+				OrderId:  orderId,
 			}
+			return s.deliverOrder(ctx, db, roleId, transfer)
+		}
+	}
 
-			fmt.Printf("订单状态：%d 订单金额: %d 实付金额: %d 订单创建时间: %d 交易时间: %d\n", order.OrderStatus, order.TotalAmount, amount, order.CreatedAt, transactionTime)
-			if order.OrderStatus == 0 && order.TotalAmount == amount && order.CreatedAt <= transactionTime {
-				resp := new(schema.ShopDeliveryResp)
-
-				var item *cfg.GlobalItemData
-				if order.ShopType == cfg.ShopType_Diamond {
-					diamondShopConfig := s.tables.DiamondShopTb.Get(order.ShopId)
-					item = diamondShopConfig.Item
-				} else if order.ShopType == cfg.ShopType_Special {
-					specialShopConfig := s.tables.SpecialShopTb.Get(order.ShopId)
-					item = specialShopConfig.Item
-				} else {
-					return nil, errors.NewResponseError(constant.ParametersInvalid, nil)
-				}
+	return nil, errors.NewResponseError(constant.UnknownError, err)
+}
 
-				err = db.Transaction(func(db *gorm.DB) error {
-					itemConfig := s.tables.ItemTb.Get(item.Id)
-					if itemConfig == nil {
-						logger.Errorf("ShopLogic.Delivery itemId=%d item not found", item.Id)
-						return errors.New("item not found")
-					}
-
-					if itemConfig.UseType == 1 {
-						for k := 0; k < int(item.Num); k++ {
-							reward, err := UtilsLogic.AddItem(ctx, db, roleId, item.Id, 1, item.Type)
-							if err != nil {
-								return err
-							}
-							if reward != nil {
-								resp.RewardList = append(resp.RewardList, reward)
-							}
-						}
-					} else {
-						reward, err := UtilsLogic.AddItem(ctx, db, roleId, item.Id, item.Num, item.Type)
-						if err != nil {
-							return err
-						}
-						if reward != nil {
-							resp.RewardList = append(resp.RewardList, reward)
-						}
-					}
-
-					if err := models.OrderRepo.Updates(ctx, db, orderId, map[string]interface{}{"orderStatus": 1, "updatedAt": time.Now().Unix()}); err != nil {
-						return err
-					}
-					return nil
-				})
+// 根据链上转账给订单发货，roleId为0时不检查订单所属玩家
+func (s *shopLogic) deliverOrder(ctx context.Context, db *gorm.DB, roleId uint64, transfer *ChainTransfer) (*schema.ShopDeliveryResp, error) {
+	logger := contextx.FromLogger(ctx)
 
-				if err != nil {
-					logger.Errorf("ShopLogic.Delivery error: %s", err.Error())
-					return nil, err
-				}
+	order, err := s.orders.Get(ctx, db, transfer.OrderId)
+	if err != nil {
+		logger.Errorf("ShopLogic.deliverOrder error: %s", err.Error())
+		return nil, err
+	}
+
+	if roleId != 0 && order.RoleID != roleId {
+		return nil, errors.NewResponseError(constant.ParametersInvalid, nil)
+	}
+
+	logger.Infof("ShopLogic.deliverOrder orderId=%d status=%d totalAmount=%d amount=%d createdAt=%d utime=%d", order.OrderId, order.OrderStatus, order.TotalAmount, transfer.Amount, order.CreatedAt, transfer.Utime)
+
+	// 已经收到付款的订单只需要发货，收到的其他付款需要退款
+	if order.OrderStatus != constant.OrderStatusPaid {
+		if err := s.payOrder(ctx, db, order, transfer); err != nil {
+			return nil, err
+		}
+	} else if err := s.recordPayment(ctx, db, order, transfer, nil); err != nil {
+		return nil, err
+	}
+
+	return s.deliverPaidOrder(ctx, db, order)
+}
+
+// 记录订单的付款，付款有效时订单状态改为已支付
+func (s *shopLogic) payOrder(ctx context.Context, db *gorm.DB, order *models.Order, transfer *ChainTransfer) error {
+	logger := contextx.FromLogger(ctx)
+
+	// 订单已经处理过，付款单独记录
+	status, code, err := s.matchPayment(order, transfer)
+	if err != nil {
+		return s.recordPayment(ctx, db, order, transfer, err)
+	}
+
+	// 订单已经记录了一笔超时的付款，之后的付款单独记录，都需要人工退款
+	if code == constant.OrderExpired && order.TxHash != "" {
+		return s.recordPayment(ctx, db, order, transfer, errors.NewResponseError(constant.OrderExpired, nil))
+	}
+
+	values := map[string]interface{}{"orderStatus": status, "paidAmount": transfer.Amount, "txHash": transfer.Hash, "updatedAt": time.Now().Unix()}
+	ok, err := s.orders.UpdateStatus(ctx, db, order.OrderId, []byte{order.OrderStatus}, values)
+	if err != nil {
+		logger.Errorf("ShopLogic.payOrder error: %s", err.Error())
+		return err
+	}
+	if !ok {
+		// 订单状态已经被其他请求修改
+		return errors.NewResponseError(constant.OrderStatusInvalid, nil)
+	}
+
+	if code != 0 {
+		logger.Warnf("ShopLogic.payOrder orderId=%d hash=%s amount=%d need refund", order.OrderId, transfer.Hash, transfer.Amount)
+		return errors.NewResponseError(code, nil)
+	}
+
+	order.OrderStatus = status
+	order.PaidAmount = transfer.Amount
+	order.TxHash = transfer.Hash
+	return nil
+}
+
+// 记录没有计入订单的付款，用于人工退款，记录成功后返回原来的错误
+func (s *shopLogic) recordPayment(ctx context.Context, db *gorm.DB, order *models.Order, transfer *ChainTransfer, cause error) error {
+	logger := contextx.FromLogger(ctx)
 
-				resp.ShopType = order.ShopType
-				resp.ShopId = order.ShopId
+	// 订单记录的就是这笔付款
+	if transfer.Hash == order.TxHash {
+		return cause
+	}
+
+	reason := constant.OrderDelivered
+	var e *errors.ResponseError
+	if errors.As(cause, &e) {
+		reason = e.Code
+	}
+
+	now := time.Now().Unix()
+	payment := &models.OrderPayment{
+		OrderId:   order.OrderId,
+		RoleID:    order.RoleID,
+		TxHash:    transfer.Hash,
+		Sender:    transfer.Sender,
+		Currency:  transfer.Currency,
+		Amount:    transfer.Amount,
+		Utime:     transfer.Utime,
+		Reason:    reason,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := s.payments.Create(ctx, db, payment); err != nil {
+		logger.Errorf("ShopLogic.recordPayment error: %s", err.Error())
+		return err
+	}
+
+	logger.Warnf("ShopLogic.recordPayment orderId=%d status=%d hash=%s amount=%d reason=%d need refund", order.OrderId, order.OrderStatus, transfer.Hash, transfer.Amount, reason)
+	return cause
+}
+
+// 检查付款和订单是否匹配，返回记录付款后订单的状态
+// code不为0时付款需要记录但不能发货，err不为nil时付款不属于该订单或者订单已经处理过
+func (s *shopLogic) matchPayment(order *models.Order, transfer *ChainTransfer) (byte, int, error) {
+	switch order.OrderStatus {
+	case constant.OrderStatusDelivered, constant.OrderStatusOverpaid:
+		return order.OrderStatus, 0, errors.NewResponseError(constant.OrderDelivered, nil)
+	case constant.OrderStatusPending, constant.OrderStatusExpired, constant.OrderStatusCancelled:
+	default:
+		return order.OrderStatus, 0, errors.NewResponseError(constant.OrderStatusInvalid, nil)
+	}
+
+	if order.OrderId != transfer.OrderId || order.Currency != transfer.Currency || transfer.Utime < order.CreatedAt {
+		return order.OrderStatus, 0, errors.NewResponseError(constant.ParametersInvalid, errors.New("payment is not match"))
+	}
+
+	// 在支付期限内付款的订单，即使已经被清理为过期也可以发货；取消的订单以取消时间为期限
+	deadline := order.ExpireAt
+	if deadline == 0 {
+		// 旧订单没有记录支付期限
+		deadline = order.CreatedAt + int64(config.C.Order.PayTimeout)
+	}
+	if order.OrderStatus == constant.OrderStatusCancelled {
+		deadline = order.UpdatedAt
+	}
+
+	switch {
+	case transfer.Utime > deadline:
+		return order.OrderStatus, constant.OrderExpired, nil
+	case transfer.Amount < order.TotalAmount:
+		return constant.OrderStatusUnderpaid, constant.OrderUnderpaid, nil
+	}
+	return constant.OrderStatusPaid, 0, nil
+}
+
+// 给已支付的订单发货，多付的订单发货后标记为多付
+func (s *shopLogic) deliverPaidOrder(ctx context.Context, db *gorm.DB, order *models.Order) (*schema.ShopDeliveryResp, error) {
+	logger := contextx.FromLogger(ctx)
+
+	var item *cfg.GlobalItemData
+	if order.ShopType == cfg.ShopType_Diamond {
+		diamondShopConfig := s.tables.DiamondShopTb.Get(order.ShopId)
+		item = diamondShopConfig.Item
+	} else if order.ShopType == cfg.ShopType_Special {
+		specialShopConfig := s.tables.SpecialShopTb.Get(order.ShopId)
+		item = specialShopConfig.Item
+	} else {
+		return nil, errors.NewResponseError(constant.ParametersInvalid, nil)
+	}
+
+	resp := new(schema.ShopDeliveryResp)
+	err := db.Transaction(func(db *gorm.DB) error {
+		// 先修改订单状态，保证同一个订单只发货一次
+		status := constant.OrderStatusDelivered
+		if order.PaidAmount > order.TotalAmount {
+			status = constant.OrderStatusOverpaid
+		}
+		ok, err := s.orders.UpdateStatus(ctx, db, order.OrderId, []byte{constant.OrderStatusPaid}, map[string]interface{}{"orderStatus": status, "updatedAt": time.Now().Unix()})
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errors.NewResponseError(constant.OrderDelivered, nil)
+		}
+
+		itemConfig := s.tables.ItemTb.Get(item.Id)
+		if itemConfig == nil {
+			logger.Errorf("ShopLogic.deliverPaidOrder itemId=%d item not found", item.Id)
+			return errors.New("item not found")
+		}
 
-				return resp, nil
+		if itemConfig.UseType == 1 {
+			for k := 0; k < int(item.Num); k++ {
+				reward, err := s.addItem(ctx, db, order.RoleID, item.Id, 1, item.Type, constant.SourceOrderDelivery)
+				if err != nil {
+					return err
+				}
+				if reward != nil {
+					resp.RewardList = append(resp.RewardList, reward)
+				}
 			}
 		} else {
-
+			reward, err := s.addItem(ctx, db, order.RoleID, item.Id, item.Num, item.Type, constant.SourceOrderDelivery)
+			if err != nil {
+				return err
+			}
+			if reward != nil {
+				resp.RewardList = append(resp.RewardList, reward)
+			}
 		}
+		return nil
+	})
+
+	if err != nil {
+		logger.Errorf("ShopLogic.deliverPaidOrder error: %s", err.Error())
+		return nil, err
 	}
 
-	return nil, errors.NewResponseError(constant.UnknownError, err)
+	resp.ShopType = order.ShopType
+	resp.ShopId = order.ShopId
+
+	return resp, nil
 }
 
 func (s *shopLogic) Share(ctx context.Context, db *gorm.DB, userId uint64, req *schema.ShopShareReq) (*schema.ShopShareResp, error) {
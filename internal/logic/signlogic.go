@@ -98,7 +98,7 @@ func (s *signLogic) SignIn(ctx context.Context, db *gorm.DB, roleId uint64, id i
 			if len(reSignConfigList) > sign.ReSign {
 				reSignConfig := reSignConfigList[sign.ReSign]
 				cost := reSignConfig.Cost
-				item, err := UtilsLogic.AddItem(ctx, db, roleId, cost.Id, -cost.Num, cost.Type)
+				item, err := UtilsLogic.AddItem(ctx, db, roleId, cost.Id, -cost.Num, cost.Type, constant.SourceSignIn)
 				if err != nil {
 					return nil, err
 				}
@@ -126,7 +126,7 @@ func (s *signLogic) SignIn(ctx context.Context, db *gorm.DB, roleId uint64, id i
 	}
 
 	for k := 0; k < rewardCount; k++ {
-		reward, err := UtilsLogic.AddItem(ctx, db, roleId, signConfig.Reward.Id, signConfig.Reward.Num, signConfig.Reward.Type)
+		reward, err := UtilsLogic.AddItem(ctx, db, roleId, signConfig.Reward.Id, signConfig.Reward.Num, signConfig.Reward.Type, constant.SourceSignIn)
 		if err != nil {
 			return nil, err
 		}
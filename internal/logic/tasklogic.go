@@ -128,7 +128,7 @@ func (s *taskLogic) GetTaskReward(ctx context.Context, db *gorm.DB, roleId uint6
 
 	var resp *schema.RewardData
 	err = db.Transaction(func(db *gorm.DB) error {
-		reward, err := UtilsLogic.AddItem(ctx, db, roleId, taskConfig.Reward.Id, taskConfig.Reward.Num, taskConfig.Reward.Type)
+		reward, err := UtilsLogic.AddItem(ctx, db, roleId, taskConfig.Reward.Id, taskConfig.Reward.Num, taskConfig.Reward.Type, constant.SourceTaskReward)
 		if err != nil {
 			logger.Errorf("TaskLogic.GetTaskReward error:%s", err.Error())
 			return err
@@ -0,0 +1,634 @@
+package logic
+
+import (
+	"context"
+	"eggServer/internal/constant"
+	"eggServer/internal/contextx"
+	cfg "eggServer/internal/gamedata"
+	"eggServer/internal/models"
+	"eggServer/internal/schema"
+	"eggServer/pkg/errors"
+	"eggServer/pkg/redisbackend"
+	"eggServer/pkg/utils"
+	"eggServer/pkg/utils/weighted"
+	"fmt"
+	"gorm.io/gorm"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	TournamentLockKey = "tournament:scheduler"
+)
+
+// 最多的轮次，达到后直接结束比赛
+const tournamentMaxStage = 10
+
+var TournamentLogic = new(tournamentLogic)
+
+type tournamentLogic struct {
+	tables *cfg.Tables
+	prizes map[int32][]*cfg.ITournamentPrize // 锦标赛id -> 名次奖励
+	source weighted.Source
+}
+
+func (s *tournamentLogic) Init(tables *cfg.Tables) {
+	s.tables = tables
+	s.prizes = make(map[int32][]*cfg.ITournamentPrize)
+	for _, v := range tables.TournamentPrizeTb.GetDataList() {
+		s.prizes[v.TournamentId] = append(s.prizes[v.TournamentId], v)
+	}
+
+	if s.source == nil {
+		s.source = weighted.Default
+	}
+}
+
+// SetSource 替换随机数源
+func (s *tournamentLogic) SetSource(source weighted.Source) {
+	s.source = source
+}
+
+// 随机打乱参赛玩家，决定分到的房间
+func (s *tournamentLogic) shuffleEntries(entries []*models.TournamentEntry) []*models.TournamentEntry {
+	list := make([]*models.TournamentEntry, len(entries))
+	copy(list, entries)
+	for i := len(list) - 1; i > 0; i-- {
+		j := s.source.Intn(i + 1)
+		list[i], list[j] = list[j], list[i]
+	}
+	return list
+}
+
+// 当前可以报名的比赛的开始时间，0表示不在报名时间
+func (s *tournamentLogic) registerStartAt(tournamentConfig *cfg.ITournament, now int64) int64 {
+	startAt := int64(tournamentConfig.FirstStart)
+	if now >= startAt {
+		// 只举办一次
+		if tournamentConfig.Interval <= 0 {
+			return 0
+		}
+		startAt += ((now-startAt)/int64(tournamentConfig.Interval) + 1) * int64(tournamentConfig.Interval)
+	}
+	if now < startAt-int64(tournamentConfig.RegisterTime) {
+		return 0
+	}
+	return startAt
+}
+
+// Register 报名当前开放报名的锦标赛
+func (s *tournamentLogic) Register(ctx context.Context, db *gorm.DB, roleId uint64, req *schema.TournamentRegisterReq) (*schema.TournamentRegisterResp, error) {
+	logger := contextx.FromLogger(ctx)
+
+	tournamentConfig := s.tables.TournamentTb.Get(req.TournamentId)
+	if tournamentConfig == nil || s.tables.PetTb.Get(req.PetId) == nil {
+		return nil, errors.NewResponseError(constant.ParametersInvalid, nil)
+	}
+
+	now := time.Now().Unix()
+	startAt := s.registerStartAt(tournamentConfig, now)
+	if startAt == 0 {
+		return nil, errors.NewResponseError(constant.TournamentNotOpen, nil)
+	}
+
+	resp := new(schema.TournamentRegisterResp)
+	err := db.Transaction(func(db *gorm.DB) error {
+		if err := models.TournamentRepo.Create(ctx, db, &models.Tournament{TournamentId: req.TournamentId, StartAt: startAt, CreatedAt: now}); err != nil {
+			return err
+		}
+
+		tournament, err := models.TournamentRepo.GetByStartAtForUpdate(ctx, db, req.TournamentId, startAt)
+		if err != nil {
+			return err
+		}
+		if tournament.State != models.TournamentStateRegister {
+			return errors.NewResponseError(constant.TournamentNotOpen, nil)
+		}
+		if tournament.PlayerNum >= tournamentConfig.MaxPlayer {
+			return errors.NewResponseError(constant.BattleRegistrationFull, nil)
+		}
+
+		if _, err := models.TournamentEntryRepo.GetForUpdate(ctx, db, tournament.ID, roleId); err == nil {
+			return errors.NewResponseError(constant.TournamentAlreadyRegistered, nil)
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		// 参赛的宠物
+		pet, err := models.PetRepo.Get(ctx, db, roleId, req.PetId)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if pet.PetNum <= 0 {
+			return errors.NewResponseError(constant.MinionsNotEnough, nil)
+		}
+
+		// 报名消耗
+		for _, need := range tournamentConfig.Need {
+			if need.Num <= 0 {
+				continue
+			}
+			reward, err := UtilsLogic.AddItem(ctx, db, roleId, need.Id, -need.Num, need.Type, constant.SourceTournamentEntry)
+			if err != nil {
+				return err
+			}
+			resp.RewardList = append(resp.RewardList, reward)
+		}
+
+		entry := &models.TournamentEntry{TournamentID: tournament.ID, RoleID: roleId, PetID: req.PetId, CreatedAt: now}
+		if err := models.TournamentEntryRepo.Create(ctx, db, entry); err != nil {
+			return err
+		}
+
+		tournament.PlayerNum++
+		if err := models.TournamentRepo.Updates(ctx, db, tournament.ID, map[string]interface{}{"playerNum": tournament.PlayerNum}); err != nil {
+			return err
+		}
+
+		resp.Tournament = s.toTournamentData(tournament)
+		return nil
+	})
+
+	if err != nil {
+		logger.Errorf("TournamentLogic.Register error:%s", err.Error())
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Bracket 锦标赛的对阵，每个玩家所在的轮次、房间和成绩
+func (s *tournamentLogic) Bracket(ctx context.Context, db *gorm.DB, roleId uint64, req *schema.TournamentBracketReq) (*schema.TournamentBracketResp, error) {
+	tournament, err := models.TournamentRepo.Get(ctx, db, req.ID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.NewResponseError(constant.ParametersInvalid, nil)
+		}
+		return nil, err
+	}
+
+	entries, err := models.TournamentEntryRepo.FindByTournamentId(ctx, db, tournament.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := new(schema.TournamentBracketResp)
+	resp.Tournament = s.toTournamentData(tournament)
+	utils.Copy(&resp.Entries, entries)
+	return resp, nil
+}
+
+// Claim 比赛结束后按名次领取奖励
+func (s *tournamentLogic) Claim(ctx context.Context, db *gorm.DB, roleId uint64, req *schema.TournamentClaimReq) (*schema.TournamentClaimResp, error) {
+	logger := contextx.FromLogger(ctx)
+
+	tournament, err := models.TournamentRepo.Get(ctx, db, req.ID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.NewResponseError(constant.ParametersInvalid, nil)
+		}
+		return nil, err
+	}
+	if tournament.State != models.TournamentStateFinished {
+		return nil, errors.NewResponseError(constant.BattleNotFinished, nil)
+	}
+
+	resp := new(schema.TournamentClaimResp)
+	err = db.Transaction(func(db *gorm.DB) error {
+		entry, err := models.TournamentEntryRepo.GetForUpdate(ctx, db, tournament.ID, roleId)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.NewResponseError(constant.ParametersInvalid, nil)
+			}
+			return err
+		}
+		if entry.Claimed == 1 {
+			return errors.NewResponseError(constant.TournamentNoPrize, nil)
+		}
+
+		prizeConfig := s.getPrize(tournament.TournamentId, entry.Rank)
+		if prizeConfig == nil {
+			return errors.NewResponseError(constant.TournamentNoPrize, nil)
+		}
+
+		for _, rewardData := range prizeConfig.Reward {
+			reward, err := UtilsLogic.AddItem(ctx, db, roleId, rewardData.Id, rewardData.Num, rewardData.Type, constant.SourceTournamentPrize)
+			if err != nil {
+				return err
+			}
+			resp.RewardList = append(resp.RewardList, reward)
+		}
+
+		if err := models.TournamentEntryRepo.Updates(ctx, db, entry.ID, map[string]interface{}{"claimed": 1}); err != nil {
+			return err
+		}
+		resp.Rank = entry.Rank
+		return nil
+	})
+
+	if err != nil {
+		logger.Errorf("TournamentLogic.Claim error:%s", err.Error())
+		return nil, err
+	}
+	return resp, nil
+}
+
+// 名次对应的奖励
+func (s *tournamentLogic) getPrize(tournamentId int32, rank int32) *cfg.ITournamentPrize {
+	if rank <= 0 {
+		return nil
+	}
+	for _, v := range s.prizes[tournamentId] {
+		if rank >= v.RankMin && rank <= v.RankMax {
+			return v
+		}
+	}
+	return nil
+}
+
+// RunScheduler 锦标赛调度器，负责开始比赛、分配房间、晋级和排名
+// 多个实例可以同时运行，通过分布式锁保证同一时间只有一个实例在调度
+func (s *tournamentLogic) RunScheduler(ctx context.Context, db *gorm.DB, rb *redisbackend.RedisBackend, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.schedule(ctx, db, rb)
+		}
+	}
+}
+
+func (s *tournamentLogic) schedule(ctx context.Context, db *gorm.DB, rb *redisbackend.RedisBackend) {
+	logger := contextx.FromLogger(ctx)
+
+	// 其他实例正在调度
+	m := rb.NewMutex(TournamentLockKey)
+	if err := m.TryLock(ctx); err != nil {
+		return
+	}
+
+	defer func() {
+		if _, err := m.Unlock(context.Background()); err != nil {
+			logger.WithError(err).Error("error on mutex unlock")
+		}
+	}()
+
+	tournaments, err := models.TournamentRepo.FindDue(ctx, db, time.Now().Unix())
+	if err != nil {
+		logger.Errorf("TournamentLogic.schedule error:%s", err.Error())
+		return
+	}
+
+	GameDataLogic.RLock()
+	defer GameDataLogic.RUnlock()
+
+	for _, tournament := range tournaments {
+		if err := s.advance(ctx, db, rb, tournament); err != nil {
+			logger.Errorf("TournamentLogic.schedule id=%d error:%s", tournament.ID, err.Error())
+		}
+	}
+}
+
+// 推进一场锦标赛
+func (s *tournamentLogic) advance(ctx context.Context, db *gorm.DB, rb *redisbackend.RedisBackend, tournament *models.Tournament) error {
+	tournamentConfig := s.tables.TournamentTb.Get(tournament.TournamentId)
+
+	// 报名截止，先修改状态，之后不能再报名
+	if tournament.State == models.TournamentStateRegister {
+		err := db.Transaction(func(db *gorm.DB) error {
+			t, err := models.TournamentRepo.GetForUpdate(ctx, db, tournament.ID)
+			if err != nil {
+				return err
+			}
+			if t.State != models.TournamentStateRegister {
+				return nil
+			}
+			return models.TournamentRepo.Updates(ctx, db, tournament.ID, map[string]interface{}{"state": models.TournamentStateRunning})
+		})
+		if err != nil {
+			return err
+		}
+		tournament.State = models.TournamentStateRunning
+	}
+
+	entries, err := models.TournamentEntryRepo.FindByTournamentId(ctx, db, tournament.ID)
+	if err != nil {
+		return err
+	}
+
+	// 还没有开始第一轮
+	if tournament.Stage == 0 {
+		if tournamentConfig == nil || len(entries) < 2 || len(entries) < int(tournamentConfig.MinPlayer) {
+			return s.cancel(ctx, db, tournament, tournamentConfig, entries)
+		}
+		return s.startStage(ctx, db, rb, tournament, tournamentConfig, entries, entries, 1)
+	}
+
+	// 当前轮次的房间
+	desks := make(map[string][]*models.TournamentEntry)
+	for _, entry := range entries {
+		if entry.Stage == tournament.Stage && entry.Out == 0 {
+			desks[entry.DeskId] = append(desks[entry.DeskId], entry)
+		}
+	}
+
+	// 所有房间都结束才能晋级
+	results := make(map[uint64]*models.BattlePlayerData)
+	rounds := make(map[uint64]int32)
+	for deskId, list := range desks {
+		battleResult, err := models.BattleResultRepo.FindOneByDeskId(ctx, db, deskId)
+		if err == nil {
+			for _, entry := range list {
+				if playerData, ok := battleResult.PlayerData[entry.RoleID]; ok {
+					results[entry.RoleID] = playerData
+					rounds[entry.RoleID] = BattleLogic.survivedRounds(&models.BattleData{Result: battleResult.Result}, playerData)
+				}
+			}
+			continue
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		// 房间数据丢失则房间内的玩家都没有成绩
+		n, err := rb.Client().Exists(ctx, fmt.Sprintf(BattleDataKey, deskId)).Result()
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			return nil
+		}
+	}
+
+	// 每个房间胜利的玩家晋级，至少淘汰一个玩家，保证每轮人数减少
+	advanced := make([]*models.TournamentEntry, 0)
+	err = db.Transaction(func(db *gorm.DB) error {
+		for _, list := range desks {
+			for _, entry := range list {
+				entry.Rounds = rounds[entry.RoleID]
+			}
+			s.sortDeskEntries(list, results)
+
+			maxAdvance := len(list) - 1
+			if maxAdvance < 1 {
+				maxAdvance = 1
+			}
+			num := 0
+			for _, entry := range list {
+				if playerData, ok := results[entry.RoleID]; ok && playerData.Win == 1 && num < maxAdvance {
+					advanced = append(advanced, entry)
+					num++
+				} else {
+					entry.Out = 1
+				}
+				if err := models.TournamentEntryRepo.Updates(ctx, db, entry.ID, map[string]interface{}{"rounds": entry.Rounds, "out": entry.Out}); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// 只有一个房间则是决赛
+	if tournamentConfig != nil && len(desks) > 1 && len(advanced) > 1 && tournament.Stage < tournamentMaxStage {
+		return s.startStage(ctx, db, rb, tournament, tournamentConfig, entries, advanced, tournament.Stage+1)
+	}
+	return s.finish(ctx, db, tournament, entries)
+}
+
+// 房间内的玩家按成绩排序，胜利的在前，其次存活回合多的在前，成绩相同先报名的在前
+func (s *tournamentLogic) sortDeskEntries(list []*models.TournamentEntry, results map[uint64]*models.BattlePlayerData) {
+	win := func(entry *models.TournamentEntry) byte {
+		if playerData, ok := results[entry.RoleID]; ok {
+			return playerData.Win
+		}
+		return 0
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		if wi, wj := win(list[i]), win(list[j]); wi != wj {
+			return wi > wj
+		}
+		if list[i].Rounds != list[j].Rounds {
+			return list[i].Rounds > list[j].Rounds
+		}
+		return list[i].ID < list[j].ID
+	})
+}
+
+// 开始一轮比赛，把玩家随机分到房间中，房间直接开始战斗
+// 已经在其他房间或者没有参赛宠物的玩家弃权，可以参赛的玩家不足两人时比赛结束
+func (s *tournamentLogic) startStage(ctx context.Context, db *gorm.DB, rb *redisbackend.RedisBackend, tournament *models.Tournament, tournamentConfig *cfg.ITournament, all []*models.TournamentEntry, entries []*models.TournamentEntry, stage int32) error {
+	logger := contextx.FromLogger(ctx)
+	battleConfig := s.tables.BattleConfigTb.Get(tournamentConfig.BattleId)
+
+	list := s.shuffleEntries(entries)
+
+	// 锁定玩家并占用玩家的房间，之后玩家不能加入其他房间
+	prefix := fmt.Sprintf("%d-t%d-", tournamentConfig.BattleId, tournament.ID)
+	var players []*models.TournamentEntry
+	var deskIds []string
+	names := make(map[uint64]string)
+	err := db.Transaction(func(db *gorm.DB) error {
+		players = make([]*models.TournamentEntry, 0, len(list))
+		for _, entry := range list {
+			ok, name, err := s.checkEntry(ctx, db, entry, prefix)
+			if err != nil {
+				return err
+			}
+			if ok {
+				players = append(players, entry)
+				names[entry.RoleID] = name
+				continue
+			}
+
+			entry.Stage = stage
+			entry.DeskId = ""
+			entry.Rounds = 0
+			entry.Out = 1
+			if err := models.TournamentEntryRepo.Updates(ctx, db, entry.ID, map[string]interface{}{"stage": stage, "deskId": "", "rounds": 0, "out": 1}); err != nil {
+				return err
+			}
+		}
+		if len(players) < 2 {
+			// 剩下的玩家直接获胜
+			for _, entry := range players {
+				entry.Stage = stage
+				entry.DeskId = ""
+				entry.Rounds = 0
+				if err := models.TournamentEntryRepo.Updates(ctx, db, entry.ID, map[string]interface{}{"stage": stage, "deskId": "", "rounds": 0}); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		// 房间数尽量少，人数平均分配
+		deskNum := (len(players) + int(battleConfig.PlayerNum) - 1) / int(battleConfig.PlayerNum)
+		deskIds = make([]string, deskNum)
+		for i := range deskIds {
+			deskIds[i] = fmt.Sprintf("%s%d-%d", prefix, stage, i+1)
+		}
+		for i, entry := range players {
+			if err := models.RoleRepo.UpdateColum(ctx, db, entry.RoleID, "lastDeskId", deskIds[i%len(deskIds)]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(players) < 2 {
+		logger.Infof("TournamentLogic.startStage id=%d stage=%d players=%d not enough", tournament.ID, stage, len(players))
+		// 第一轮人数不足则取消比赛
+		if stage == 1 {
+			return s.cancel(ctx, db, tournament, tournamentConfig, all)
+		}
+		return s.finish(ctx, db, tournament, all)
+	}
+
+	deskNum := len(deskIds)
+	for i, deskId := range deskIds {
+		if err := s.createDesk(ctx, rb, tournament, battleConfig.Id, deskId, players, names, i, deskNum); err != nil {
+			return err
+		}
+	}
+
+	err = db.Transaction(func(db *gorm.DB) error {
+		for i, entry := range players {
+			if err := models.TournamentEntryRepo.Updates(ctx, db, entry.ID, map[string]interface{}{"stage": stage, "deskId": deskIds[i%deskNum], "rounds": 0}); err != nil {
+				return err
+			}
+		}
+		return models.TournamentRepo.Updates(ctx, db, tournament.ID, map[string]interface{}{"stage": stage, "state": models.TournamentStateRunning})
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.Infof("TournamentLogic.startStage id=%d stage=%d players=%d desks=%d", tournament.ID, stage, len(players), deskNum)
+	return nil
+}
+
+// 玩家是否可以参加这一轮，不能在本场锦标赛以外的房间中，并且还拥有报名的宠物，同时返回玩家显示的名字
+func (s *tournamentLogic) checkEntry(ctx context.Context, db *gorm.DB, entry *models.TournamentEntry, prefix string) (bool, string, error) {
+	role, err := models.RoleRepo.GetForUpdate(ctx, db, entry.RoleID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, "", nil
+		}
+		return false, "", err
+	}
+	if role.LastDeskId != "" && !strings.HasPrefix(role.LastDeskId, prefix) {
+		return false, "", nil
+	}
+
+	pet, err := models.PetRepo.Get(ctx, db, entry.RoleID, entry.PetID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, "", err
+	}
+	if pet.PetNum <= 0 {
+		return false, "", nil
+	}
+
+	name, err := BattleLogic.getRoleName(ctx, db, role)
+	if err != nil {
+		return false, "", err
+	}
+	return true, name, nil
+}
+
+// 创建一个比赛房间，第index个房间的玩家为list中下标对deskNum取余等于index的玩家
+func (s *tournamentLogic) createDesk(ctx context.Context, rb *redisbackend.RedisBackend, tournament *models.Tournament, battleId int32, deskId string, list []*models.TournamentEntry, names map[uint64]string, index int, deskNum int) error {
+	logger := contextx.FromLogger(ctx)
+
+	// 分布式锁，房间设置完成前战斗调度器不能处理
+	m := rb.NewMutex(deskId)
+	if err := m.Lock(ctx); err != nil {
+		return errors.NewResponseError(constant.ServerBusy, err)
+	}
+
+	defer func() {
+		if _, err := m.Unlock(context.Background()); err != nil {
+			logger.WithError(err).Error("error on mutex unlock")
+		}
+	}()
+
+	battleData, err := BattleLogic.createBattleData(ctx, rb, battleId, deskId)
+	if err != nil {
+		return err
+	}
+	battleData.TournamentId = tournament.ID
+
+	for i := index; i < len(list); i += deskNum {
+		BattleLogic.dealJoin(false, list[i].RoleID, names[list[i].RoleID], battleData, list[i].PetID)
+	}
+	battleData.StartAt = time.Now().Unix()
+
+	if err := BattleLogic.saveBattleData(ctx, rb, battleData); err != nil {
+		return err
+	}
+	BattlePushLogic.Publish(ctx, rb, deskId, BattleEventMatchState, BattleEventRoundResult)
+	return nil
+}
+
+// 比赛结束，按到达的轮次、是否晋级和存活回合数排名，成绩相同名次相同
+func (s *tournamentLogic) finish(ctx context.Context, db *gorm.DB, tournament *models.Tournament, entries []*models.TournamentEntry) error {
+	better := func(a, b *models.TournamentEntry) bool {
+		if a.Stage != b.Stage {
+			return a.Stage > b.Stage
+		}
+		if a.Out != b.Out {
+			return a.Out < b.Out
+		}
+		return a.Rounds > b.Rounds
+	}
+
+	return db.Transaction(func(db *gorm.DB) error {
+		for _, entry := range entries {
+			var rank int32 = 1
+			for _, other := range entries {
+				if better(other, entry) {
+					rank++
+				}
+			}
+			if err := models.TournamentEntryRepo.Updates(ctx, db, entry.ID, map[string]interface{}{"ranking": rank}); err != nil {
+				return err
+			}
+		}
+		return models.TournamentRepo.Updates(ctx, db, tournament.ID, map[string]interface{}{"state": models.TournamentStateFinished})
+	})
+}
+
+// 人数不足取消比赛，退还报名消耗
+func (s *tournamentLogic) cancel(ctx context.Context, db *gorm.DB, tournament *models.Tournament, tournamentConfig *cfg.ITournament, entries []*models.TournamentEntry) error {
+	return db.Transaction(func(db *gorm.DB) error {
+		if tournamentConfig != nil {
+			for _, entry := range entries {
+				for _, need := range tournamentConfig.Need {
+					if need.Num <= 0 {
+						continue
+					}
+					if _, err := UtilsLogic.AddItem(ctx, db, entry.RoleID, need.Id, need.Num, need.Type, constant.SourceTournamentRefund); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		return models.TournamentRepo.Updates(ctx, db, tournament.ID, map[string]interface{}{"state": models.TournamentStateCanceled})
+	})
+}
+
+func (s *tournamentLogic) toTournamentData(tournament *models.Tournament) *schema.TournamentData {
+	data := new(schema.TournamentData)
+	utils.Copy(data, tournament)
+	return data
+}
@@ -0,0 +1,38 @@
+package logic
+
+import (
+	"eggServer/internal/models"
+	"math/rand"
+	"testing"
+)
+
+func TestShuffleEntries(t *testing.T) {
+	entries := make([]*models.TournamentEntry, 0)
+	for i := 1; i <= 16; i++ {
+		entries = append(entries, &models.TournamentEntry{ID: uint64(i)})
+	}
+
+	// 相同的随机数源得到相同的分组
+	s1 := &tournamentLogic{source: rand.New(rand.NewSource(42))}
+	s2 := &tournamentLogic{source: rand.New(rand.NewSource(42))}
+	list1 := s1.shuffleEntries(entries)
+	list2 := s2.shuffleEntries(entries)
+
+	seen := make(map[uint64]bool)
+	for i := range list1 {
+		if list1[i].ID != list2[i].ID {
+			t.Fatalf("index %d: %d != %d", i, list1[i].ID, list2[i].ID)
+		}
+		seen[list1[i].ID] = true
+	}
+	if len(list1) != len(entries) || len(seen) != len(entries) {
+		t.Fatalf("shuffle lost entries: %d unique of %d", len(seen), len(list1))
+	}
+
+	// 不修改传入的列表
+	for i, entry := range entries {
+		if entry.ID != uint64(i+1) {
+			t.Fatalf("entries modified at %d", i)
+		}
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"eggServer/internal/models"
 	"eggServer/internal/schema"
 	"eggServer/pkg/errors"
+	"eggServer/pkg/telegram"
 	"eggServer/pkg/utils"
 	"fmt"
 	"github.com/golang-jwt/jwt/v5"
@@ -35,8 +36,26 @@ func (s *userLogic) Init(tables *cfg.Tables) {
 func (s *userLogic) Login(ctx context.Context, db *gorm.DB, req *schema.LoginReq) (string, error) {
 	logger := contextx.FromLogger(ctx)
 	rb := contextx.FromRB(ctx)
+
+	// 校验 initData 签名，用户信息只取自签名数据
+	initData, err := telegram.Validate(req.InitData, config.C.Telegram.BotToken, config.C.Telegram.AuthExpired*time.Second)
+	if err != nil {
+		logger.Errorf("UserLogic.Login error:%s", err.Error())
+		if errors.Is(err, telegram.ErrExpired) {
+			return "", errors.NewResponseError(constant.InitDataExpired, err)
+		}
+		return "", errors.NewResponseError(constant.InitDataInvalid, err)
+	}
+
+	if initData.User == nil || initData.User.ID == 0 {
+		return "", errors.NewResponseError(constant.InitDataInvalid, nil)
+	}
+
+	userUid := cast.ToString(initData.User.ID)
+	startParam := initData.StartParam
+
 	// 分布式锁
-	mutex := rb.NewMutex(req.UserUid)
+	mutex := rb.NewMutex(userUid)
 	if err := mutex.Lock(ctx); err != nil {
 		logger.Errorf("UserLogic.Login error:%s", err.Error())
 		return "", errors.NewResponseError(constant.ServerBusy, err)
@@ -48,20 +67,14 @@ func (s *userLogic) Login(ctx context.Context, db *gorm.DB, req *schema.LoginReq
 		}
 	}()
 
-	userUid := req.UserUid
-
-	if userUid == "" {
-		return "", errors.NewResponseError(constant.ParametersInvalid, nil)
-	}
-
 	var inviterUserUid string
 	var p, m, d, shopType, shopIdx int
 
 	// 携带启动参数
-	if req.StartParam != "" {
-		num, err := utils.Decrypt(req.StartParam)
+	if startParam != "" {
+		num, err := utils.Decrypt(startParam)
 		if err != nil {
-			logger.Errorf("UserLogic.Login startParam:%s error: %s", req.StartParam, err.Error())
+			logger.Errorf("UserLogic.Login startParam:%s error: %s", startParam, err.Error())
 		} else {
 			startParam := cast.ToString(num)
 			strLen := len(startParam)
@@ -86,11 +99,11 @@ func (s *userLogic) Login(ctx context.Context, db *gorm.DB, req *schema.LoginReq
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			user.UserUid = userUid
-			user.FirstName = req.FirstName
-			user.LastName = req.LastName
-			user.PhotoUrl = req.PhotoUrl
-			user.UserName = req.UserName
-			user.LanguageCode = req.LanguageCode
+			user.FirstName = initData.User.FirstName
+			user.LastName = initData.User.LastName
+			user.PhotoUrl = initData.User.PhotoUrl
+			user.UserName = initData.User.UserName
+			user.LanguageCode = initData.User.LanguageCode
 			user.OS = req.OS
 			user.Platform = p
 			if err := models.UserRepo.Create(ctx, db, user); err != nil {
@@ -103,11 +116,11 @@ func (s *userLogic) Login(ctx context.Context, db *gorm.DB, req *schema.LoginReq
 		}
 	} else {
 		if err := models.UserRepo.Updates(ctx, db, userUid, map[string]interface{}{
-			"userName":     req.UserName,
-			"firstName":    req.FirstName,
-			"lastName":     req.LastName,
-			"photoUrl":     req.PhotoUrl,
-			"languageCode": req.LanguageCode,
+			"userName":     initData.User.UserName,
+			"firstName":    initData.User.FirstName,
+			"lastName":     initData.User.LastName,
+			"photoUrl":     initData.User.PhotoUrl,
+			"languageCode": initData.User.LanguageCode,
 			"os":           req.OS,
 		}); err != nil {
 			logger.Errorf("UserLogic.Login error: %s", err.Error())
@@ -167,7 +180,7 @@ func (s *userLogic) checkInvite(ctx context.Context, db *gorm.DB, inviterId uint
 
 				// 被邀请的人获得奖励
 				globalConfig := s.tables.GlobalTb.GetDataList()[0]
-				_, err := UtilsLogic.AddItem(ctx, db, invitedId, globalConfig.InvitedReward.Id, globalConfig.InvitedReward.Num, globalConfig.InvitedReward.Type)
+				_, err := UtilsLogic.AddItem(ctx, db, invitedId, globalConfig.InvitedReward.Id, globalConfig.InvitedReward.Num, globalConfig.InvitedReward.Type, constant.SourceInvite)
 				if err != nil {
 					return err
 				}
@@ -218,7 +231,7 @@ func (s *userLogic) checkShare(ctx context.Context, db *gorm.DB, inviterId uint6
 
 				// 被邀请的人获得奖励
 				globalConfig := s.tables.GlobalTb.GetDataList()[0]
-				_, err := UtilsLogic.AddItem(ctx, db, invitedId, globalConfig.InvitedReward.Id, globalConfig.InvitedReward.Num, globalConfig.InvitedReward.Type)
+				_, err := UtilsLogic.AddItem(ctx, db, invitedId, globalConfig.InvitedReward.Id, globalConfig.InvitedReward.Num, globalConfig.InvitedReward.Type, constant.SourceInvite)
 				if err != nil {
 					return err
 				}
@@ -331,6 +344,11 @@ func (s *userLogic) Enter(ctx context.Context, db *gorm.DB, userId uint64) (*sch
 		return nil, err
 	}
 
+	petInstances, err := models.PetInstanceRepo.FindAllByRoleId(ctx, db, role.ID)
+	if err != nil {
+		return nil, err
+	}
+
 	eggs, err := models.EggRepo.FindAllByRoleId(ctx, db, role.ID)
 	if err != nil {
 		return nil, err
@@ -356,15 +374,22 @@ func (s *userLogic) Enter(ctx context.Context, db *gorm.DB, userId uint64) (*sch
 		return nil, err
 	}
 
+	eggPityDataResp, err := EggLogic.PityData(ctx, db, role.ID)
+	if err != nil {
+		return nil, err
+	}
+
 	// 填充响应数据
 	utils.Copy(&resp.Items, items)
 	utils.Copy(&resp.Pets, pets)
+	utils.Copy(&resp.PetInstances, petInstances)
 	utils.Copy(&resp.Eggs, eggs)
 
 	resp.Task = taskDataResp
 	resp.Shop = shopDataResp
 	resp.Sign = signDataResp
 	resp.PassPortReward = passPortRewardDataResp
+	resp.EggPity = eggPityDataResp
 
 	guideDataResp, err := GuideLogic.GuideData(ctx, db, role.ID)
 	if err != nil {
@@ -383,21 +408,21 @@ func (s *userLogic) initAddValue(ctx context.Context, db *gorm.DB, roleId uint64
 	initGold := globalConfig.InitGold
 	initDiamond := globalConfig.InitDiamond
 	if initVit >= 0 {
-		_, err := ItemLogic.AddItem(ctx, db, roleId, constant.VIT, initVit)
+		_, err := ItemLogic.AddItem(ctx, db, roleId, constant.VIT, initVit, constant.SourceCreateRole)
 		if err != nil {
 			logger.Errorf("UserLogic.initAddValue error: %s", err.Error())
 		}
 	}
 
 	if initGold >= 0 {
-		_, err := ItemLogic.AddItem(ctx, db, roleId, constant.Gold, initGold)
+		_, err := ItemLogic.AddItem(ctx, db, roleId, constant.Gold, initGold, constant.SourceCreateRole)
 		if err != nil {
 			logger.Errorf("UserLogic.initAddValue error: %s", err.Error())
 		}
 	}
 
 	if initDiamond >= 0 {
-		_, err := ItemLogic.AddItem(ctx, db, roleId, constant.Diamond, initDiamond)
+		_, err := ItemLogic.AddItem(ctx, db, roleId, constant.Diamond, initDiamond, constant.SourceCreateRole)
 		if err != nil {
 			logger.Errorf("UserLogic.initAddValue error: %s", err.Error())
 		}
@@ -508,7 +533,7 @@ func (s *userLogic) AutoAddVit(ctx context.Context, db *gorm.DB, roleId uint64,
 				logger.Errorf("VitLogic.AutoAddVit error: %s", err.Error())
 				return err
 			}
-			reward, err := ItemLogic.AddItem(ctx, db, roleId, constant.VIT, num)
+			reward, err := ItemLogic.AddItem(ctx, db, roleId, constant.VIT, num, constant.SourceAutoAddVit)
 			resp.Reward = reward
 			return err
 		})
@@ -562,7 +587,7 @@ func (s *userLogic) GM(ctx context.Context, db *gorm.DB, roleId uint64, cmdStr s
 	if cmd == "addItem" {
 		itemId := cast.ToInt32(strArr[1])
 		itemNum := cast.ToInt32(strArr[2])
-		reward, err := ItemLogic.AddItem(ctx, db, roleId, itemId, itemNum)
+		reward, err := ItemLogic.AddItem(ctx, db, roleId, itemId, itemNum, constant.SourceGM)
 		if err != nil {
 			return nil, err
 		}
@@ -606,6 +631,16 @@ func (s *userLogic) GM(ctx context.Context, db *gorm.DB, roleId uint64, cmdStr s
 			return nil, err
 		}
 		resp.BattleCount = battleCount
+	} else if cmd == "reloadGameData" {
+		// 修改配置表后不停服重新加载
+		if err := GameDataLogic.Reload(ctx, contextx.FromRB(ctx)); err != nil {
+			return nil, errors.NewResponseError(constant.UnknownError, err)
+		}
+	} else if cmd == "refundOrder" {
+		// 人工退款后标记订单
+		if err := OrderLogic.Refund(ctx, db, cast.ToInt64(strArr[1])); err != nil {
+			return nil, err
+		}
 	} else {
 		return nil, errors.NewResponseError(constant.ParametersInvalid, nil)
 	}
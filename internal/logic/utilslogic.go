@@ -19,11 +19,11 @@ func (s *utilsLogic) Init() {
 }
 
 // AddItem 添加物品
-func (s *utilsLogic) AddItem(ctx context.Context, db *gorm.DB, roleId uint64, itemId int32, count int32, itemType int32) (*schema.RewardData, error) {
+func (s *utilsLogic) AddItem(ctx context.Context, db *gorm.DB, roleId uint64, itemId int32, count int32, itemType int32, source int32) (*schema.RewardData, error) {
 	if itemType == cfg.RewardType_Item {
-		return ItemLogic.AddItem(ctx, db, roleId, itemId, count)
+		return ItemLogic.AddItem(ctx, db, roleId, itemId, count, source)
 	} else if itemType == cfg.RewardType_Pet {
-		return PetLogic.AddPet(ctx, db, roleId, itemId, count)
+		return PetLogic.AddPet(ctx, db, roleId, itemId, count, source)
 	}
 
 	return nil, errors.NewResponseError(constant.ParametersInvalid, nil)
@@ -18,6 +18,10 @@ func Auth() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 从请求头中获取 Authorization 字段，格式应为 "Bearer <token>"
 		tokenHeader := c.GetHeader("Authorization")
+		// WebSocket 握手无法自定义请求头，从 token 查询参数中获取
+		if tokenHeader == "" && c.IsWebsocket() && c.Query("token") != "" {
+			tokenHeader = "Bearer " + c.Query("token")
+		}
 		if tokenHeader == "" {
 			// 如果 Authorization 头部不存在，则返回 401 未授权错误，并终止请求处理
 			ginx.ResError(c, http.StatusUnauthorized, errors.NewResponseError(constant.TokenInvalid, nil))
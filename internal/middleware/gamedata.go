@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"eggServer/internal/logic"
+	"github.com/gin-gonic/gin"
+)
+
+// GameData 请求处理期间不允许替换配置表，websocket是长连接，推送时再加锁
+func GameData() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.IsWebsocket() {
+			c.Next()
+			return
+		}
+
+		logic.GameDataLogic.RLock()
+		defer logic.GameDataLogic.RUnlock()
+
+		// 处理请求
+		c.Next()
+	}
+}
@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"eggServer/internal/config"
+	"eggServer/internal/constant"
+	"eggServer/internal/contextx"
+	"eggServer/internal/ginx"
+	"eggServer/pkg/errors"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	IdempotencyKeyHeader = "Idempotency-Key"
+	IdempotencyKey       = "idempotency:%d:%s:%s"
+	idempotencyKeyMaxLen = 64
+)
+
+// 保存的第一次请求，请求体的哈希用于识别同一个键被用于不同的请求
+type idempotencyRecord struct {
+	BodyHash string             `json:"bodyHash"`
+	Response *ginx.ResponseBody `json:"response"`
+}
+
+// Idempotency 幂等中间件，相同 Idempotency-Key 的重复请求直接返回第一次成功的响应
+// 同一个键的请求体不同时拒绝请求，只用于会改变数据的接口
+func Idempotency() gin.HandlerFunc {
+	cfg := config.C.Idempotency
+	if !cfg.Enable {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	return func(c *gin.Context) {
+		idempotencyKey := c.GetHeader(IdempotencyKeyHeader)
+		if idempotencyKey == "" {
+			c.Next()
+			return
+		}
+
+		if len(idempotencyKey) > idempotencyKeyMaxLen {
+			ginx.ResError(c, http.StatusOK, errors.NewResponseError(constant.ParametersInvalid, nil))
+			c.Abort()
+			return
+		}
+
+		ctx := c.Request.Context()
+		logger := contextx.FromLogger(ctx)
+		rb := contextx.FromRB(ctx)
+
+		// 读取请求体计算哈希，再放回去给后面的处理函数使用
+		reqBody, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			ginx.ResError(c, http.StatusOK, errors.NewResponseError(constant.ParametersInvalid, err))
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(reqBody))
+		sum := sha256.Sum256(reqBody)
+		bodyHash := hex.EncodeToString(sum[:])
+
+		key := fmt.Sprintf(IdempotencyKey, contextx.FromRoleID(ctx), c.FullPath(), idempotencyKey)
+
+		// 分布式锁，并发的重复请求依次执行
+		m := rb.NewMutex(key)
+		if err := m.Lock(ctx); err != nil {
+			logger.Errorf("Idempotency error:%s", err.Error())
+			ginx.ResError(c, http.StatusOK, errors.NewResponseError(constant.ServerBusy, err))
+			c.Abort()
+			return
+		}
+
+		defer func() {
+			if _, err := m.Unlock(context.Background()); err != nil {
+				logger.WithError(err).Error("error on mutex unlock")
+			}
+		}()
+
+		// 重复请求，原样返回第一次的响应
+		val, err := rb.Client().Get(ctx, key).Bytes()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			logger.Errorf("Idempotency error:%s", err.Error())
+			ginx.ResError(c, http.StatusOK, errors.NewResponseError(constant.RDBError, err))
+			c.Abort()
+			return
+		}
+
+		if err == nil {
+			record := new(idempotencyRecord)
+			if err := json.Unmarshal(val, record); err != nil || record.Response == nil {
+				logger.Errorf("Idempotency error:%v", err)
+				ginx.ResError(c, http.StatusOK, errors.NewResponseError(constant.JsonUnmarshalError, err))
+				c.Abort()
+				return
+			}
+			// 同一个键用于不同的请求
+			if record.BodyHash != bodyHash {
+				ginx.ResError(c, http.StatusUnprocessableEntity, errors.NewResponseError(constant.IdempotencyKeyReused, nil))
+				c.Abort()
+				return
+			}
+			body := record.Response
+			c.Data(body.Status, body.ContentType, body.Data)
+			c.Abort()
+			return
+		}
+
+		// 处理请求
+		c.Next()
+
+		// 只保存成功的响应
+		body := ginx.GetResBody(c)
+		if body == nil {
+			return
+		}
+
+		val, err = json.Marshal(&idempotencyRecord{BodyHash: bodyHash, Response: body})
+		if err != nil {
+			logger.Errorf("Idempotency error:%s", err.Error())
+			return
+		}
+
+		if err := rb.Client().Set(ctx, key, val, cfg.Expired*time.Second).Err(); err != nil {
+			logger.Errorf("Idempotency error:%s", err.Error())
+		}
+	}
+}
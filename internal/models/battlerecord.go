@@ -0,0 +1,65 @@
+package models
+
+import (
+	"context"
+	"eggServer/internal/constant"
+	"eggServer/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// BattleRecord 玩家的参战记录，战斗结算时每个真实玩家写入一条
+type BattleRecord struct {
+	ID           uint64 `gorm:"column:id;primary_key;AUTO_INCREMENT"`
+	RoleID       uint64 `gorm:"column:roleId;index:idx_roleId_createdAt;NOT NULL"`
+	BattleId     int32  `gorm:"column:battleId;NOT NULL"`
+	DeskId       string `gorm:"column:deskId;NOT NULL"`
+	PetID        int32  `gorm:"column:petId;NOT NULL"`  // 参战的宠物
+	Rounds       int32  `gorm:"column:rounds;NOT NULL"` // 存活的回合数
+	Win          byte   `gorm:"column:win;NOT NULL"`
+	Bonus        int32  `gorm:"column:bonus;NOT NULL"` // 实际发放的奖金，锦标赛为0
+	TournamentId uint64 `gorm:"column:tournamentId"`   // 锦标赛
+	CreatedAt    int64  `gorm:"column:createdAt;index:idx_roleId_createdAt;NOT NULL"`
+}
+
+// BattleStats 玩家的战斗统计
+type BattleStats struct {
+	BattleCount int64 `gorm:"column:battleCount"`
+	WinCount    int64 `gorm:"column:winCount"`
+	TotalRounds int64 `gorm:"column:totalRounds"`
+	TotalBonus  int64 `gorm:"column:totalBonus"`
+}
+
+var BattleRecordRepo = new(battleRecordRepo)
+
+type battleRecordRepo struct{}
+
+func (s *battleRecordRepo) Create(ctx context.Context, db *gorm.DB, records []*BattleRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+	if err := db.Create(records).Error; err != nil {
+		return errors.NewResponseError(constant.DatabaseError, err)
+	}
+	return nil
+}
+
+func (s *battleRecordRepo) FindPageByRoleId(ctx context.Context, db *gorm.DB, roleId uint64, pageNum, pageSize int) ([]*BattleRecord, int64, error) {
+	records := make([]*BattleRecord, 0)
+	count, err := GetPages(db.Model(new(BattleRecord)).Where("roleId=?", roleId).Order("createdAt desc, id desc"), &records, pageNum, pageSize)
+	if err != nil {
+		return records, 0, errors.NewResponseError(constant.DatabaseError, err)
+	}
+	return records, count, nil
+}
+
+func (s *battleRecordRepo) StatsByRoleId(ctx context.Context, db *gorm.DB, roleId uint64) (*BattleStats, error) {
+	stats := new(BattleStats)
+	err := db.Model(new(BattleRecord)).
+		Select("COUNT(*) AS battleCount, COALESCE(SUM(win), 0) AS winCount, COALESCE(SUM(rounds), 0) AS totalRounds, COALESCE(SUM(CASE WHEN win = 1 AND COALESCE(tournamentId, 0) = 0 THEN bonus ELSE 0 END), 0) AS totalBonus").
+		Where("roleId=?", roleId).
+		Scan(stats).Error
+	if err != nil {
+		return nil, errors.NewResponseError(constant.DatabaseError, err)
+	}
+	return stats, nil
+}
@@ -8,12 +8,23 @@ import (
 )
 
 type BattlePlayerData struct {
-	PetId      int32   `json:"1,omitempty"` // 参战的宠物
-	Bet        []int32 `json:"2,omitempty"`
-	Win        byte    `json:"3,omitempty"`
-	Bonus      int32   `json:"4,omitempty"`
-	Settlement byte    `json:"5,omitempty"` // 0还不可结算 1可以结算 2已经结算
-	JoinAt     int64   `json:"6,omitempty"` // 加入房间时间
+	PetId         int32   `json:"1,omitempty"` // 参战的宠物
+	Bet           []int32 `json:"2,omitempty"`
+	Win           byte    `json:"3,omitempty"`
+	Bonus         int32   `json:"4,omitempty"`
+	Settlement    byte    `json:"5,omitempty"` // 0还不可结算 1可以结算 2已经结算
+	JoinAt        int64   `json:"6,omitempty"` // 加入房间时间
+	PetLevel      int32   `json:"7,omitempty"` // 参战宠物的等级，返还时保留
+	PetExp        int32   `json:"8,omitempty"`
+	PetStage      int32   `json:"9,omitempty"`
+	Ability       int32   `json:"10,omitempty"` // 参战宠物的能力，加入时记录
+	AbilityVal    int32   `json:"11,omitempty"`
+	Survived      int32   `json:"12,omitempty"` // 能力存活触发的回合
+	SafeGrid      []int32 `json:"13,omitempty"` // 每回合看到的安全格子
+	Strategy      int32   `json:"14,omitempty"` // 机器人的策略
+	PetInstanceId uint64  `json:"15,omitempty"` // 加入时消耗的宠物，开始前离开房间时按原来的id返还
+	Name          string  `json:"16,omitempty"` // 显示的名字，机器人使用机器人配置的名字
+	IsRobot       byte    `json:"17,omitempty"` // 是否是机器人
 }
 
 type BattleData struct {
@@ -26,23 +37,35 @@ type BattleData struct {
 	Result          []int32                      // 每回合的结果
 	ExitPlayer      []uint64                     // 每轮退出的玩家数
 	PlayerData      map[uint64]*BattlePlayerData // 所有玩家数据
-	RobotRoleId     uint64                       // 机器人roleId自增
 	Settlement      byte                         // 0未结算 1已结算
 	State           byte                         // 0未使用 1已使用 2可以销毁
 	Bonus           map[int]int32                // 每回合的奖励
+	ServerSeed      string                       // 服务器种子，结算前不公开
+	SeedHash        string                       // 服务器种子的哈希，开局时公开
+	Creator         uint64                       // 私人房间的创建者，0为公开房间
+	InviteCode      string                       // 私人房间的邀请码
+	PlayerNum       int                          // 私人房间的人数上限
+	AddRobot        byte                         // 私人房间是否用机器人补满
+	TournamentId    uint64                       // 锦标赛的比赛房间，0为普通房间
+	SettleAt        int64                        // 战斗结算的时间
 }
 
 type BattleResult struct {
-	ID         uint64                       `gorm:"column:id;primary_key;AUTO_INCREMENT"`
-	CreateAt   int64                        `gorm:"column:createAt;NOT NULL"`
-	StartAt    int64                        `gorm:"column:startAt;NOT NULL"`
-	BattleId   int32                        `gorm:"column:battleId;NOT NULL"`
-	DeskId     string                       `gorm:"column:deskId;NOT NULL"`
-	Players    []uint64                     `gorm:"column:players;type:TEXT;serializer:json"`    // 剩余的玩家
-	Result     []int32                      `gorm:"column:result;serializer:json"`               // 每回合的结果
-	ExitPlayer []uint64                     `gorm:"column:exitPlayer;type:TEXT;serializer:json"` // 每轮退出的玩家数
-	PlayerData map[uint64]*BattlePlayerData `gorm:"column:playerData;type:TEXT;serializer:json"` // 所有玩家数据
-	Bonus      map[int]int32                `gorm:"column:bonus;serializer:json"`                // 每回合的奖励
+	ID           uint64                       `gorm:"column:id;primary_key;AUTO_INCREMENT"`
+	CreateAt     int64                        `gorm:"column:createAt;NOT NULL"`
+	StartAt      int64                        `gorm:"column:startAt;NOT NULL"`
+	BattleId     int32                        `gorm:"column:battleId;NOT NULL"`
+	DeskId       string                       `gorm:"column:deskId;index:idx_deskId;NOT NULL"`
+	Players      []uint64                     `gorm:"column:players;type:TEXT;serializer:json"`    // 剩余的玩家
+	Result       []int32                      `gorm:"column:result;serializer:json"`               // 每回合的结果
+	ExitPlayer   []uint64                     `gorm:"column:exitPlayer;type:TEXT;serializer:json"` // 每轮退出的玩家数
+	PlayerData   map[uint64]*BattlePlayerData `gorm:"column:playerData;type:TEXT;serializer:json"` // 所有玩家数据
+	Bonus        map[int]int32                `gorm:"column:bonus;serializer:json"`                // 每回合的奖励
+	ServerSeed   string                       `gorm:"column:serverSeed"`                           // 服务器种子
+	SeedHash     string                       `gorm:"column:seedHash"`                             // 服务器种子的哈希
+	Creator      uint64                       `gorm:"column:creator"`                              // 私人房间的创建者
+	InviteCode   string                       `gorm:"column:inviteCode"`                           // 私人房间的邀请码
+	TournamentId uint64                       `gorm:"column:tournamentId"`                         // 锦标赛
 }
 
 var BattleResultRepo = new(battleResultRepo)
@@ -55,3 +78,12 @@ func (s *battleResultRepo) Save(ctx context.Context, db *gorm.DB, battleResult *
 	}
 	return nil
 }
+
+func (s *battleResultRepo) FindOneByDeskId(ctx context.Context, db *gorm.DB, deskId string) (*BattleResult, error) {
+	battleResult := new(BattleResult)
+	err := db.Where("`deskId` = ?", deskId).Last(battleResult).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return battleResult, errors.NewResponseError(constant.DatabaseError, err)
+	}
+	return battleResult, err
+}
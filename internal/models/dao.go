@@ -1,10 +1,23 @@
 package models
 
 import (
+	"eggServer/pkg/errors"
+	"fmt"
 	"gorm.io/gorm"
 )
 
+// ErrNumNotEnough 数量不足
+var ErrNumNotEnough = errors.New("num not enough")
+
 func AutoMigrate(db *gorm.DB) error {
+	// 建立唯一索引前合并重复的数据
+	if err := mergeDuplicate(db, new(Item), "g_item", "itemId", "itemNum", "idx_roleId_itemId"); err != nil {
+		return err
+	}
+	if err := mergeDuplicate(db, new(Pet), "g_pet", "petId", "petNum", "idx_roleId_petId"); err != nil {
+		return err
+	}
+
 	err := db.AutoMigrate(
 		new(User),
 		new(Role),
@@ -19,6 +32,13 @@ func AutoMigrate(db *gorm.DB) error {
 		new(BattleResult),
 		new(Sign),
 		new(PassPort),
+		new(Ledger),
+		new(EggPity),
+		new(PetInstance),
+		new(Tournament),
+		new(TournamentEntry),
+		new(BattleRecord),
+		new(OrderPayment),
 	)
 	// 设置自增起始值
 	err = db.Exec("ALTER TABLE g_role AUTO_INCREMENT = 10001;").Error
@@ -30,9 +50,36 @@ func AutoMigrate(db *gorm.DB) error {
 	if err != nil {
 		panic("failed to set AUTO_INCREMENT")
 	}
+
+	// 可叠加的宠物拆分为单独的宠物
+	if err := migratePetInstance(db); err != nil {
+		return err
+	}
 	return err
 }
 
+// 合并同一个角色重复的道具或宠物，并删除旧的roleId索引
+func mergeDuplicate(db *gorm.DB, model interface{}, table string, idColumn string, numColumn string, uniqueIndex string) error {
+	migrator := db.Migrator()
+	if !migrator.HasTable(model) || migrator.HasIndex(model, uniqueIndex) {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		// 数量合并到id最小的一行
+		if err := tx.Exec(fmt.Sprintf("UPDATE %[1]s a JOIN (SELECT MIN(id) AS id, SUM(%[3]s) AS num FROM %[1]s GROUP BY roleId, %[2]s HAVING COUNT(*) > 1) b ON a.id = b.id SET a.%[3]s = b.num", table, idColumn, numColumn)).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec(fmt.Sprintf("DELETE a FROM %[1]s a JOIN %[1]s b ON a.roleId = b.roleId AND a.%[2]s = b.%[2]s AND a.id > b.id", table, idColumn)).Error; err != nil {
+			return err
+		}
+		if migrator.HasIndex(model, "idx_roleId") {
+			return tx.Migrator().DropIndex(model, "idx_roleId")
+		}
+		return nil
+	})
+}
+
 // GetPages 分页返回数据
 func GetPages(db *gorm.DB, out interface{}, pageNum, pageSize int) (int64, error) {
 	var count int64
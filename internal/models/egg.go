@@ -5,6 +5,7 @@ import (
 	"eggServer/internal/constant"
 	"eggServer/pkg/errors"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type Egg struct {
@@ -55,3 +56,41 @@ func (s *eggRepo) UpdateColum(ctx context.Context, db *gorm.DB, id uint64, colum
 	}
 	return nil
 }
+
+// FindByIdsForUpdate 在事务中读取并锁定指定的蛋
+func (s *eggRepo) FindByIdsForUpdate(ctx context.Context, db *gorm.DB, roleId uint64, ids []uint64) ([]*Egg, error) {
+	list := make([]*Egg, 0)
+	if err := db.Clauses(clause.Locking{Strength: "UPDATE"}).Where("roleId=? and id in ? and isDel=0", roleId, ids).Order("id").Find(&list).Error; err != nil {
+		return nil, errors.NewResponseError(constant.DatabaseError, err)
+	}
+	return list, nil
+}
+
+// FindAllByRoleIdForUpdate 在事务中读取并锁定玩家所有的蛋
+func (s *eggRepo) FindAllByRoleIdForUpdate(ctx context.Context, db *gorm.DB, roleId uint64) ([]*Egg, error) {
+	list := make([]*Egg, 0)
+	if err := db.Clauses(clause.Locking{Strength: "UPDATE"}).Where("roleId=? and isDel=0", roleId).Order("id").Find(&list).Error; err != nil {
+		return nil, errors.NewResponseError(constant.DatabaseError, err)
+	}
+	return list, nil
+}
+
+func (s *eggRepo) DeleteByIds(ctx context.Context, db *gorm.DB, ids []uint64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := db.Where("`id` in ?", ids).Delete(new(Egg)).Error; err != nil {
+		return errors.NewResponseError(constant.DatabaseError, err)
+	}
+	return nil
+}
+
+func (s *eggRepo) UpdateColumByIds(ctx context.Context, db *gorm.DB, ids []uint64, column string, value interface{}) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := db.Model(new(Egg)).Where("`id` in ?", ids).Update(column, value).Error; err != nil {
+		return errors.NewResponseError(constant.DatabaseError, err)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"context"
+	"eggServer/internal/constant"
+	"eggServer/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// EggPity 开蛋保底计数
+type EggPity struct {
+	ID     uint64          `gorm:"column:id;primary_key;AUTO_INCREMENT"`
+	RoleID uint64          `gorm:"column:roleId;uniqueIndex:idx_roleId;NOT NULL"`
+	Count  map[int32]int32 `gorm:"column:count;serializer:json"` // 品质 -> 连续没有开出该品质及以上的次数
+}
+
+var EggPityRepo = new(eggPityRepo)
+
+type eggPityRepo struct{}
+
+func (s *eggPityRepo) Save(ctx context.Context, db *gorm.DB, roleId uint64, pity *EggPity) error {
+	pity.RoleID = roleId
+	if err := db.Save(pity).Error; err != nil {
+		return errors.NewResponseError(constant.DatabaseError, err)
+	}
+	return nil
+}
+
+func (s *eggPityRepo) Get(ctx context.Context, db *gorm.DB, roleId uint64) (*EggPity, error) {
+	pity := new(EggPity)
+	err := db.Where("roleId=?", roleId).First(pity).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.NewResponseError(constant.DatabaseError, err)
+	}
+	return pity, err
+}
+
+// GetForUpdate 在事务中读取并锁定保底计数
+func (s *eggPityRepo) GetForUpdate(ctx context.Context, db *gorm.DB, roleId uint64) (*EggPity, error) {
+	pity := new(EggPity)
+	err := db.Clauses(clause.Locking{Strength: "UPDATE"}).Where("roleId=?", roleId).First(pity).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.NewResponseError(constant.DatabaseError, err)
+	}
+	return pity, err
+}
@@ -5,13 +5,14 @@ import (
 	"eggServer/internal/constant"
 	"eggServer/pkg/errors"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type Item struct {
 	ID      uint64 `gorm:"column:id;primary_key;AUTO_INCREMENT"`
-	RoleID  uint64 `gorm:"column:roleId;index:idx_roleId;NOT NULL"`
-	ItemID  int32  `gorm:"column:itemId;NOT NULL"`
-	ItemNum int32  `gorm:"column:itemNum;"`
+	RoleID  uint64 `gorm:"column:roleId;uniqueIndex:idx_roleId_itemId;NOT NULL"`
+	ItemID  int32  `gorm:"column:itemId;uniqueIndex:idx_roleId_itemId;NOT NULL"`
+	ItemNum int32  `gorm:"column:itemNum;check:chk_item_itemNum,itemNum >= 0"`
 }
 
 var ItemRepo = new(itemRepo)
@@ -33,9 +34,33 @@ func (s *itemRepo) FindAllByRoleId(ctx context.Context, db *gorm.DB, roleId uint
 	return items, err
 }
 
-func (s *itemRepo) Save(ctx context.Context, db *gorm.DB, item *Item) error {
-	if err := db.Save(item).Error; err != nil {
-		return errors.NewResponseError(constant.DatabaseError, err)
-	}
-	return nil
+// AddNum 原子增加道具数量，减少时由数据库保证数量不会小于0，返回变化后的数量
+func (s *itemRepo) AddNum(ctx context.Context, db *gorm.DB, roleId uint64, itemId int32, count int32) (int32, error) {
+	var itemNum int32
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if count >= 0 {
+			item := &Item{RoleID: roleId, ItemID: itemId, ItemNum: count}
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "roleId"}, {Name: "itemId"}},
+				DoUpdates: clause.Assignments(map[string]interface{}{"itemNum": gorm.Expr("itemNum + ?", count)}),
+			}).Create(item).Error; err != nil {
+				return errors.NewResponseError(constant.DatabaseError, err)
+			}
+		} else {
+			ret := tx.Model(new(Item)).Where("roleId=? and itemId=? and itemNum>=?", roleId, itemId, -count).Update("itemNum", gorm.Expr("itemNum + ?", count))
+			if ret.Error != nil {
+				return errors.NewResponseError(constant.DatabaseError, ret.Error)
+			}
+			if ret.RowsAffected == 0 {
+				return ErrNumNotEnough
+			}
+		}
+
+		// 本事务中已经锁定该行，读取到的就是变化后的数量
+		if err := tx.Model(new(Item)).Where("roleId=? and itemId=?", roleId, itemId).Pluck("itemNum", &itemNum).Error; err != nil {
+			return errors.NewResponseError(constant.DatabaseError, err)
+		}
+		return nil
+	})
+	return itemNum, err
 }
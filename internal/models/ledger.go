@@ -0,0 +1,40 @@
+package models
+
+import (
+	"context"
+	"eggServer/internal/constant"
+	"eggServer/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// Ledger 道具和宠物的变化记录，只追加不修改
+type Ledger struct {
+	ID        uint64 `gorm:"column:id;primary_key;AUTO_INCREMENT"`
+	RoleID    uint64 `gorm:"column:roleId;index:idx_roleId;NOT NULL"`
+	Type      int32  `gorm:"column:type;NOT NULL"` // 1宠物 2道具
+	ItemID    int32  `gorm:"column:itemId;NOT NULL"`
+	Delta     int32  `gorm:"column:delta;NOT NULL"`   // 变化数量
+	Balance   int32  `gorm:"column:balance;NOT NULL"` // 变化后的数量
+	Source    int32  `gorm:"column:source;NOT NULL"`  // 来源
+	CreatedAt int64  `gorm:"column:createdAt;NOT NULL"`
+}
+
+var LedgerRepo = new(ledgerRepo)
+
+type ledgerRepo struct{}
+
+func (s *ledgerRepo) Create(ctx context.Context, db *gorm.DB, ledger *Ledger) error {
+	if err := db.Create(ledger).Error; err != nil {
+		return errors.NewResponseError(constant.DatabaseError, err)
+	}
+	return nil
+}
+
+func (s *ledgerRepo) FindPageByRoleId(ctx context.Context, db *gorm.DB, roleId uint64, pageNum, pageSize int) ([]*Ledger, int64, error) {
+	ledgers := make([]*Ledger, 0)
+	count, err := GetPages(db.Model(new(Ledger)).Where("roleId=?", roleId).Order("id desc"), &ledgers, pageNum, pageSize)
+	if err != nil {
+		return ledgers, 0, errors.NewResponseError(constant.DatabaseError, err)
+	}
+	return ledgers, count, nil
+}
@@ -10,14 +10,17 @@ import (
 type Order struct {
 	ID          uint64 `gorm:"column:id;primary_key;AUTO_INCREMENT"`
 	OrderId     int64  `gorm:"column:orderId;index:idx_orderId;NOT NULL"`
-	RoleID      uint64 `gorm:"column:roleId;NOT NULL"`
+	RoleID      uint64 `gorm:"column:roleId;index:idx_roleId;NOT NULL"`
 	Platform    int    `gorm:"column:platform"` // 平台标识 0 官方
 	TotalAmount int64  `gorm:"column:totalAmount;NOT NULL"`
-	OrderStatus byte   `gorm:"column:orderStatus;NOT NULL"`
+	OrderStatus byte   `gorm:"column:orderStatus;index:idx_orderStatus_expireAt,priority:1;NOT NULL"` // 订单状态 constant.OrderStatusPending等
 	ShopId      int32  `gorm:"column:shopId;NOT NULL"`
 	ShopType    byte   `gorm:"column:shopType;NOT NULL"`
 	Currency    int    `gorm:"column:currency;NOT NULL"`
+	PaidAmount  int64  `gorm:"column:paidAmount"` // 实付金额
+	TxHash      string `gorm:"column:txHash"`     // 支付的交易哈希
 	CreatedAt   int64  `gorm:"column:createdAt;NOT NULL"`
+	ExpireAt    int64  `gorm:"column:expireAt;index:idx_orderStatus_expireAt,priority:2"` // 支付截止时间
 	UpdatedAt   int64  `gorm:"column:updatedAt;"`
 }
 
@@ -39,6 +42,15 @@ func (s *orderRepo) Updates(ctx context.Context, db *gorm.DB, orderId int64, val
 	return nil
 }
 
+// UpdateStatus 订单状态为status中的一个时才更新，返回是否更新成功
+func (s *orderRepo) UpdateStatus(ctx context.Context, db *gorm.DB, orderId int64, status []byte, values interface{}) (bool, error) {
+	result := db.Model(new(Order)).Where("`orderId` = ? AND `orderStatus` IN ?", orderId, status).Updates(values)
+	if result.Error != nil {
+		return false, errors.NewResponseError(constant.DatabaseError, result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
 func (s *orderRepo) Get(ctx context.Context, db *gorm.DB, orderId int64) (*Order, error) {
 	order := new(Order)
 	err := db.Model(new(Order)).Where("`orderId` = ?", orderId).First(order).Error
@@ -47,3 +59,30 @@ func (s *orderRepo) Get(ctx context.Context, db *gorm.DB, orderId int64) (*Order
 	}
 	return order, err
 }
+
+// Expire 将已经超过支付期限的待支付订单设为过期
+func (s *orderRepo) Expire(ctx context.Context, db *gorm.DB, now int64) (int64, error) {
+	result := db.Model(new(Order)).Where("`orderStatus` = ? AND `expireAt` < ?", constant.OrderStatusPending, now).
+		Updates(map[string]interface{}{"orderStatus": constant.OrderStatusExpired, "updatedAt": now})
+	if result.Error != nil {
+		return 0, errors.NewResponseError(constant.DatabaseError, result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+func (s *orderRepo) FindByStatus(ctx context.Context, db *gorm.DB, status byte, limit int) ([]*Order, error) {
+	orders := make([]*Order, 0)
+	if err := db.Where("`orderStatus` = ?", status).Order("id").Limit(limit).Find(&orders).Error; err != nil {
+		return orders, errors.NewResponseError(constant.DatabaseError, err)
+	}
+	return orders, nil
+}
+
+func (s *orderRepo) FindPageByRoleId(ctx context.Context, db *gorm.DB, roleId uint64, pageNum, pageSize int) ([]*Order, int64, error) {
+	orders := make([]*Order, 0)
+	count, err := GetPages(db.Model(new(Order)).Where("roleId=?", roleId).Order("id desc"), &orders, pageNum, pageSize)
+	if err != nil {
+		return orders, 0, errors.NewResponseError(constant.DatabaseError, err)
+	}
+	return orders, count, nil
+}
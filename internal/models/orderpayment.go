@@ -0,0 +1,47 @@
+package models
+
+import (
+	"context"
+	"eggServer/internal/constant"
+	"eggServer/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OrderPayment 没有计入订单的付款，例如订单已经发货或者少付后又收到的付款，需要人工退款
+type OrderPayment struct {
+	ID        uint64 `gorm:"column:id;primary_key;AUTO_INCREMENT"`
+	OrderId   int64  `gorm:"column:orderId;index:idx_orderId;NOT NULL"`
+	RoleID    uint64 `gorm:"column:roleId;NOT NULL"`
+	TxHash    string `gorm:"column:txHash;uniqueIndex:idx_txHash;size:128;NOT NULL"` // 同一笔交易只记录一次
+	Sender    string `gorm:"column:sender"`                                          // 付款钱包地址，退款时使用
+	Currency  int    `gorm:"column:currency;NOT NULL"`
+	Amount    int64  `gorm:"column:amount;NOT NULL"`
+	Utime     int64  `gorm:"column:utime;NOT NULL"`    // 交易时间
+	Reason    int    `gorm:"column:reason;NOT NULL"`   // 没有计入订单的原因，错误码
+	Refunded  byte   `gorm:"column:refunded;NOT NULL"` // 1已退款
+	CreatedAt int64  `gorm:"column:createdAt;NOT NULL"`
+	UpdatedAt int64  `gorm:"column:updatedAt;"`
+}
+
+var OrderPaymentRepo = new(orderPaymentRepo)
+
+type orderPaymentRepo struct{}
+
+// Create 已经记录过的交易不重复记录
+func (s *orderPaymentRepo) Create(ctx context.Context, db *gorm.DB, payment *OrderPayment) error {
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(payment).Error; err != nil {
+		return errors.NewResponseError(constant.DatabaseError, err)
+	}
+	return nil
+}
+
+// Refund 标记订单所有未退款的付款已经退款，返回标记的数量
+func (s *orderPaymentRepo) Refund(ctx context.Context, db *gorm.DB, orderId int64, now int64) (int64, error) {
+	result := db.Model(new(OrderPayment)).Where("`orderId` = ? AND `refunded` = 0", orderId).
+		Updates(map[string]interface{}{"refunded": 1, "updatedAt": now})
+	if result.Error != nil {
+		return 0, errors.NewResponseError(constant.DatabaseError, result.Error)
+	}
+	return result.RowsAffected, nil
+}
@@ -5,13 +5,14 @@ import (
 	"eggServer/internal/constant"
 	"eggServer/pkg/errors"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type Pet struct {
 	ID     uint64 `gorm:"column:id;primary_key;AUTO_INCREMENT"`
-	RoleID uint64 `gorm:"column:roleId;index:idx_roleId;NOT NULL"`
-	PetID  int32  `gorm:"column:petId;NOT NULL"`
-	PetNum int32  `gorm:"column:petNum;"`
+	RoleID uint64 `gorm:"column:roleId;uniqueIndex:idx_roleId_petId;NOT NULL"`
+	PetID  int32  `gorm:"column:petId;uniqueIndex:idx_roleId_petId;NOT NULL"`
+	PetNum int32  `gorm:"column:petNum;check:chk_pet_petNum,petNum >= 0"`
 }
 
 var PetRepo = new(petRepo)
@@ -33,9 +34,33 @@ func (s *petRepo) FindAllByRoleId(ctx context.Context, db *gorm.DB, roleId uint6
 	return pets, err
 }
 
-func (s *petRepo) Save(ctx context.Context, db *gorm.DB, pet *Pet) error {
-	if err := db.Save(pet).Error; err != nil {
-		return errors.NewResponseError(constant.DatabaseError, err)
-	}
-	return nil
+// AddNum 原子增加宠物数量，减少时由数据库保证数量不会小于0，返回变化后的数量
+func (s *petRepo) AddNum(ctx context.Context, db *gorm.DB, roleId uint64, petId int32, count int32) (int32, error) {
+	var petNum int32
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if count >= 0 {
+			pet := &Pet{RoleID: roleId, PetID: petId, PetNum: count}
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "roleId"}, {Name: "petId"}},
+				DoUpdates: clause.Assignments(map[string]interface{}{"petNum": gorm.Expr("petNum + ?", count)}),
+			}).Create(pet).Error; err != nil {
+				return errors.NewResponseError(constant.DatabaseError, err)
+			}
+		} else {
+			ret := tx.Model(new(Pet)).Where("roleId=? and petId=? and petNum>=?", roleId, petId, -count).Update("petNum", gorm.Expr("petNum + ?", count))
+			if ret.Error != nil {
+				return errors.NewResponseError(constant.DatabaseError, ret.Error)
+			}
+			if ret.RowsAffected == 0 {
+				return ErrNumNotEnough
+			}
+		}
+
+		// 本事务中已经锁定该行，读取到的就是变化后的数量
+		if err := tx.Model(new(Pet)).Where("roleId=? and petId=?", roleId, petId).Pluck("petNum", &petNum).Error; err != nil {
+			return errors.NewResponseError(constant.DatabaseError, err)
+		}
+		return nil
+	})
+	return petNum, err
 }
@@ -0,0 +1,123 @@
+package models
+
+import (
+	"context"
+	"eggServer/internal/constant"
+	"eggServer/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// PetInstance 单独的宠物，Pet.PetNum 为同一种宠物的数量
+type PetInstance struct {
+	ID        uint64 `gorm:"column:id;primary_key;AUTO_INCREMENT"`
+	RoleID    uint64 `gorm:"column:roleId;index:idx_roleId_petId;NOT NULL"`
+	PetID     int32  `gorm:"column:petId;index:idx_roleId_petId;NOT NULL"`
+	Level     int32  `gorm:"column:level;NOT NULL"`
+	Exp       int32  `gorm:"column:exp;NOT NULL"` // 当前等级的经验
+	Stage     int32  `gorm:"column:stage;NOT NULL"`
+	CreatedAt int64  `gorm:"column:createdAt;"`
+}
+
+var PetInstanceRepo = new(petInstanceRepo)
+
+type petInstanceRepo struct{}
+
+func (s *petInstanceRepo) Create(ctx context.Context, db *gorm.DB, list []*PetInstance) error {
+	if len(list) == 0 {
+		return nil
+	}
+	if err := db.CreateInBatches(list, 100).Error; err != nil {
+		return errors.NewResponseError(constant.DatabaseError, err)
+	}
+	return nil
+}
+
+// GetForUpdate 在事务中读取并锁定宠物
+func (s *petInstanceRepo) GetForUpdate(ctx context.Context, db *gorm.DB, roleId uint64, id uint64) (*PetInstance, error) {
+	pet := new(PetInstance)
+	err := db.Clauses(clause.Locking{Strength: "UPDATE"}).Where("roleId=? and id=?", roleId, id).First(pet).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return pet, errors.NewResponseError(constant.DatabaseError, err)
+	}
+	return pet, err
+}
+
+// FindByIdsForUpdate 在事务中读取并锁定指定的宠物
+func (s *petInstanceRepo) FindByIdsForUpdate(ctx context.Context, db *gorm.DB, roleId uint64, ids []uint64) ([]*PetInstance, error) {
+	list := make([]*PetInstance, 0)
+	if err := db.Clauses(clause.Locking{Strength: "UPDATE"}).Where("roleId=? and id in ?", roleId, ids).Order("id").Find(&list).Error; err != nil {
+		return nil, errors.NewResponseError(constant.DatabaseError, err)
+	}
+	return list, nil
+}
+
+func (s *petInstanceRepo) FindAllByRoleId(ctx context.Context, db *gorm.DB, roleId uint64) ([]*PetInstance, error) {
+	list := make([]*PetInstance, 0)
+	err := db.Where("roleId=?", roleId).Order("id").Find(&list).Error
+	return list, err
+}
+
+// FindLowestForUpdate 在事务中读取并锁定阶段和等级最低的宠物，消耗宠物时优先消耗这些
+func (s *petInstanceRepo) FindLowestForUpdate(ctx context.Context, db *gorm.DB, roleId uint64, petId int32, limit int) ([]*PetInstance, error) {
+	list := make([]*PetInstance, 0)
+	if err := db.Clauses(clause.Locking{Strength: "UPDATE"}).Where("roleId=? and petId=?", roleId, petId).
+		Order("stage, level, exp, id").Limit(limit).Find(&list).Error; err != nil {
+		return nil, errors.NewResponseError(constant.DatabaseError, err)
+	}
+	return list, nil
+}
+
+// FindHighestForUpdate 在事务中读取并锁定阶段和等级最高的宠物
+func (s *petInstanceRepo) FindHighestForUpdate(ctx context.Context, db *gorm.DB, roleId uint64, petId int32) (*PetInstance, error) {
+	pet := new(PetInstance)
+	err := db.Clauses(clause.Locking{Strength: "UPDATE"}).Where("roleId=? and petId=?", roleId, petId).
+		Order("stage desc, level desc, exp desc, id").First(pet).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return pet, errors.NewResponseError(constant.DatabaseError, err)
+	}
+	return pet, err
+}
+
+func (s *petInstanceRepo) Updates(ctx context.Context, db *gorm.DB, id uint64, values interface{}) error {
+	if err := db.Model(new(PetInstance)).Where("`id` = ?", id).Updates(values).Error; err != nil {
+		return errors.NewResponseError(constant.DatabaseError, err)
+	}
+	return nil
+}
+
+func (s *petInstanceRepo) DeleteByIds(ctx context.Context, db *gorm.DB, ids []uint64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := db.Where("`id` in ?", ids).Delete(new(PetInstance)).Error; err != nil {
+		return errors.NewResponseError(constant.DatabaseError, err)
+	}
+	return nil
+}
+
+// 把可叠加的宠物数量拆分为单独的宠物，只在还没有单独的宠物时执行
+func migratePetInstance(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(new(PetInstance)).Limit(1).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return nil
+		}
+
+		now := time.Now().Unix()
+		var pets []*Pet
+		return tx.Where("petNum>0").FindInBatches(&pets, 100, func(_ *gorm.DB, batch int) error {
+			list := make([]*PetInstance, 0)
+			for _, pet := range pets {
+				for i := int32(0); i < pet.PetNum; i++ {
+					list = append(list, &PetInstance{RoleID: pet.RoleID, PetID: pet.PetID, Level: 1, CreatedAt: now})
+				}
+			}
+			return tx.CreateInBatches(list, 100).Error
+		}).Error
+	})
+}
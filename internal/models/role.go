@@ -5,6 +5,7 @@ import (
 	"eggServer/internal/constant"
 	"eggServer/pkg/errors"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type Role struct {
@@ -37,6 +38,16 @@ func (s *roleRepo) Get(ctx context.Context, db *gorm.DB, roleId uint64) (*Role,
 	return role, err
 }
 
+// GetForUpdate 在事务中读取并锁定角色
+func (s *roleRepo) GetForUpdate(ctx context.Context, db *gorm.DB, roleId uint64) (*Role, error) {
+	role := new(Role)
+	err := db.Clauses(clause.Locking{Strength: "UPDATE"}).Where("`id` = ?", roleId).First(role).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return role, errors.NewResponseError(constant.DatabaseError, err)
+	}
+	return role, err
+}
+
 func (s *roleRepo) UpdateColum(ctx context.Context, db *gorm.DB, roleId uint64, column string, value interface{}) error {
 	if err := db.Model(new(Role)).Where("`id` = ?", roleId).Update(column, value).Error; err != nil {
 		return errors.NewResponseError(constant.DatabaseError, err)
@@ -44,6 +55,14 @@ func (s *roleRepo) UpdateColum(ctx context.Context, db *gorm.DB, roleId uint64,
 	return nil
 }
 
+// ClearLastDeskId 清除玩家所在的房间，已经加入其他房间的不修改
+func (s *roleRepo) ClearLastDeskId(ctx context.Context, db *gorm.DB, roleId uint64, deskId string) error {
+	if err := db.Model(new(Role)).Where("`id` = ? and `lastDeskId` = ?", roleId, deskId).Update("lastDeskId", "").Error; err != nil {
+		return errors.NewResponseError(constant.DatabaseError, err)
+	}
+	return nil
+}
+
 func (s *roleRepo) FindOneByUserId(ctx context.Context, db *gorm.DB, userId uint64) (*Role, error) {
 	role := new(Role)
 	err := db.Where("`userId` = ?", userId).First(role).Error
@@ -0,0 +1,135 @@
+package models
+
+import (
+	"context"
+	"eggServer/internal/constant"
+	"eggServer/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Tournament 一场锦标赛，同一个配置每个开始时间一场
+type Tournament struct {
+	ID           uint64 `gorm:"column:id;primary_key;AUTO_INCREMENT"`
+	TournamentId int32  `gorm:"column:tournamentId;uniqueIndex:idx_tournamentId_startAt;NOT NULL"`
+	StartAt      int64  `gorm:"column:startAt;uniqueIndex:idx_tournamentId_startAt;NOT NULL"` // 报名截止并开始比赛的时间
+	Stage        int32  `gorm:"column:stage;NOT NULL"`                                        // 当前的轮次，0还没开始
+	State        byte   `gorm:"column:state;index:idx_state;NOT NULL"`                        // 0报名中 1比赛中 2已结束 3人数不足取消
+	PlayerNum    int32  `gorm:"column:playerNum;NOT NULL"`                                    // 报名人数
+	CreatedAt    int64  `gorm:"column:createdAt;"`
+}
+
+const (
+	TournamentStateRegister = 0 // 报名中
+	TournamentStateRunning  = 1 // 比赛中
+	TournamentStateFinished = 2 // 已结束
+	TournamentStateCanceled = 3 // 人数不足取消
+)
+
+// TournamentEntry 玩家的锦标赛报名和成绩
+type TournamentEntry struct {
+	ID           uint64 `gorm:"column:id;primary_key;AUTO_INCREMENT"`
+	TournamentID uint64 `gorm:"column:tournamentId;uniqueIndex:idx_tournamentId_roleId;NOT NULL"`
+	RoleID       uint64 `gorm:"column:roleId;uniqueIndex:idx_tournamentId_roleId;NOT NULL"`
+	PetID        int32  `gorm:"column:petId;NOT NULL"`   // 参赛的宠物
+	Stage        int32  `gorm:"column:stage;NOT NULL"`   // 到达的轮次
+	DeskId       string `gorm:"column:deskId"`           // 所在轮次的房间
+	Rounds       int32  `gorm:"column:rounds;NOT NULL"`  // 所在轮次存活的回合数
+	Out          byte   `gorm:"column:out;NOT NULL"`     // 1已淘汰
+	Rank         int32  `gorm:"column:ranking;NOT NULL"` // 最终名次，比赛结束后计算
+	Claimed      byte   `gorm:"column:claimed;NOT NULL"`
+	CreatedAt    int64  `gorm:"column:createdAt;"`
+}
+
+var TournamentRepo = new(tournamentRepo)
+
+type tournamentRepo struct{}
+
+// Create 创建一场锦标赛，已经存在则忽略
+func (s *tournamentRepo) Create(ctx context.Context, db *gorm.DB, tournament *Tournament) error {
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(tournament).Error; err != nil {
+		return errors.NewResponseError(constant.DatabaseError, err)
+	}
+	return nil
+}
+
+func (s *tournamentRepo) Get(ctx context.Context, db *gorm.DB, id uint64) (*Tournament, error) {
+	tournament := new(Tournament)
+	err := db.Where("id=?", id).First(tournament).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return tournament, errors.NewResponseError(constant.DatabaseError, err)
+	}
+	return tournament, err
+}
+
+// GetByStartAtForUpdate 在事务中读取并锁定某个开始时间的锦标赛
+func (s *tournamentRepo) GetByStartAtForUpdate(ctx context.Context, db *gorm.DB, tournamentId int32, startAt int64) (*Tournament, error) {
+	tournament := new(Tournament)
+	err := db.Clauses(clause.Locking{Strength: "UPDATE"}).Where("tournamentId=? and startAt=?", tournamentId, startAt).First(tournament).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return tournament, errors.NewResponseError(constant.DatabaseError, err)
+	}
+	return tournament, err
+}
+
+// GetForUpdate 在事务中读取并锁定锦标赛
+func (s *tournamentRepo) GetForUpdate(ctx context.Context, db *gorm.DB, id uint64) (*Tournament, error) {
+	tournament := new(Tournament)
+	err := db.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id=?", id).First(tournament).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return tournament, errors.NewResponseError(constant.DatabaseError, err)
+	}
+	return tournament, err
+}
+
+// FindDue 需要调度的锦标赛，报名已经截止的和比赛中的
+func (s *tournamentRepo) FindDue(ctx context.Context, db *gorm.DB, now int64) ([]*Tournament, error) {
+	list := make([]*Tournament, 0)
+	if err := db.Where("(state=? and startAt<=?) or state=?", TournamentStateRegister, now, TournamentStateRunning).Order("id").Find(&list).Error; err != nil {
+		return nil, errors.NewResponseError(constant.DatabaseError, err)
+	}
+	return list, nil
+}
+
+func (s *tournamentRepo) Updates(ctx context.Context, db *gorm.DB, id uint64, values interface{}) error {
+	if err := db.Model(new(Tournament)).Where("`id` = ?", id).Updates(values).Error; err != nil {
+		return errors.NewResponseError(constant.DatabaseError, err)
+	}
+	return nil
+}
+
+var TournamentEntryRepo = new(tournamentEntryRepo)
+
+type tournamentEntryRepo struct{}
+
+func (s *tournamentEntryRepo) Create(ctx context.Context, db *gorm.DB, entry *TournamentEntry) error {
+	if err := db.Create(entry).Error; err != nil {
+		return errors.NewResponseError(constant.DatabaseError, err)
+	}
+	return nil
+}
+
+// GetForUpdate 在事务中读取并锁定玩家的报名
+func (s *tournamentEntryRepo) GetForUpdate(ctx context.Context, db *gorm.DB, tournamentId uint64, roleId uint64) (*TournamentEntry, error) {
+	entry := new(TournamentEntry)
+	err := db.Clauses(clause.Locking{Strength: "UPDATE"}).Where("tournamentId=? and roleId=?", tournamentId, roleId).First(entry).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return entry, errors.NewResponseError(constant.DatabaseError, err)
+	}
+	return entry, err
+}
+
+func (s *tournamentEntryRepo) FindByTournamentId(ctx context.Context, db *gorm.DB, tournamentId uint64) ([]*TournamentEntry, error) {
+	list := make([]*TournamentEntry, 0)
+	if err := db.Where("tournamentId=?", tournamentId).Order("id").Find(&list).Error; err != nil {
+		return nil, errors.NewResponseError(constant.DatabaseError, err)
+	}
+	return list, nil
+}
+
+func (s *tournamentEntryRepo) Updates(ctx context.Context, db *gorm.DB, id uint64, values interface{}) error {
+	if err := db.Model(new(TournamentEntry)).Where("`id` = ?", id).Updates(values).Error; err != nil {
+		return errors.NewResponseError(constant.DatabaseError, err)
+	}
+	return nil
+}
@@ -22,12 +22,44 @@ type BattleMatchStateReq struct {
 }
 
 type BattleMatchStateResp struct {
-	BattleId  int32  `json:"battleId" msgpack:"battleId"`
-	CreatedAt int64  `json:"createdAt" msgpack:"createdAt"`
-	StartAt   int64  `json:"startAt" msgpack:"startAt"`
-	JoinAt    int64  `json:"joinAt" msgpack:"joinAt"`
-	PlayerNum int    `json:"playerNum" msgpack:"playerNum"`
-	DeskId    string `json:"deskId" msgpack:"deskId"`
+	BattleId     int32           `json:"battleId" msgpack:"battleId"`
+	CreatedAt    int64           `json:"createdAt" msgpack:"createdAt"`
+	StartAt      int64           `json:"startAt" msgpack:"startAt"`
+	JoinAt       int64           `json:"joinAt" msgpack:"joinAt"`
+	PlayerNum    int             `json:"playerNum" msgpack:"playerNum"`
+	DeskId       string          `json:"deskId" msgpack:"deskId"`
+	SeedHash     string          `json:"seedHash" msgpack:"seedHash"`               // 服务器种子的哈希
+	MaxPlayerNum int             `json:"maxPlayerNum" msgpack:"maxPlayerNum"`       // 人数上限
+	Creator      uint64          `json:"creator,omitempty" msgpack:"creator"`       // 私人房间的创建者
+	InviteCode   string          `json:"inviteCode,omitempty" msgpack:"inviteCode"` // 私人房间的邀请码
+	Queued       byte            `json:"queued,omitempty" msgpack:"queued"`         // 1在匹配队列中还没有分配房间，createdAt为入队时间，playerNum为排队人数
+	Players      []*BattlePlayer `json:"players,omitempty" msgpack:"players"`       // 房间中的玩家
+}
+
+type BattlePlayer struct {
+	RoleId uint64 `json:"roleId" msgpack:"roleId"`
+	Name   string `json:"name" msgpack:"name"`
+	PetId  int32  `json:"petId" msgpack:"petId"`
+}
+
+type BattleMatchCancelReq struct {
+	BattleId int32 `json:"battleId" msgpack:"battleId" binding:"required"`
+}
+
+type BattleCreateReq struct {
+	BattleId  int32 `json:"battleId" msgpack:"battleId" binding:"required"`
+	PetId     int32 `json:"petId" msgpack:"petId" binding:"required"`
+	PlayerNum int   `json:"playerNum" msgpack:"playerNum"` // 人数上限，0为战斗配置的人数
+	AddRobot  byte  `json:"addRobot" msgpack:"addRobot"`   // 1用机器人补满空位
+}
+
+type BattleJoinReq struct {
+	InviteCode string `json:"inviteCode" msgpack:"inviteCode" binding:"required"`
+	PetId      int32  `json:"petId" msgpack:"petId" binding:"required"`
+}
+
+type BattleStartReq struct {
+	DeskId string `json:"deskId" msgpack:"deskId" binding:"required"`
 }
 
 type BattleLeaveReq struct {
@@ -54,9 +86,11 @@ type BattleBetReq struct {
 type BattleSyncScoreResp struct {
 	ScoreList   map[int32]int32   `json:"scoreList,omitempty" msgpack:"scoreList"`
 	PetList     map[int32][]int32 `json:"petList,omitempty" msgpack:"petList"`
+	PetAbility  map[int32]int32   `json:"petAbility,omitempty" msgpack:"petAbility"` // 宠物id -> 能力
 	PlayerBonus int32             `json:"playerBonus" msgpack:"playerBonus"`
 	BaseBonus   int32             `json:"baseBonus" msgpack:"baseBonus"`
 	Grid        int32             `json:"grid" msgpack:"grid"`
+	SafeGrid    int32             `json:"safeGrid,omitempty" msgpack:"safeGrid"` // 宠物能力看到的安全格子
 }
 
 type BattleSyncScoreReq struct {
@@ -87,3 +121,149 @@ type BattleSettlementResp struct {
 	Bonus  int32       `json:"bonus" msgpack:"bonus"`
 	Reward *RewardData `json:"reward" msgpack:"reward"`
 }
+
+type BattleWSReq struct {
+	DeskId     string `form:"deskId" json:"deskId" msgpack:"deskId" binding:"required"`
+	Spectate   byte   `form:"spectate" json:"spectate" msgpack:"spectate"`       // 1观战，只推送观战数据
+	InviteCode string `form:"inviteCode" json:"inviteCode" msgpack:"inviteCode"` // 观战私人房间需要邀请码
+}
+
+type BattlePushResp struct {
+	Event byte        `json:"event" msgpack:"event"` // 1匹配状态 2同步分数 3回合结果 4观战
+	Data  interface{} `json:"data" msgpack:"data"`
+}
+
+type BattleResumeReq struct {
+	DeskId string `json:"deskId" msgpack:"deskId"` // 为空时恢复上次加入的房间
+}
+
+type BattleResumeResp struct {
+	DeskId      string                 `json:"deskId" msgpack:"deskId"`
+	BattleId    int32                  `json:"battleId" msgpack:"battleId"`
+	ServerTime  int64                  `json:"serverTime" msgpack:"serverTime"` // 服务器时间，用于计算剩余时间
+	TotalRound  int32                  `json:"totalRound" msgpack:"totalRound"`
+	MatchState  *BattleMatchStateResp  `json:"matchState" msgpack:"matchState"`
+	RoundResult *BattleRoundResultResp `json:"roundResult" msgpack:"roundResult"`
+	RoundEndAt  int64                  `json:"roundEndAt,omitempty" msgpack:"roundEndAt"` // 当前回合结束的时间
+	Bet         []int32                `json:"bet,omitempty" msgpack:"bet"`               // 每回合下注的格子
+	SafeGrid    []int32                `json:"safeGrid,omitempty" msgpack:"safeGrid"`     // 每回合看到的安全格子
+	Alive       byte                   `json:"alive" msgpack:"alive"`                     // 1还在房间中
+	Win         byte                   `json:"win" msgpack:"win"`
+	Bonus       int32                  `json:"bonus" msgpack:"bonus"`
+	Settlement  byte                   `json:"settlement" msgpack:"settlement"` // 0还不可结算 1可以结算 2已经结算
+	Archived    byte                   `json:"archived" msgpack:"archived"`     // 1房间已经清理，数据来自保存的战斗结果
+}
+
+type BattleVerifyReq struct {
+	DeskId string `json:"deskId" msgpack:"deskId" binding:"required"`
+}
+
+type BattleVerifyResp struct {
+	DeskId     string  `json:"deskId" msgpack:"deskId"`
+	BattleId   int32   `json:"battleId" msgpack:"battleId"`
+	ServerSeed string  `json:"serverSeed" msgpack:"serverSeed"` // 服务器种子
+	SeedHash   string  `json:"seedHash" msgpack:"seedHash"`     // 开局时公开的哈希
+	Result     []int32 `json:"result" msgpack:"result"`         // 每回合杀死的格子
+	Verified   bool    `json:"verified" msgpack:"verified"`     // 种子和结果是否一致
+}
+
+type BattleHistoryReq struct {
+	Page  int `json:"page" msgpack:"page"`   // 页码，从1开始
+	Limit int `json:"limit" msgpack:"limit"` // 每页条数
+}
+
+type BattleRecordData struct {
+	BattleId     int32  `json:"battleId" msgpack:"battleId"`
+	DeskId       string `json:"deskId" msgpack:"deskId"`
+	PetID        int32  `json:"petId" msgpack:"petId"`
+	Rounds       int32  `json:"rounds" msgpack:"rounds"` // 存活的回合数
+	Win          byte   `json:"win" msgpack:"win"`
+	Bonus        int32  `json:"bonus" msgpack:"bonus"`
+	TournamentId uint64 `json:"tournamentId,omitempty" msgpack:"tournamentId"`
+	CreatedAt    int64  `json:"createdAt" msgpack:"createdAt"`
+}
+
+type BattleHistoryResp struct {
+	List  []*BattleRecordData `json:"list" msgpack:"list"`
+	Total int64               `json:"total" msgpack:"total"`
+}
+
+type BattleStatsResp struct {
+	BattleCount int64   `json:"battleCount" msgpack:"battleCount"`
+	WinCount    int64   `json:"winCount" msgpack:"winCount"`
+	WinRate     int32   `json:"winRate" msgpack:"winRate"`     // 胜率，万分比
+	AvgRounds   float64 `json:"avgRounds" msgpack:"avgRounds"` // 平均存活的回合数
+	TotalBonus  int64   `json:"totalBonus" msgpack:"totalBonus"`
+}
+
+type BattleReplayReq struct {
+	DeskId string `json:"deskId" msgpack:"deskId" binding:"required"`
+}
+
+type BattleReplayPlayer struct {
+	RoleId   uint64  `json:"roleId" msgpack:"roleId"`
+	Name     string  `json:"name" msgpack:"name"`
+	IsRobot  byte    `json:"isRobot,omitempty" msgpack:"isRobot"`
+	PetId    int32   `json:"petId" msgpack:"petId"`
+	Ability  int32   `json:"ability,omitempty" msgpack:"ability"`   // 宠物能力
+	Bet      []int32 `json:"bet" msgpack:"bet"`                     // 每回合下注的格子
+	SafeGrid []int32 `json:"safeGrid,omitempty" msgpack:"safeGrid"` // 每回合看到的安全格子
+	Survived int32   `json:"survived,omitempty" msgpack:"survived"` // 能力存活触发的回合
+	Rounds   int32   `json:"rounds" msgpack:"rounds"`               // 存活的回合数
+	Win      byte    `json:"win" msgpack:"win"`
+	Bonus    int32   `json:"bonus" msgpack:"bonus"`
+}
+
+type BattleReplayResp struct {
+	DeskId       string                `json:"deskId" msgpack:"deskId"`
+	BattleId     int32                 `json:"battleId" msgpack:"battleId"`
+	CreatedAt    int64                 `json:"createdAt" msgpack:"createdAt"`
+	StartAt      int64                 `json:"startAt" msgpack:"startAt"`
+	ServerSeed   string                `json:"serverSeed" msgpack:"serverSeed"`
+	SeedHash     string                `json:"seedHash" msgpack:"seedHash"`
+	Result       []int32               `json:"result" msgpack:"result"`         // 每回合杀死的格子
+	RoundBonus   []int32               `json:"roundBonus" msgpack:"roundBonus"` // 每回合的奖金
+	Players      []*BattleReplayPlayer `json:"players" msgpack:"players"`
+	TournamentId uint64                `json:"tournamentId,omitempty" msgpack:"tournamentId"`
+}
+
+type BattleSpectateReq struct {
+	DeskId     string `json:"deskId" msgpack:"deskId" binding:"required"`
+	InviteCode string `json:"inviteCode" msgpack:"inviteCode"` // 观战私人房间需要邀请码
+}
+
+type BattleSpectateResp struct {
+	DeskId         string            `json:"deskId" msgpack:"deskId"`
+	BattleId       int32             `json:"battleId" msgpack:"battleId"`
+	ServerTime     int64             `json:"serverTime" msgpack:"serverTime"`
+	State          byte              `json:"state" msgpack:"state"` // 0匹配中 1战斗中 2战斗已结束
+	Round          int               `json:"round" msgpack:"round"`
+	TotalRound     int32             `json:"totalRound" msgpack:"totalRound"`
+	RoundStartTime int64             `json:"roundStartTime" msgpack:"roundStartTime"`
+	Result         []int32           `json:"result,omitempty" msgpack:"result"`             // 已经结束的回合杀死的格子
+	LeftPlayerNum  int               `json:"leftPlayerNum" msgpack:"leftPlayerNum"`         // 存活的人数
+	MaxPlayerNum   int               `json:"maxPlayerNum" msgpack:"maxPlayerNum"`           // 人数上限
+	ScoreList      map[int32]int32   `json:"scoreList,omitempty" msgpack:"scoreList"`       // 当前回合每个格子的分数
+	PetList        map[int32][]int32 `json:"petList,omitempty" msgpack:"petList"`           // 当前回合每个格子的宠物
+	PetAbility     map[int32]int32   `json:"petAbility,omitempty" msgpack:"petAbility"`     // 宠物id -> 能力
+	TournamentId   uint64            `json:"tournamentId,omitempty" msgpack:"tournamentId"` // 锦标赛
+}
+
+type BattleLobbyReq struct {
+	BattleId int32 `json:"battleId" msgpack:"battleId" binding:"required"`
+}
+
+type BattleLobbyDesk struct {
+	DeskId        string `json:"deskId" msgpack:"deskId"`
+	State         byte   `json:"state" msgpack:"state"` // 0匹配中 1战斗中
+	Round         int    `json:"round" msgpack:"round"`
+	LeftPlayerNum int    `json:"leftPlayerNum" msgpack:"leftPlayerNum"`
+	MaxPlayerNum  int    `json:"maxPlayerNum" msgpack:"maxPlayerNum"`
+	StartAt       int64  `json:"startAt" msgpack:"startAt"`
+	TournamentId  uint64 `json:"tournamentId,omitempty" msgpack:"tournamentId"`
+}
+
+type BattleLobbyResp struct {
+	BattleId int32              `json:"battleId" msgpack:"battleId"`
+	List     []*BattleLobbyDesk `json:"list" msgpack:"list"`
+}
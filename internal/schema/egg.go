@@ -11,3 +11,24 @@ type GetEggOpenReq struct {
 	ID      int32 `json:"id" msgpack:"id" binding:"required"`
 	GuideId int32 `json:"guideId" msgpack:"guideId"`
 }
+
+type EggOpenBatchReq struct {
+	IDs     []uint64 `json:"ids" msgpack:"ids"`         // 要开的蛋，为空时按品质开
+	Quality int32    `json:"quality" msgpack:"quality"` // 开所有该品质的蛋，品质为评分对应的EggScoreTb的id
+}
+
+type EggOpenBatchResp struct {
+	RewardList []*RewardData `json:"rewards" msgpack:"rewards"`   // 合并后的奖励
+	OpenIds    []uint64      `json:"openIds" msgpack:"openIds"`   // 开出奖励的蛋
+	EmptyIds   []uint64      `json:"emptyIds" msgpack:"emptyIds"` // 空蛋，可以使用挽回卡重新获得
+}
+
+type EggFusionReq struct {
+	IDs        []uint64 `json:"ids" msgpack:"ids" binding:"required"` // 要合成的蛋
+	CatalystId int32    `json:"catalystId" msgpack:"catalystId"`      // 催化剂道具，0为不使用
+}
+
+type EggFusionResp struct {
+	Egg      *Egg     `json:"egg" msgpack:"egg"`           // 合成的新蛋
+	FusedIds []uint64 `json:"fusedIds" msgpack:"fusedIds"` // 消耗的蛋
+}
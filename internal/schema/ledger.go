@@ -0,0 +1,20 @@
+package schema
+
+type LedgerDataReq struct {
+	Page  int `json:"page" msgpack:"page"`   // 页码，从1开始
+	Limit int `json:"limit" msgpack:"limit"` // 每页条数
+}
+
+type LedgerData struct {
+	Type      int32 `json:"type" msgpack:"type"` // 1宠物 2道具
+	ItemID    int32 `json:"itemId" msgpack:"itemId"`
+	Delta     int32 `json:"delta" msgpack:"delta"`     // 变化数量
+	Balance   int32 `json:"balance" msgpack:"balance"` // 变化后的数量
+	Source    int32 `json:"source" msgpack:"source"`   // 来源
+	CreatedAt int64 `json:"createdAt" msgpack:"createdAt"`
+}
+
+type LedgerDataResp struct {
+	List  []*LedgerData `json:"list" msgpack:"list"`
+	Total int64         `json:"total" msgpack:"total"`
+}
@@ -0,0 +1,33 @@
+package schema
+
+type OrderListReq struct {
+	Page  int `json:"page" msgpack:"page"`   // 页码，从1开始
+	Limit int `json:"limit" msgpack:"limit"` // 每页条数
+}
+
+type OrderData struct {
+	OrderId     string `json:"orderId" msgpack:"orderId"`
+	ShopId      int32  `json:"shopId" msgpack:"shopId"`
+	ShopType    byte   `json:"shopType" msgpack:"shopType"`
+	Currency    int    `json:"currency" msgpack:"currency"`
+	TotalAmount int64  `json:"totalAmount" msgpack:"totalAmount"` // 订单金额
+	PaidAmount  int64  `json:"paidAmount" msgpack:"paidAmount"`   // 实付金额
+	OrderStatus byte   `json:"orderStatus" msgpack:"orderStatus"` // 0待支付 1已发货 2已支付 3已过期 4已取消 5已退款 6少付 7多付
+	TxHash      string `json:"txHash" msgpack:"txHash"`
+	CreatedAt   int64  `json:"createdAt" msgpack:"createdAt"`
+	ExpireAt    int64  `json:"expireAt" msgpack:"expireAt"` // 支付截止时间
+	UpdatedAt   int64  `json:"updatedAt" msgpack:"updatedAt"`
+}
+
+type OrderListResp struct {
+	List  []*OrderData `json:"list" msgpack:"list"`
+	Total int64        `json:"total" msgpack:"total"`
+}
+
+type OrderCancelReq struct {
+	OrderId string `json:"orderId" msgpack:"orderId" binding:"required"`
+}
+
+type OrderCancelResp struct {
+	Order *OrderData `json:"order" msgpack:"order"`
+}
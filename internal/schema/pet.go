@@ -4,3 +4,31 @@ type Pet struct {
 	PetID  int32 `json:"petId" msgpack:"petId"`
 	PetNum int32 `json:"petNum" msgpack:"petNum"`
 }
+
+type PetInstance struct {
+	ID    uint64 `json:"id" msgpack:"id"`
+	PetID int32  `json:"petId" msgpack:"petId"`
+	Level int32  `json:"level" msgpack:"level"`
+	Exp   int32  `json:"exp" msgpack:"exp"`     // 当前等级的经验
+	Stage int32  `json:"stage" msgpack:"stage"` // 进化阶段
+}
+
+type PetFeedReq struct {
+	ID      uint64   `json:"id" msgpack:"id" binding:"required"` // 喂养的宠物
+	PetIds  []uint64 `json:"petIds" msgpack:"petIds"`            // 喂掉的同种宠物
+	ItemId  int32    `json:"itemId" msgpack:"itemId"`            // 喂掉的道具
+	ItemNum int32    `json:"itemNum" msgpack:"itemNum"`
+}
+
+type PetFeedResp struct {
+	Pet    *PetInstance `json:"pet" msgpack:"pet"`
+	FedIds []uint64     `json:"fedIds" msgpack:"fedIds"` // 喂掉的宠物
+}
+
+type PetEvolveReq struct {
+	ID uint64 `json:"id" msgpack:"id" binding:"required"`
+}
+
+type PetEvolveResp struct {
+	Pet *PetInstance `json:"pet" msgpack:"pet"`
+}
@@ -4,6 +4,7 @@ type RoleDataResp struct {
 	Token                string                  `json:"token" msgpack:"token"`
 	RoleID               uint64                  `json:"roleId" msgpack:"roleId"`
 	Pets                 []*Pet                  `json:"pets" msgpack:"pets"`
+	PetInstances         []*PetInstance          `json:"petInstances" msgpack:"petInstances"` // 每只宠物的等级和经验
 	Items                []*Item                 `json:"items" msgpack:"items"`
 	Eggs                 []*Egg                  `json:"eggs" msgpack:"eggs"`
 	Shop                 *ShopDataResp           `json:"shop" msgpack:"shop"`
@@ -11,6 +12,7 @@ type RoleDataResp struct {
 	Guide                *GuideDataResp          `json:"guide" msgpack:"guide"`
 	Sign                 *SignDataResp           `json:"sign" msgpack:"sign"`
 	PassPortReward       *PassPortRewardDataResp `json:"passPortReward" msgpack:"passPortReward"`
+	EggPity              *EggPityDataResp        `json:"eggPity" msgpack:"eggPity"` // 开蛋保底计数
 	ServerTime           int64                   `json:"serverTime" msgpack:"serverTime"`
 	LastLoginTime        int64                   `json:"lastLoginTime" msgpack:"lastLoginTime"`
 	LastLayEggTime       int64                   `json:"lastLayEggTime" msgpack:"lastLayEggTime"`
@@ -66,3 +68,7 @@ type DailyResp struct {
 	Reward     *RewardData   `json:"reward" msgpack:"reward"`
 	ServerTime int64         `json:"serverTime" msgpack:"serverTime"`
 }
+
+type EggPityDataResp struct {
+	Data map[int32]int32 `json:"data" msgpack:"data"` // 品质 -> 连续没有开出该品质及以上的次数
+}
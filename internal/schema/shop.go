@@ -30,8 +30,9 @@ type ShopCreateOrderReq struct {
 }
 
 type ShopCreateOrderResp struct {
-	OrderId string `json:"orderId" msgpack:"orderId"`
-	Price   int64  `json:"price" msgpack:"price"`
+	OrderId  string `json:"orderId" msgpack:"orderId"`
+	Price    int64  `json:"price" msgpack:"price"`
+	ExpireAt int64  `json:"expireAt" msgpack:"expireAt"` // 支付截止时间
 }
 
 type ShopDeliveryReq struct {
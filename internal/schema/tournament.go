@@ -0,0 +1,49 @@
+package schema
+
+type TournamentData struct {
+	ID           uint64 `json:"id" msgpack:"id"`
+	TournamentId int32  `json:"tournamentId" msgpack:"tournamentId"`
+	StartAt      int64  `json:"startAt" msgpack:"startAt"`
+	Stage        int32  `json:"stage" msgpack:"stage"`
+	State        byte   `json:"state" msgpack:"state"` // 0报名中 1比赛中 2已结束 3人数不足取消
+	PlayerNum    int32  `json:"playerNum" msgpack:"playerNum"`
+}
+
+type TournamentEntryData struct {
+	RoleID  uint64 `json:"roleId" msgpack:"roleId"`
+	PetID   int32  `json:"petId" msgpack:"petId"`
+	Stage   int32  `json:"stage" msgpack:"stage"`   // 到达的轮次
+	DeskId  string `json:"deskId" msgpack:"deskId"` // 所在轮次的房间
+	Rounds  int32  `json:"rounds" msgpack:"rounds"` // 所在轮次存活的回合数
+	Out     byte   `json:"out" msgpack:"out"`       // 1已淘汰
+	Rank    int32  `json:"rank" msgpack:"rank"`
+	Claimed byte   `json:"claimed" msgpack:"claimed"`
+}
+
+type TournamentRegisterReq struct {
+	TournamentId int32 `json:"tournamentId" msgpack:"tournamentId" binding:"required"`
+	PetId        int32 `json:"petId" msgpack:"petId" binding:"required"`
+}
+
+type TournamentRegisterResp struct {
+	Tournament *TournamentData `json:"tournament" msgpack:"tournament"`
+	RewardList []*RewardData   `json:"rewards" msgpack:"rewards"` // 报名消耗
+}
+
+type TournamentBracketReq struct {
+	ID uint64 `json:"id" msgpack:"id" binding:"required"`
+}
+
+type TournamentBracketResp struct {
+	Tournament *TournamentData        `json:"tournament" msgpack:"tournament"`
+	Entries    []*TournamentEntryData `json:"entries" msgpack:"entries"`
+}
+
+type TournamentClaimReq struct {
+	ID uint64 `json:"id" msgpack:"id" binding:"required"`
+}
+
+type TournamentClaimResp struct {
+	Rank       int32         `json:"rank" msgpack:"rank"`
+	RewardList []*RewardData `json:"rewards" msgpack:"rewards"`
+}
@@ -0,0 +1,77 @@
+package oracle
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Breaker 熔断器，连续失败threshold次后在cooldown时间内不再请求价格源，冷却后放行一次请求试探
+type Breaker struct {
+	oracle    PriceOracle
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	failures int       // 连续失败次数
+	openAt   time.Time // 熔断开始时间
+	probing  bool      // 冷却后正在试探
+}
+
+func NewBreaker(oracle PriceOracle, threshold int, cooldown time.Duration) *Breaker {
+	if threshold <= 0 {
+		threshold = 1
+	}
+	return &Breaker{
+		oracle:    oracle,
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+	}
+}
+
+func (b *Breaker) Name() string {
+	return b.oracle.Name()
+}
+
+func (b *Breaker) Price(ctx context.Context, instId string) (Quote, error) {
+	if !b.allow() {
+		return Quote{}, ErrBreakerOpen
+	}
+
+	q, err := b.oracle.Price(ctx, instId)
+	b.done(err == nil)
+	return q, err
+}
+
+func (b *Breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return true
+	}
+
+	if b.probing || b.now().Sub(b.openAt) < b.cooldown {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+func (b *Breaker) done(ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if ok {
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openAt = b.now()
+	}
+}
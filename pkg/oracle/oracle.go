@@ -0,0 +1,162 @@
+package oracle
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNotEnoughSources = errors.New("oracle: not enough price sources")
+	ErrStale            = errors.New("oracle: price is stale")
+	ErrBreakerOpen      = errors.New("oracle: circuit breaker is open")
+)
+
+// Quote 报价
+type Quote struct {
+	Price float64
+	Time  time.Time // 报价时间
+}
+
+// PriceOracle 价格源
+type PriceOracle interface {
+	Name() string
+	// Price 返回交易对的最新报价，instId格式为 TON-USDT
+	Price(ctx context.Context, instId string) (Quote, error)
+}
+
+// Static 固定价格，用于人工指定价格和测试
+type Static map[string]float64
+
+func (s Static) Name() string {
+	return "static"
+}
+
+func (s Static) Price(ctx context.Context, instId string) (Quote, error) {
+	price, ok := s[instId]
+	if !ok || price <= 0 {
+		return Quote{}, fmt.Errorf("oracle: static price of %s not found", instId)
+	}
+	return Quote{Price: price, Time: time.Now()}, nil
+}
+
+// Median 同时查询多个价格源，取未过期报价的中位数
+type Median struct {
+	oracles    []PriceOracle
+	maxStale   time.Duration // 报价的最大时效，0表示不检查
+	minSources int           // 最少需要的有效报价数
+	timeout    time.Duration // 单个价格源的超时时间，0表示不限制
+	now        func() time.Time
+}
+
+func NewMedian(maxStale time.Duration, minSources int, timeout time.Duration, oracles ...PriceOracle) *Median {
+	if minSources <= 0 {
+		minSources = 1
+	}
+	return &Median{
+		oracles:    oracles,
+		maxStale:   maxStale,
+		minSources: minSources,
+		timeout:    timeout,
+		now:        time.Now,
+	}
+}
+
+func (m *Median) Name() string {
+	names := make([]string, 0, len(m.oracles))
+	for _, o := range m.oracles {
+		names = append(names, o.Name())
+	}
+	return "median(" + strings.Join(names, ",") + ")"
+}
+
+func (m *Median) Price(ctx context.Context, instId string) (Quote, error) {
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		quotes = make([]Quote, 0, len(m.oracles))
+		errs   = make([]error, 0)
+	)
+
+	for _, o := range m.oracles {
+		wg.Add(1)
+		go func(o PriceOracle) {
+			defer wg.Done()
+
+			c := ctx
+			if m.timeout > 0 {
+				var cancel context.CancelFunc
+				c, cancel = context.WithTimeout(ctx, m.timeout)
+				defer cancel()
+			}
+
+			q, err := o.Price(c, instId)
+			if err == nil && q.Price <= 0 {
+				err = fmt.Errorf("invalid price %v", q.Price)
+			}
+			if err == nil && m.maxStale > 0 && m.now().Sub(q.Time) > m.maxStale {
+				err = ErrStale
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", o.Name(), err))
+				return
+			}
+			quotes = append(quotes, q)
+		}(o)
+	}
+	wg.Wait()
+
+	if len(quotes) < m.minSources {
+		return Quote{}, fmt.Errorf("%w: %d/%d %v", ErrNotEnoughSources, len(quotes), m.minSources, errors.Join(errs...))
+	}
+
+	sort.Slice(quotes, func(i, j int) bool {
+		return quotes[i].Price < quotes[j].Price
+	})
+
+	// 取中位数，报价时间取参与计算的报价中最早的
+	n := len(quotes)
+	if n%2 == 1 {
+		return quotes[n/2], nil
+	}
+
+	a, b := quotes[n/2-1], quotes[n/2]
+	q := Quote{Price: (a.Price + b.Price) / 2, Time: a.Time}
+	if b.Time.Before(q.Time) {
+		q.Time = b.Time
+	}
+	return q, nil
+}
+
+func getJSON(ctx context.Context, client *http.Client, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("http status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,144 @@
+package oracle
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// 可以控制报价和错误的价格源
+type fakeOracle struct {
+	name  string
+	quote Quote
+	err   error
+	calls int
+}
+
+func (o *fakeOracle) Name() string {
+	return o.name
+}
+
+func (o *fakeOracle) Price(ctx context.Context, instId string) (Quote, error) {
+	o.calls++
+	return o.quote, o.err
+}
+
+func TestMedian(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	quote := func(price float64) *fakeOracle {
+		return &fakeOracle{name: "fake", quote: Quote{Price: price, Time: now}}
+	}
+
+	cases := []struct {
+		name    string
+		oracles []PriceOracle
+		want    float64
+	}{
+		{"odd", []PriceOracle{quote(3), quote(1), quote(2)}, 2},
+		{"even", []PriceOracle{quote(4), quote(1), quote(2), quote(3)}, 2.5},
+		{"outlier", []PriceOracle{quote(2), quote(2.1), quote(1000)}, 2.1},
+		{"failed", []PriceOracle{quote(2), &fakeOracle{name: "down", err: errors.New("down")}, quote(4)}, 3},
+		{"invalid", []PriceOracle{quote(2), quote(0), quote(4)}, 3},
+	}
+	for _, c := range cases {
+		m := NewMedian(0, 2, 0, c.oracles...)
+		m.now = func() time.Time { return now }
+		q, err := m.Price(context.Background(), "TON-USDT")
+		if err != nil {
+			t.Fatalf("%s: error %v", c.name, err)
+		}
+		if q.Price != c.want {
+			t.Errorf("%s: price %v, want %v", c.name, q.Price, c.want)
+		}
+	}
+}
+
+func TestMedianNotEnoughSources(t *testing.T) {
+	m := NewMedian(0, 2, 0, &fakeOracle{name: "a", quote: Quote{Price: 2, Time: time.Now()}}, &fakeOracle{name: "b", err: errors.New("down")})
+	if _, err := m.Price(context.Background(), "TON-USDT"); !errors.Is(err, ErrNotEnoughSources) {
+		t.Fatalf("error %v, want %v", err, ErrNotEnoughSources)
+	}
+}
+
+// 过期的报价不参与计算
+func TestMedianStale(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	fresh := &fakeOracle{name: "fresh", quote: Quote{Price: 2, Time: now.Add(-time.Minute)}}
+	stale := &fakeOracle{name: "stale", quote: Quote{Price: 100, Time: now.Add(-10 * time.Minute)}}
+
+	m := NewMedian(5*time.Minute, 1, 0, fresh, stale)
+	m.now = func() time.Time { return now }
+	q, err := m.Price(context.Background(), "TON-USDT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Price != 2 {
+		t.Errorf("price %v, want 2", q.Price)
+	}
+
+	m = NewMedian(5*time.Minute, 2, 0, fresh, stale)
+	m.now = func() time.Time { return now }
+	if _, err := m.Price(context.Background(), "TON-USDT"); !errors.Is(err, ErrNotEnoughSources) {
+		t.Fatalf("error %v, want %v", err, ErrNotEnoughSources)
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	o := &fakeOracle{name: "fake", err: errors.New("down")}
+	b := NewBreaker(o, 2, time.Minute)
+	b.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	// 连续失败达到阈值后熔断，不再请求价格源
+	for i := 0; i < 2; i++ {
+		if _, err := b.Price(ctx, "TON-USDT"); err == nil || errors.Is(err, ErrBreakerOpen) {
+			t.Fatalf("call %d: error %v, want oracle error", i, err)
+		}
+	}
+	if _, err := b.Price(ctx, "TON-USDT"); !errors.Is(err, ErrBreakerOpen) {
+		t.Fatalf("error %v, want %v", err, ErrBreakerOpen)
+	}
+	if o.calls != 2 {
+		t.Fatalf("oracle called %d times, want 2", o.calls)
+	}
+
+	// 冷却后试探失败则继续熔断
+	now = now.Add(time.Minute)
+	if _, err := b.Price(ctx, "TON-USDT"); err == nil || errors.Is(err, ErrBreakerOpen) {
+		t.Fatalf("probe error %v, want oracle error", err)
+	}
+	if _, err := b.Price(ctx, "TON-USDT"); !errors.Is(err, ErrBreakerOpen) {
+		t.Fatalf("error %v, want %v", err, ErrBreakerOpen)
+	}
+
+	// 冷却后试探成功则恢复
+	now = now.Add(time.Minute)
+	o.err = nil
+	o.quote = Quote{Price: 2, Time: now}
+	for i := 0; i < 2; i++ {
+		if q, err := b.Price(ctx, "TON-USDT"); err != nil || q.Price != 2 {
+			t.Fatalf("call %d: price %v error %v", i, q.Price, err)
+		}
+	}
+	if o.calls != 5 {
+		t.Fatalf("oracle called %d times, want 5", o.calls)
+	}
+}
+
+func TestNewStatic(t *testing.T) {
+	o, err := New("static", nil, Static{"TON-USDT": 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q, err := o.Price(context.Background(), "TON-USDT"); err != nil || q.Price != 5 {
+		t.Fatalf("price %v error %v, want 5", q.Price, err)
+	}
+	if _, err := o.Price(context.Background(), "BTC-USDT"); err == nil {
+		t.Fatal("missing static price should fail")
+	}
+	if _, err := New("unknown", nil, nil); err == nil {
+		t.Fatal("unknown provider should fail")
+	}
+}
@@ -0,0 +1,133 @@
+package oracle
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OKX 欧易行情
+type OKX struct {
+	Client *http.Client
+}
+
+func (o *OKX) Name() string {
+	return "okx"
+}
+
+func (o *OKX) Price(ctx context.Context, instId string) (Quote, error) {
+	var resp struct {
+		Code string `json:"code"`
+		Msg  string `json:"msg"`
+		Data []struct {
+			Last string `json:"last"`
+			Ts   string `json:"ts"`
+		} `json:"data"`
+	}
+
+	if err := getJSON(ctx, httpClient(o.Client), "https://www.okx.com/api/v5/market/ticker?instId="+instId, &resp); err != nil {
+		return Quote{}, err
+	}
+	if resp.Code != "0" || len(resp.Data) == 0 {
+		return Quote{}, fmt.Errorf("error from API: %s %s", resp.Code, resp.Msg)
+	}
+
+	return parseQuote(resp.Data[0].Last, resp.Data[0].Ts)
+}
+
+// Binance 币安行情，接口不返回报价时间，使用当前时间
+type Binance struct {
+	Client *http.Client
+}
+
+func (b *Binance) Name() string {
+	return "binance"
+}
+
+func (b *Binance) Price(ctx context.Context, instId string) (Quote, error) {
+	var resp struct {
+		Symbol string `json:"symbol"`
+		Price  string `json:"price"`
+	}
+
+	if err := getJSON(ctx, httpClient(b.Client), "https://api.binance.com/api/v3/ticker/price?symbol="+symbol(instId), &resp); err != nil {
+		return Quote{}, err
+	}
+
+	return parseQuote(resp.Price, "")
+}
+
+// Bybit 行情
+type Bybit struct {
+	Client *http.Client
+}
+
+func (b *Bybit) Name() string {
+	return "bybit"
+}
+
+func (b *Bybit) Price(ctx context.Context, instId string) (Quote, error) {
+	var resp struct {
+		RetCode int    `json:"retCode"`
+		RetMsg  string `json:"retMsg"`
+		Result  struct {
+			List []struct {
+				LastPrice string `json:"lastPrice"`
+			} `json:"list"`
+		} `json:"result"`
+		Time int64 `json:"time"`
+	}
+
+	if err := getJSON(ctx, httpClient(b.Client), "https://api.bybit.com/v5/market/tickers?category=spot&symbol="+symbol(instId), &resp); err != nil {
+		return Quote{}, err
+	}
+	if resp.RetCode != 0 || len(resp.Result.List) == 0 {
+		return Quote{}, fmt.Errorf("error from API: %d %s", resp.RetCode, resp.RetMsg)
+	}
+
+	return parseQuote(resp.Result.List[0].LastPrice, strconv.FormatInt(resp.Time, 10))
+}
+
+// New 根据名称创建价格源，static使用配置的固定价格
+func New(name string, client *http.Client, static Static) (PriceOracle, error) {
+	switch name {
+	case "static":
+		return static, nil
+	case "okx":
+		return &OKX{Client: client}, nil
+	case "binance":
+		return &Binance{Client: client}, nil
+	case "bybit":
+		return &Bybit{Client: client}, nil
+	}
+	return nil, fmt.Errorf("oracle: unknown provider %s", name)
+}
+
+// TON-USDT 转为 TONUSDT
+func symbol(instId string) string {
+	return strings.ReplaceAll(instId, "-", "")
+}
+
+func httpClient(client *http.Client) *http.Client {
+	if client == nil {
+		return http.DefaultClient
+	}
+	return client
+}
+
+// 解析价格和毫秒时间戳，没有时间戳时使用当前时间
+func parseQuote(price string, ts string) (Quote, error) {
+	p, err := strconv.ParseFloat(price, 64)
+	if err != nil {
+		return Quote{}, err
+	}
+
+	t := time.Now()
+	if ms, err := strconv.ParseInt(ts, 10, 64); err == nil && ms > 0 {
+		t = time.UnixMilli(ms)
+	}
+	return Quote{Price: p, Time: t}, nil
+}
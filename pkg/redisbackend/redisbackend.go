@@ -17,6 +17,7 @@ var (
 
 type RedisLocker interface {
 	Lock(ctx context.Context) error
+	TryLock(ctx context.Context) error
 	Unlock(ctx context.Context) (bool, error)
 }
 
@@ -47,6 +48,11 @@ func (rb *RedisBackend) Lock(ctx context.Context) error {
 	return rb.mutex.LockContext(ctx)
 }
 
+// TryLock 只尝试加锁一次，失败立即返回
+func (rb *RedisBackend) TryLock(ctx context.Context) error {
+	return rb.mutex.TryLockContext(ctx)
+}
+
 func (rb *RedisBackend) Unlock(ctx context.Context) (bool, error) {
 	return rb.mutex.UnlockContext(ctx)
 }
@@ -0,0 +1,112 @@
+package telegram
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrSignInvalid     = errors.New("init data sign invalid")      // 签名无效
+	ErrExpired         = errors.New("init data expired")           // 数据已过期
+	ErrAuthDateInvalid = errors.New("init data auth_date invalid") // 签名时间在未来
+)
+
+// 允许的服务器时间误差，签名时间超过当前时间加上误差时拒绝
+const maxClockSkew = time.Minute
+
+// WebAppUser initData 中的用户信息
+type WebAppUser struct {
+	ID           int64  `json:"id"`
+	FirstName    string `json:"first_name"`
+	LastName     string `json:"last_name"`
+	UserName     string `json:"username"`
+	LanguageCode string `json:"language_code"`
+	PhotoUrl     string `json:"photo_url"`
+	IsPremium    bool   `json:"is_premium"`
+}
+
+// InitData 校验通过的 initData
+type InitData struct {
+	QueryID    string
+	User       *WebAppUser
+	StartParam string
+	AuthDate   int64
+	Hash       string
+}
+
+// Validate 校验 Telegram WebApp 的 initData 签名和有效期
+// https://core.telegram.org/bots/webapps#validating-data-received-via-the-mini-app
+// expIn 为0时不校验有效期
+func Validate(initData string, botToken string, expIn time.Duration) (*InitData, error) {
+	values, err := url.ParseQuery(initData)
+	if err != nil {
+		return nil, ErrSignInvalid
+	}
+
+	hash := values.Get("hash")
+	if hash == "" || botToken == "" {
+		return nil, ErrSignInvalid
+	}
+
+	// 按key排序后用换行连接生成待校验字符串
+	pairs := make([]string, 0, len(values))
+	for k := range values {
+		if k == "hash" {
+			continue
+		}
+		pairs = append(pairs, k+"="+values.Get(k))
+	}
+	sort.Strings(pairs)
+
+	if !hmac.Equal([]byte(sign(strings.Join(pairs, "\n"), botToken)), []byte(hash)) {
+		return nil, ErrSignInvalid
+	}
+
+	authDate, err := strconv.ParseInt(values.Get("auth_date"), 10, 64)
+	if err != nil {
+		return nil, ErrSignInvalid
+	}
+
+	now := time.Now()
+	if time.Unix(authDate, 0).After(now.Add(maxClockSkew)) {
+		return nil, ErrAuthDateInvalid
+	}
+
+	if expIn > 0 && time.Unix(authDate, 0).Add(expIn).Before(now) {
+		return nil, ErrExpired
+	}
+
+	data := &InitData{
+		QueryID:    values.Get("query_id"),
+		StartParam: values.Get("start_param"),
+		AuthDate:   authDate,
+		Hash:       hash,
+	}
+
+	if v := values.Get("user"); v != "" {
+		data.User = new(WebAppUser)
+		if err := json.Unmarshal([]byte(v), data.User); err != nil {
+			return nil, ErrSignInvalid
+		}
+	}
+
+	return data, nil
+}
+
+// 计算签名 HMAC_SHA256(HMAC_SHA256("WebAppData", botToken), dataCheckString)
+func sign(dataCheckString string, botToken string) string {
+	secretKey := hmac.New(sha256.New, []byte("WebAppData"))
+	secretKey.Write([]byte(botToken))
+
+	h := hmac.New(sha256.New, secretKey.Sum(nil))
+	h.Write([]byte(dataCheckString))
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,57 @@
+package telegram
+
+import (
+	"errors"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testBotToken = "123456:test-token"
+
+// 按Telegram的规则生成签名后的initData
+func signedInitData(authDate int64) string {
+	values := url.Values{}
+	values.Set("auth_date", strconv.FormatInt(authDate, 10))
+	values.Set("query_id", "AAHdF6IQAAAAAN0XohDhrOrc")
+	values.Set("user", `{"id":279058397,"first_name":"Vladislav"}`)
+
+	pairs := make([]string, 0, len(values))
+	for k := range values {
+		pairs = append(pairs, k+"="+values.Get(k))
+	}
+	sort.Strings(pairs)
+	values.Set("hash", sign(strings.Join(pairs, "\n"), testBotToken))
+	return values.Encode()
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Now().Unix()
+	cases := []struct {
+		name     string
+		initData string
+		botToken string
+		err      error
+	}{
+		{"valid", signedInitData(now - 60), testBotToken, nil},
+		{"skew", signedInitData(now + 10), testBotToken, nil},
+		{"future", signedInitData(now + 3600), testBotToken, ErrAuthDateInvalid},
+		{"expired", signedInitData(now - 2*86400), testBotToken, ErrExpired},
+		{"token", signedInitData(now), "654321:other-token", ErrSignInvalid},
+		{"empty token", signedInitData(now), "", ErrSignInvalid},
+		{"tampered", strings.Replace(signedInitData(now), "Vladislav", "Mallory", 1), testBotToken, ErrSignInvalid},
+	}
+	for _, c := range cases {
+		data, err := Validate(c.initData, c.botToken, 86400*time.Second)
+		if !errors.Is(err, c.err) {
+			t.Errorf("%s: error %v, want %v", c.name, err, c.err)
+			continue
+		}
+		if err == nil && (data.User == nil || data.User.ID != 279058397) {
+			t.Errorf("%s: user %+v", c.name, data.User)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package fair
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+)
+
+// NewSeed 生成随机的服务器种子
+func NewSeed() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Hash 种子的承诺值，开局时公开
+func Hash(seed string) string {
+	h := sha256.Sum256([]byte(seed))
+	return hex.EncodeToString(h[:])
+}
+
+// Intn 由种子和消息确定性地生成 [0,n) 的随机数
+// r = HMAC_SHA256(seed, message:counter)，取前8字节，超出均匀范围时counter加1重新计算
+func Intn(seed string, message string, n int) int {
+	if n <= 0 {
+		return 0
+	}
+
+	limit := math.MaxUint64 - math.MaxUint64%uint64(n)
+	for counter := 0; ; counter++ {
+		h := hmac.New(sha256.New, []byte(seed))
+		h.Write([]byte(fmt.Sprintf("%s:%d", message, counter)))
+		v := binary.BigEndian.Uint64(h.Sum(nil))
+		if v < limit {
+			return int(v % uint64(n))
+		}
+	}
+}
+
+// KillMessage 回合杀死格子的消息
+func KillMessage(round int) string {
+	return fmt.Sprintf("kill:%d", round)
+}
+
+// BetMessage 自动下注的消息
+func BetMessage(round int, roleId uint64) string {
+	return fmt.Sprintf("bet:%d:%d", round, roleId)
+}
+
+// RobotMessage 机器人策略的消息
+func RobotMessage(kind string, round int, roleId uint64) string {
+	return fmt.Sprintf("robot:%s:%d:%d", kind, round, roleId)
+}
+
+// ScoutMessage 宠物能力看到安全格子的消息
+func ScoutMessage(round int, roleId uint64) string {
+	return fmt.Sprintf("scout:%d:%d", round, roleId)
+}
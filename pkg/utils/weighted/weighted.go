@@ -0,0 +1,118 @@
+package weighted
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+)
+
+var ErrEmpty = errors.New("weighted: no items")
+
+// Source 随机数源，*rand.Rand 满足该接口，测试时可以传入固定种子的随机数源
+type Source interface {
+	Intn(n int) int
+	Int63n(n int64) int64
+}
+
+type defaultSource struct{}
+
+func (defaultSource) Intn(n int) int {
+	return rand.Intn(n)
+}
+
+func (defaultSource) Int63n(n int64) int64 {
+	return rand.Int63n(n)
+}
+
+// Default 使用 math/rand 全局随机数的随机数源，可以并发使用
+var Default Source = defaultSource{}
+
+// Sampler 按权重随机，使用别名法，构建O(n)，每次随机O(1)
+// 权重按整数计算，概率和配置的权重完全一致
+type Sampler[T any] struct {
+	items []T
+	prob  []int64 // 每列保留自己的概率，范围 [0, total)
+	alias []int   // 每列剩余部分的别名
+	total int64
+}
+
+// New 创建随机器，权重必须大于0
+func New[T any](items []T, weights []int32) (*Sampler[T], error) {
+	n := len(items)
+	if n == 0 {
+		return nil, ErrEmpty
+	}
+	if len(weights) != n {
+		return nil, fmt.Errorf("weighted: %d items with %d weights", n, len(weights))
+	}
+
+	// 每列的高度为 total，第i项占 weights[i]*n
+	var total int64
+	for i, w := range weights {
+		if w <= 0 {
+			return nil, fmt.Errorf("weighted: weight[%d] = %d <= 0", i, w)
+		}
+		total += int64(w)
+	}
+
+	s := &Sampler[T]{
+		items: items,
+		prob:  make([]int64, n),
+		alias: make([]int, n),
+		total: total,
+	}
+
+	scaled := make([]int64, n)
+	small := make([]int, 0, n)
+	large := make([]int, 0, n)
+	for i, w := range weights {
+		scaled[i] = int64(w) * int64(n)
+		if scaled[i] < total {
+			small = append(small, i)
+		} else {
+			large = append(large, i)
+		}
+	}
+
+	for len(small) > 0 && len(large) > 0 {
+		l := small[len(small)-1]
+		small = small[:len(small)-1]
+		g := large[len(large)-1]
+		large = large[:len(large)-1]
+
+		s.prob[l] = scaled[l]
+		s.alias[l] = g
+
+		// 大的一项补满小的一列
+		scaled[g] -= total - scaled[l]
+		if scaled[g] < total {
+			small = append(small, g)
+		} else {
+			large = append(large, g)
+		}
+	}
+
+	for _, i := range large {
+		s.prob[i] = total
+		s.alias[i] = i
+	}
+	for _, i := range small {
+		s.prob[i] = total
+		s.alias[i] = i
+	}
+	return s, nil
+}
+
+// Sample 随机一项
+func (s *Sampler[T]) Sample(r Source) T {
+	i := r.Intn(len(s.items))
+	if r.Int63n(s.total) < s.prob[i] {
+		return s.items[i]
+	}
+	return s.items[s.alias[i]]
+}
+
+// Len 项数
+func (s *Sampler[T]) Len() int {
+	return len(s.items)
+}
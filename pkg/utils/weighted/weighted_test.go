@@ -0,0 +1,139 @@
+package weighted
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// 依次返回固定的值，用于枚举所有随机结果
+type fixedSource struct {
+	column int
+	value  int64
+}
+
+func (s *fixedSource) Intn(n int) int {
+	return s.column
+}
+
+func (s *fixedSource) Int63n(n int64) int64 {
+	return s.value
+}
+
+// 卡方检验的临界值，显著性水平0.001，使用Wilson-Hilferty近似
+func chiSquareCritical(df int) float64 {
+	const z = 3.09
+	k := float64(df)
+	return k * math.Pow(1-2/(9*k)+z*math.Sqrt(2/(9*k)), 3)
+}
+
+func TestNewRejectsInvalidWeights(t *testing.T) {
+	cases := []struct {
+		name    string
+		items   []int
+		weights []int32
+	}{
+		{"empty", nil, nil},
+		{"zero", []int{1, 2, 3}, []int32{10, 0, 10}},
+		{"negative", []int{1, 2}, []int32{10, -1}},
+		{"length", []int{1, 2}, []int32{10}},
+	}
+	for _, c := range cases {
+		if _, err := New(c.items, c.weights); err == nil {
+			t.Errorf("%s: New(%v, %v) should fail", c.name, c.items, c.weights)
+		}
+	}
+}
+
+// 枚举所有的列和随机值，每一项被选中的次数应该正好是权重的n倍
+func TestAliasTableIsExact(t *testing.T) {
+	weightsList := [][]int32{
+		{1},
+		{5, 5, 5, 5},
+		{1, 2, 3, 4},
+		{100, 100, 100, 100, 100},
+		{1, 1000},
+		{7, 13, 29, 1, 50},
+	}
+	for _, weights := range weightsList {
+		items := make([]int, len(weights))
+		for i := range items {
+			items[i] = i
+		}
+		s, err := New(items, weights)
+		if err != nil {
+			t.Fatalf("New(%v) error: %v", weights, err)
+		}
+
+		counts := make([]int64, len(weights))
+		source := new(fixedSource)
+		for source.column = 0; source.column < len(weights); source.column++ {
+			for source.value = 0; source.value < s.total; source.value++ {
+				counts[s.Sample(source)]++
+			}
+		}
+
+		for i, w := range weights {
+			if want := int64(w) * int64(len(weights)); counts[i] != want {
+				t.Errorf("weights %v: item %d selected %d times, want %d", weights, i, counts[i], want)
+			}
+		}
+	}
+}
+
+// 按配置的权重随机，观察到的频率和权重做卡方检验
+func TestSampleMatchesWeights(t *testing.T) {
+	const draws = 200000
+	weightsList := [][]int32{
+		{5, 5, 5, 5},
+		{1, 2, 3, 4},
+		{100, 100, 100, 100, 100},
+		{1, 1000},
+		{7, 13, 29, 1, 50},
+	}
+	for i, weights := range weightsList {
+		items := make([]int, len(weights))
+		for j := range items {
+			items[j] = j
+		}
+		s, err := New(items, weights)
+		if err != nil {
+			t.Fatalf("New(%v) error: %v", weights, err)
+		}
+
+		// 固定种子，结果可以复现
+		r := rand.New(rand.NewSource(int64(i + 1)))
+		counts := make([]int, len(weights))
+		for j := 0; j < draws; j++ {
+			counts[s.Sample(r)]++
+		}
+
+		var total int64
+		for _, w := range weights {
+			total += int64(w)
+		}
+		var chi2 float64
+		for j, w := range weights {
+			expected := float64(draws) * float64(w) / float64(total)
+			d := float64(counts[j]) - expected
+			chi2 += d * d / expected
+		}
+		if critical := chiSquareCritical(len(weights) - 1); chi2 > critical {
+			t.Errorf("weights %v: counts %v, chi-square %.2f > %.2f", weights, counts, chi2, critical)
+		}
+	}
+}
+
+func TestSampleSameSeedSameSequence(t *testing.T) {
+	s, err := New([]string{"a", "b", "c"}, []int32{1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r1 := rand.New(rand.NewSource(42))
+	r2 := rand.New(rand.NewSource(42))
+	for i := 0; i < 1000; i++ {
+		if a, b := s.Sample(r1), s.Sample(r2); a != b {
+			t.Fatalf("draw %d: %s != %s", i, a, b)
+		}
+	}
+}
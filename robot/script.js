@@ -1,4 +1,5 @@
 import http from 'k6/http';
+import crypto from 'k6/crypto';
 import { check, sleep, current } from 'k6';
 
 // API 的基础 URL
@@ -16,6 +17,7 @@ const roundInterval = 5;
 const clickCount = 100;
 const robotId = 10001;
 const battleId = 1;
+const botToken = __ENV.BOT_TOKEN || ""; // 与服务器配置 Telegram.BotToken 一致
 
 // k6 的负载测试选项
 export const options = {
@@ -31,6 +33,18 @@ function logRequest(userId, url, data, isRequest = true) {
     );
 }
 
+// 生成带签名的 Telegram initData
+function buildInitData(userId) {
+    const params = {
+        auth_date: `${Math.floor(Date.now() / 1000)}`,
+        user: JSON.stringify({ id: userId, first_name: `robot${userId}` }),
+    };
+    const dataCheckString = Object.keys(params).sort().map((k) => `${k}=${params[k]}`).join("\n");
+    const secretKey = crypto.hmac('sha256', 'WebAppData', botToken, 'binary');
+    const hash = crypto.hmac('sha256', secretKey, dataCheckString, 'hex');
+    return Object.keys(params).map((k) => `${k}=${encodeURIComponent(params[k])}`).join("&") + `&hash=${hash}`;
+}
+
 // 执行 HTTP POST 请求的函数
 function request(url, token, data, code) {
     const refinedParams = {
@@ -172,7 +186,7 @@ export default function () {
     let token = "";
     let startTime = currentVUTime(); // 记录开始时间
 
-    let resp = request("/login", token, { locale: "", userUid: userId, username: "", startParam: "" }, [0]);
+    let resp = request("/login", token, { initData: buildInitData(robotId + __VU), os: "" }, [0]);
     token = resp.data.token;
     sleep(1);
 